@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -44,6 +46,7 @@ type App struct {
 	instanceMgr *instance.Manager
 	rconPool    *rcon.Pool
 	steamCmd    *steam.SteamCMD
+	steamJobs   *steam.JobQueue
 	monitors    map[string]*monitor.Collector
 	sched       *scheduler.Scheduler
 }
@@ -56,6 +59,7 @@ func NewApp(cfg *config.AppConfig, db *gorm.DB, encKey []byte) *App {
 		encKey:    encKey,
 		rconPool:  rcon.NewPool(),
 		steamCmd:  steam.New(cfg.SteamCMDPath),
+		steamJobs: steam.NewJobQueue(cfg.SteamCMDConcurrency),
 	}
 }
 
@@ -71,12 +75,24 @@ func (a *App) startup(ctx context.Context) {
 	a.instanceMgr.SetDecryptFn(func(encrypted string) (string, error) {
 		return crypto.Decrypt(encrypted, a.encKey)
 	})
+	backup.SetDecryptFn(func(encrypted string) (string, error) {
+		return crypto.Decrypt(encrypted, a.encKey)
+	})
+	// Plaintext copies of encrypted backups left behind by a crash
+	backup.RemoveStaleDecrypts()
 	a.instanceMgr.SetOnOutput(func(instanceID, line string) {
 		wailsruntime.EventsEmit(a.ctx, "console:"+instanceID, line)
 	})
 	a.instanceMgr.SetOnStatus(func(instanceID, status string) {
 		wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, status)
 	})
+	a.steamJobs.SetOnUpdate(func(j steam.Job) {
+		wailsruntime.EventsEmit(a.ctx, "steamcmd-job", j)
+		wailsruntime.EventsEmit(a.ctx, "steamcmd-job:"+j.ID, j)
+	})
+	a.steamJobs.SetOnLine(func(jobID, line string) {
+		wailsruntime.EventsEmit(a.ctx, "steamcmd-line:"+jobID, line)
+	})
 
 	// Auto-start instances that have auto_start enabled
 	if err := a.instanceMgr.AutoStartAll(); err != nil {
@@ -92,11 +108,25 @@ func (a *App) startup(ctx context.Context) {
 			a.RestartInstance(instanceID)
 		case scheduler.ActionRCON:
 			a.SendRCON(instanceID, payload)
+		case scheduler.ActionBackup:
+			a.CreateBackup(instanceID, payload)
+		case scheduler.ActionUpdate:
+			a.runServerUpdate(instanceID)
 		}
 	})
+	a.sched.Every("backup-retention", 24*time.Hour, func() {
+		if err := backup.Sweep(a.db); err != nil {
+			logger.Log.Error().Err(err).Msg("Backup retention sweep failed")
+		}
+	})
+	a.sched.Every("backup-verify", 7*24*time.Hour, a.verifyAllBackups)
+	a.sched.Every("appstate-backup", 24*time.Hour, a.scheduledAppStateBackup)
+	a.sched.Every("server-update-check", 30*time.Minute, a.checkServerUpdates)
+	a.sched.Every("plugin-catalog-refresh", 6*time.Hour, func() { a.RefreshPluginCatalog() })
 	if err := a.sched.Start(); err != nil {
 		logger.Log.Error().Err(err).Msg("Failed to start scheduler")
 	}
+	go a.RefreshPluginCatalog()
 
 	logger.Log.Info().Str("version", appVersion).Msg("CS2 Admin started")
 }
@@ -119,6 +149,7 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 func (a *App) shutdown(ctx context.Context) {
 	logger.Log.Info().Msg("CS2 Admin shutting down")
 	a.sched.Stop()
+	a.steamJobs.Close()
 	a.instanceMgr.StopAll()
 	a.rconPool.DisconnectAll()
 }
@@ -185,6 +216,13 @@ func (a *App) UpdateAppConfig(cfg config.AppConfig) error {
 	a.cfg.SteamCMDPath = cfg.SteamCMDPath
 	a.cfg.DefaultInstallDir = cfg.DefaultInstallDir
 	a.cfg.BackupDir = cfg.BackupDir
+	a.cfg.AppStateBackupKeep = cfg.AppStateBackupKeep
+	a.cfg.BackupFormat = cfg.BackupFormat
+	a.cfg.BackupCompressionLevel = cfg.BackupCompressionLevel
+	a.cfg.BackupConcurrency = cfg.BackupConcurrency
+	a.cfg.SteamCMDConcurrency = cfg.SteamCMDConcurrency
+	a.steamJobs.SetLimit(cfg.SteamCMDConcurrency)
+	a.cfg.PluginCatalogURL = cfg.PluginCatalogURL
 	if err := a.cfg.Save(); err != nil {
 		logger.Log.Error().Err(err).Msg("Failed to save config")
 		return err
@@ -211,6 +249,9 @@ type InstanceConfig struct {
 	LaunchArgs   string `json:"launch_args"`
 	AutoRestart  bool   `json:"auto_restart"`
 	AutoStart    bool   `json:"auto_start"`
+
+	UpdateOnNewBuild bool `json:"update_on_new_build"`
+	TrackMatches     bool `json:"track_matches"`
 }
 
 // GetInstances returns all server instances.
@@ -256,6 +297,9 @@ func (a *App) CreateInstance(cfg InstanceConfig) (*models.ServerInstance, error)
 		AutoRestart:  cfg.AutoRestart,
 		AutoStart:    cfg.AutoStart,
 		Status:       "stopped",
+
+		UpdateOnNewBuild: cfg.UpdateOnNewBuild,
+		TrackMatches:     cfg.TrackMatches,
 	}
 
 	if inst.Port == 0 {
@@ -291,6 +335,9 @@ func (a *App) UpdateInstance(id string, cfg InstanceConfig) error {
 		"launch_args":  cfg.LaunchArgs,
 		"auto_restart": cfg.AutoRestart,
 		"auto_start":   cfg.AutoStart,
+
+		"update_on_new_build": cfg.UpdateOnNewBuild,
+		"track_matches":       cfg.TrackMatches,
 	}
 	if cfg.InstallPath != "" {
 		updates["install_path"] = cfg.InstallPath
@@ -321,6 +368,14 @@ func (a *App) BrowseDirectory() (string, error) {
 	})
 }
 
+// BrowsePluginPackage opens a native file picker for a plugin zip and returns its path.
+func (a *App) BrowsePluginPackage() (string, error) {
+	return wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title:   "Select Plugin Package",
+		Filters: []wailsruntime.FileFilter{{DisplayName: "Plugin packages (*.zip)", Pattern: "*.zip"}},
+	})
+}
+
 // StartInstance starts a server instance and auto-starts monitoring.
 func (a *App) StartInstance(id string) error {
 	// Ensure the instance has an encrypted RCON password (fix for legacy empty passwords)
@@ -336,6 +391,9 @@ func (a *App) StartInstance(id string) error {
 	if err := a.instanceMgr.Start(id); err != nil {
 		return err
 	}
+	if inst.TrackMatches {
+		a.checkStatsPlugin(&inst)
+	}
 	// Auto-start metrics collector
 	go func() {
 		// Brief delay to allow the server to start listening
@@ -419,14 +477,26 @@ func (a *App) GetServerConfig(instanceID string) (map[string]string, error) {
 	return config.ReadCfgFile(cfgPath)
 }
 
-// UpdateServerConfig writes the server.cfg for an instance.
+// UpdateServerConfig makes the server.cfg for an instance set exactly cvars. Changed
+// cvars are edited in place; comments, exec lines and other commands are kept.
 func (a *App) UpdateServerConfig(instanceID string, cvars map[string]string) error {
+	return a.editServerConfig(instanceID, func(doc *config.CfgDocument) error {
+		return doc.Sync(cvars)
+	})
+}
+
+// editServerConfig applies edit to an instance's server.cfg, creating it if needed.
+func (a *App) editServerConfig(instanceID string, edit func(doc *config.CfgDocument) error) error {
 	inst, err := a.GetInstance(instanceID)
 	if err != nil {
 		return err
 	}
 	cfgPath := filepath.Join(inst.InstallPath, "game", "csgo", "cfg", "server.cfg")
-	return config.WriteCfgFile(cfgPath, cvars)
+	if err := config.EditCfgFile(cfgPath, edit); err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("Failed to update server.cfg")
+		return err
+	}
+	return nil
 }
 
 // GetCvarDatabase returns all known CS2 cvars.
@@ -455,7 +525,20 @@ func (a *App) ApplyGameModePreset(instanceID string, presetName string) error {
 	if preset == nil {
 		return fmt.Errorf("preset not found: %s", presetName)
 	}
-	return a.UpdateServerConfig(instanceID, preset.Cvars)
+	// The preset's cvars are set on top of the rest of server.cfg.
+	return a.editServerConfig(instanceID, func(doc *config.CfgDocument) error {
+		keys := make([]string, 0, len(preset.Cvars))
+		for k := range preset.Cvars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := doc.Set(k, preset.Cvars[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // GetConfigProfiles lists config profiles for an instance.
@@ -518,19 +601,23 @@ func (a *App) ChangeMap(instanceID string, mapName string) error {
 	return err
 }
 
-// DownloadWorkshopMap downloads a workshop map for an instance.
+// DownloadWorkshopMap downloads a workshop map for an instance and waits for it.
+// Progress is reported on the SteamCMD job, kind "workshop".
 func (a *App) DownloadWorkshopMap(instanceID string, workshopID int64) error {
 	inst, err := a.GetInstance(instanceID)
 	if err != nil {
 		return err
 	}
-	progressCh := make(chan steam.Progress, 100)
-	go func() {
-		for p := range progressCh {
-			wailsruntime.EventsEmit(a.ctx, "progress:"+instanceID, p)
-		}
-	}()
-	return a.steamCmd.DownloadWorkshopItem(inst.InstallPath, workshopID, progressCh)
+	_, err = a.steamJobs.Do(steam.JobSpec{
+		Kind:       "workshop",
+		InstanceID: instanceID,
+		Title:      fmt.Sprintf("Download workshop item %d for %s", workshopID, inst.Name),
+		Key:        steamJobKey(inst.InstallPath),
+		Run: func(ctx context.Context, progressCh chan<- steam.Progress, lineFn func(string)) error {
+			return a.steamCmd.DownloadWorkshopItem(ctx, inst.InstallPath, workshopID, progressCh, lineFn)
+		},
+	})
+	return err
 }
 
 // ── Players ───────────────────────────────────────────────────────────
@@ -637,78 +724,619 @@ func (a *App) UpdateBotConfig(instanceID string, cfg BotConfig) error {
 
 // ── SteamCMD ──────────────────────────────────────────────────────────
 
-// InstallCS2Server installs CS2 server files for an instance.
-func (a *App) InstallCS2Server(instanceID string) error {
+// InstallCS2Server queues an install of the CS2 server files for an instance and returns
+// the SteamCMD job ID. Output and progress are emitted under the job ID.
+func (a *App) InstallCS2Server(instanceID string) (string, error) {
 	inst, err := a.GetInstance(instanceID)
 	if err != nil {
-		return err
+		return "", err
+	}
+	// Reinstalling over existing files updates them, and a fresh install gets the branch
+	// head rather than the pinned build, so neither is allowed while pinned.
+	if err := checkNotPinned(inst); err != nil {
+		return "", err
 	}
+	return a.steamJobs.Submit(steam.JobSpec{
+		Kind:       "install",
+		InstanceID: instanceID,
+		Title:      "Install CS2 for " + inst.Name,
+		Key:        steamJobKey(inst.InstallPath),
+		Run: func(ctx context.Context, progressCh chan<- steam.Progress, lineFn func(string)) error {
+			return a.installCS2(ctx, inst, progressCh, lineFn)
+		},
+	}), nil
+}
+
+func (a *App) installCS2(ctx context.Context, inst *models.ServerInstance, progressCh chan<- steam.Progress, lineFn func(string)) error {
+	instanceID := inst.ID.String()
 
 	// Ensure SteamCMD is installed
-	wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, "Checking SteamCMD installation...")
+	lineFn("Checking SteamCMD installation...")
 	if err := a.steamCmd.EnsureInstalled(); err != nil {
+		lineFn("ERROR: " + err.Error())
 		return fmt.Errorf("steamcmd setup: %w", err)
 	}
-	wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, "SteamCMD ready.")
+	lineFn("SteamCMD ready.")
+
+	if err := steam.CheckFreeSpace(inst.InstallPath); err != nil {
+		lineFn("ERROR: " + err.Error())
+		return err
+	}
 
 	// Update status
 	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "installing")
 	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "installing")
 
-	progressCh := make(chan steam.Progress, 100)
-	go func() {
-		for p := range progressCh {
-			wailsruntime.EventsEmit(a.ctx, "progress:"+instanceID, p)
-		}
-	}()
-
-	// Line callback sends raw SteamCMD output to frontend mini-terminal
-	lineFn := func(line string) {
-		wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, line)
+	branch, err := a.instanceBranch(inst)
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
+		return err
 	}
+	lineFn(fmt.Sprintf("Installing CS2 (%s branch) to %s ...", branchName(branch.Name), inst.InstallPath))
 
-	wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, fmt.Sprintf("Installing CS2 to %s ...", inst.InstallPath))
-
-	if err := a.steamCmd.InstallCS2(inst.InstallPath, progressCh, lineFn); err != nil {
+	if err := a.steamCmd.InstallCS2(ctx, inst.InstallPath, branch, progressCh, lineFn); err != nil {
 		a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "stopped")
 		wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "stopped")
-		wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, "ERROR: "+err.Error())
+		lineFn("ERROR: " + err.Error())
 		return err
 	}
 
 	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "stopped")
 	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "stopped")
-	wailsruntime.EventsEmit(a.ctx, "install-line:"+instanceID, "Installation complete!")
+	lineFn("Installation complete!")
 	logger.Log.Info().Str("id", instanceID).Msg("CS2 server installed")
+	a.recordInstalledBuild(instanceID, inst.InstallPath)
+	// Reinstalling over an existing server puts stock files back; a failed patch is reported
+	// in the output and plugin list but doesn't fail the install.
+	applyStockPatches(inst.InstallPath, lineFn)
+
+	// Freshly validated files are the stock baseline for custom-content backups.
+	go a.recordStockBaseline(instanceID, inst.InstallPath)
 	return nil
 }
 
-// UpdateCS2Server updates CS2 server files for an instance.
-func (a *App) UpdateCS2Server(instanceID string) error {
+// UpdateCS2Server queues an update of the CS2 server files for an instance and returns the
+// SteamCMD job ID.
+func (a *App) UpdateCS2Server(instanceID string) (string, error) {
 	inst, err := a.GetInstance(instanceID)
 	if err != nil {
+		return "", err
+	}
+	if err := checkNotPinned(inst); err != nil {
+		return "", err
+	}
+	return a.steamJobs.Submit(steam.JobSpec{
+		Kind:       "update",
+		InstanceID: instanceID,
+		Title:      "Update CS2 for " + inst.Name,
+		Key:        steamJobKey(inst.InstallPath),
+		Run: func(ctx context.Context, progressCh chan<- steam.Progress, lineFn func(string)) error {
+			return a.updateCS2(ctx, inst, progressCh, lineFn)
+		},
+	}), nil
+}
+
+func (a *App) updateCS2(ctx context.Context, inst *models.ServerInstance, progressCh chan<- steam.Progress, lineFn func(string)) error {
+	instanceID := inst.ID.String()
+	if err := steam.CheckFreeSpace(inst.InstallPath); err != nil {
+		lineFn("ERROR: " + err.Error())
+		return err
+	}
+	branch, err := a.instanceBranch(inst)
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
 		return err
 	}
 	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "updating")
 	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "updating")
 
-	progressCh := make(chan steam.Progress, 100)
-	go func() {
-		for p := range progressCh {
-			wailsruntime.EventsEmit(a.ctx, "progress:"+instanceID, p)
+	err = a.steamCmd.UpdateCS2(ctx, inst.InstallPath, branch, progressCh, lineFn)
+	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "stopped")
+	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "stopped")
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
+		return err
+	}
+	a.recordInstalledBuild(instanceID, inst.InstallPath)
+	applyStockPatches(inst.InstallPath, lineFn)
+
+	// The files SteamCMD rewrote are the new stock, so refresh the baseline for
+	// custom-content backups.
+	go a.recordStockBaseline(instanceID, inst.InstallPath)
+	return nil
+}
+
+// applyStockPatches re-applies the stock-file patches the instance needs and reports each
+// one to lineFn.
+func applyStockPatches(installPath string, lineFn func(string)) ([]instance.PatchResult, error) {
+	results, err := instance.ApplyStockPatches(installPath)
+	for _, p := range results {
+		switch {
+		case p.Error != "":
+			lineFn(fmt.Sprintf("ERROR: patch %s failed: %s", p.Name, p.Error))
+		case p.Changed:
+			lineFn("Re-applied patch: " + p.Name)
 		}
-	}()
+	}
+	return results, err
+}
 
-	if err := a.steamCmd.UpdateCS2(inst.InstallPath, progressCh); err != nil {
-		a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "stopped")
+// RepairReport describes what a verify-and-repair run changed.
+type RepairReport struct {
+	InstanceID string                 `json:"instance_id"`
+	BuildID    string                 `json:"build_id"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Repaired   []string               `json:"repaired"` // stock files SteamCMD found damaged and re-downloaded
+	Restored   []string               `json:"restored"` // stock files that were missing
+	Reverted   []string               `json:"reverted"` // stock files you had modified, now back to stock
+	Patches    []instance.PatchResult `json:"patches"`  // required patches re-applied afterwards
+}
+
+// RepairCS2Server queues a verify-and-repair of an instance's CS2 files and returns the
+// SteamCMD job ID. The report is emitted as "repair-report:<id>" and kept for GetRepairReport.
+func (a *App) RepairCS2Server(instanceID string) (string, error) {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return "", err
+	}
+	if status := a.instanceMgr.GetStatus(instanceID); status == "running" || status == "starting" {
+		return "", fmt.Errorf("stop the server before repairing it")
+	}
+	// Validating installs the branch head, so a pinned instance can only be repaired while
+	// it's still on the head.
+	if inst.PinnedBuildID != "" && (inst.LatestBuildID == "" || inst.BuildID != inst.LatestBuildID) {
+		return "", fmt.Errorf("%s is pinned to build %s and the branch has moved on; validating would update it", inst.Name, inst.PinnedBuildID)
+	}
+	return a.steamJobs.Submit(steam.JobSpec{
+		Kind:       "validate",
+		InstanceID: instanceID,
+		Title:      "Verify and repair CS2 for " + inst.Name,
+		Key:        steamJobKey(inst.InstallPath),
+		Run: func(ctx context.Context, progressCh chan<- steam.Progress, lineFn func(string)) error {
+			return a.repairCS2(ctx, inst, progressCh, lineFn)
+		},
+	}), nil
+}
+
+func (a *App) repairCS2(ctx context.Context, inst *models.ServerInstance, progressCh chan<- steam.Progress, lineFn func(string)) error {
+	instanceID := inst.ID.String()
+	report := &RepairReport{InstanceID: instanceID, StartedAt: time.Now()}
+	branch, err := a.instanceBranch(inst)
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
 		return err
 	}
 
+	lineFn("Scanning installed files...")
+	before, err := steam.SnapshotFiles(inst.InstallPath)
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
+		return fmt.Errorf("scan install: %w", err)
+	}
+	// The baseline tells us which stock files were modified, as long as it's for this build.
+	var base *backup.Baseline
+	if m, err := steam.ReadAppManifest(inst.InstallPath); err == nil {
+		if b, err := backup.LoadBaseline(a.backupDir(), instanceID); err == nil && b.BuildID == m.BuildID {
+			base = b
+		}
+	}
+
+	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "updating")
+	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "updating")
+	lineFn("Validating CS2 files...")
+	err = a.steamCmd.ValidateCS2(ctx, inst.InstallPath, branch, progressCh, lineFn)
 	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("status", "stopped")
 	wailsruntime.EventsEmit(a.ctx, "status:"+instanceID, "stopped")
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
+		return err
+	}
+
+	after, err := steam.SnapshotFiles(inst.InstallPath)
+	if err != nil {
+		lineFn("ERROR: " + err.Error())
+		return fmt.Errorf("scan install: %w", err)
+	}
+	diff := steam.DiffSnapshots(before, after)
+	patched := make(map[string]bool)
+	for _, f := range instance.StockPatchFiles() {
+		patched[f] = true
+	}
+	for _, rel := range diff.Changed {
+		if steam.IsLogFile(rel) {
+			continue
+		}
+		modified := patched[rel]
+		if base != nil {
+			if known, ok := base.Files[rel]; ok {
+				b := before[rel]
+				modified = modified || known.Size != b.Size || !known.ModTime.Equal(b.ModTime)
+			}
+		}
+		if modified {
+			report.Reverted = append(report.Reverted, rel)
+		} else {
+			report.Repaired = append(report.Repaired, rel)
+		}
+	}
+	for _, rel := range diff.Added {
+		if !steam.IsLogFile(rel) {
+			report.Restored = append(report.Restored, rel)
+		}
+	}
+	a.recordInstalledBuild(instanceID, inst.InstallPath)
+	if m, err := steam.ReadAppManifest(inst.InstallPath); err == nil {
+		report.BuildID = m.BuildID
+	}
+
+	lineFn(fmt.Sprintf("Repaired %d file(s), restored %d missing file(s).", len(report.Repaired), len(report.Restored)))
+	if len(report.Reverted) > 0 {
+		lineFn("WARNING: these modified stock files were reverted to stock:")
+		for _, rel := range report.Reverted {
+			lineFn("  " + rel)
+		}
+	}
+	report.Patches, err = applyStockPatches(inst.InstallPath, lineFn)
+	report.FinishedAt = time.Now()
+
+	if err := a.saveRepairReport(report); err != nil {
+		logger.Log.Warn().Err(err).Str("instance", instanceID).Msg("Save repair report failed")
+	}
+	wailsruntime.EventsEmit(a.ctx, "repair-report:"+instanceID, report)
+	logger.Log.Info().
+		Str("instance", instanceID).
+		Int("repaired", len(report.Repaired)).
+		Int("restored", len(report.Restored)).
+		Int("reverted", len(report.Reverted)).
+		Msg("CS2 server repaired")
+
+	// Files are stock again (plus patches), so refresh the baseline for custom-content backups.
+	go a.recordStockBaseline(instanceID, inst.InstallPath)
+	return err
+}
+
+func (a *App) repairReportPath(instanceID string) string {
+	return filepath.Join(a.cfg.AppDataDir, "repair-reports", instanceID+".json")
+}
+
+func (a *App) saveRepairReport(r *RepairReport) error {
+	p := a.repairReportPath(r.InstanceID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+// GetRepairReport returns the report of the instance's last verify-and-repair, or nil.
+func (a *App) GetRepairReport(instanceID string) (*RepairReport, error) {
+	data, err := os.ReadFile(a.repairReportPath(instanceID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r RepairReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse repair report: %w", err)
+	}
+	return &r, nil
+}
+
+// GetSteamCMDJobs lists queued, running and recently finished SteamCMD jobs.
+func (a *App) GetSteamCMDJobs() []steam.Job {
+	return a.steamJobs.List()
+}
+
+// GetSteamCMDJob returns a SteamCMD job with its recent output.
+func (a *App) GetSteamCMDJob(jobID string) (*steam.Job, error) {
+	j, ok := a.steamJobs.Get(jobID)
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", jobID)
+	}
+	return j, nil
+}
+
+// CancelSteamCMDJob cancels a queued job or kills the SteamCMD process of a running one.
+func (a *App) CancelSteamCMDJob(jobID string) error {
+	if err := a.steamJobs.Cancel(jobID); err != nil {
+		logger.Log.Error().Err(err).Str("job", jobID).Msg("CancelSteamCMDJob failed")
+		return err
+	}
 	return nil
 }
 
+// steamJobActive reports whether a job of kind is queued or running for an instance.
+func (a *App) steamJobActive(instanceID, kind string) bool {
+	for _, j := range a.steamJobs.List() {
+		if j.InstanceID == instanceID && j.Kind == kind && (j.Status == steam.JobQueued || j.Status == steam.JobRunning) {
+			return true
+		}
+	}
+	return false
+}
+
+// steamJobKey keys SteamCMD jobs by install dir so two jobs never write the same files.
+func steamJobKey(installPath string) string {
+	if abs, err := filepath.Abs(installPath); err == nil {
+		installPath = abs
+	}
+	return strings.ToLower(filepath.Clean(installPath))
+}
+
+// BuildStatus compares an instance's installed CS2 build with the head of its branch on Steam.
+type BuildStatus struct {
+	InstanceID      string     `json:"instance_id"`
+	Branch          string     `json:"branch"`
+	BuildID         string     `json:"build_id"`
+	LatestBuildID   string     `json:"latest_build_id"`
+	PinnedBuildID   string     `json:"pinned_build_id"`
+	UpdateAvailable bool       `json:"update_available"`
+	CheckedAt       *time.Time `json:"checked_at"`
+}
+
+func buildStatus(inst *models.ServerInstance) BuildStatus {
+	return BuildStatus{
+		InstanceID:      inst.ID.String(),
+		Branch:          branchName(inst.Branch),
+		BuildID:         inst.BuildID,
+		LatestBuildID:   inst.LatestBuildID,
+		PinnedBuildID:   inst.PinnedBuildID,
+		UpdateAvailable: inst.BuildID != "" && inst.LatestBuildID != "" && inst.BuildID != inst.LatestBuildID,
+		CheckedAt:       inst.BuildCheckedAt,
+	}
+}
+
+func branchName(branch string) string {
+	if branch == "" {
+		return steam.BranchPublic
+	}
+	return branch
+}
+
+// GetBuildStatus returns the last known build status of an instance without asking Steam.
+func (a *App) GetBuildStatus(instanceID string) (*BuildStatus, error) {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	st := buildStatus(inst)
+	return &st, nil
+}
+
+// CheckForServerUpdates asks Steam for the head build of every branch in use and compares
+// each installed instance with its branch. Unpinned instances with UpdateOnNewBuild are
+// updated when they're behind.
+func (a *App) CheckForServerUpdates() ([]BuildStatus, error) {
+	var insts []models.ServerInstance
+	if err := a.db.Order("created_at asc").Find(&insts).Error; err != nil {
+		return nil, err
+	}
+	// Installed builds come from disk; skip asking Steam when nothing is installed yet.
+	installed := insts[:0]
+	for _, inst := range insts {
+		if m, err := steam.ReadAppManifest(inst.InstallPath); err == nil {
+			inst.BuildID = m.BuildID
+			installed = append(installed, inst)
+		}
+	}
+	if len(installed) == 0 {
+		return nil, nil
+	}
+
+	latest := make(map[string]*steam.BuildInfo)
+	var lastErr error
+	checked := false
+	for _, inst := range installed {
+		branch := branchName(inst.Branch)
+		if _, done := latest[branch]; done {
+			continue
+		}
+		var info *steam.BuildInfo
+		_, err := a.steamJobs.Do(steam.JobSpec{
+			Kind:  "app_info",
+			Title: "Check for CS2 updates (" + branch + ")",
+			Run: func(ctx context.Context, _ chan<- steam.Progress, _ func(string)) error {
+				var err error
+				info, err = a.steamCmd.LatestBuild(ctx, branch)
+				return err
+			},
+		})
+		if err != nil {
+			// Password-protected betas may not be listed for anonymous users; keep going.
+			logger.Log.Error().Err(err).Str("branch", branch).Msg("CheckForServerUpdates failed")
+			lastErr = err
+		} else {
+			checked = true
+		}
+		latest[branch] = info
+	}
+
+	now := time.Now()
+	out := make([]BuildStatus, 0, len(installed))
+	for i := range installed {
+		inst := &installed[i]
+		updates := map[string]interface{}{"build_id": inst.BuildID}
+		if info := latest[branchName(inst.Branch)]; info != nil {
+			inst.LatestBuildID = info.BuildID
+			inst.BuildCheckedAt = &now
+			updates["latest_build_id"] = inst.LatestBuildID
+			updates["build_checked_at"] = inst.BuildCheckedAt
+		}
+		if err := a.db.Model(&models.ServerInstance{}).Where("id = ?", inst.ID).Updates(updates).Error; err != nil {
+			logger.Log.Error().Err(err).Str("instance", inst.ID.String()).Msg("Save build status failed")
+		}
+
+		st := buildStatus(inst)
+		out = append(out, st)
+		if !st.UpdateAvailable {
+			continue
+		}
+		if st.PinnedBuildID != "" {
+			logger.Log.Warn().
+				Str("instance", st.InstanceID).
+				Str("pinned", st.PinnedBuildID).
+				Str("latest", st.LatestBuildID).
+				Msg("Pinned CS2 instance is behind its branch")
+		} else {
+			logger.Log.Info().
+				Str("instance", st.InstanceID).
+				Str("installed", st.BuildID).
+				Str("latest", st.LatestBuildID).
+				Msg("CS2 update available")
+		}
+		wailsruntime.EventsEmit(a.ctx, "update-available:"+st.InstanceID, st)
+
+		if inst.UpdateOnNewBuild && st.PinnedBuildID == "" &&
+			!a.steamJobActive(st.InstanceID, "update") && !a.steamJobActive(st.InstanceID, "install") {
+			go a.runServerUpdate(st.InstanceID)
+		}
+	}
+	if !checked {
+		return out, lastErr
+	}
+	return out, nil
+}
+
+// BranchConfig selects the Steam branch an instance installs from and optionally pins it.
+type BranchConfig struct {
+	Branch        string `json:"branch"`          // empty or "public" for the public branch
+	BetaPassword  string `json:"beta_password"`   // empty keeps the saved password
+	PinnedBuildID string `json:"pinned_build_id"` // the installed build ID; empty unpins
+}
+
+var branchNameRe = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// SetInstanceBranch changes an instance's branch and build pin. The new branch takes effect
+// on the next install or update. SteamCMD's app_update only installs a branch's head, so an
+// instance can only be pinned to the build it has installed.
+func (a *App) SetInstanceBranch(instanceID string, cfg BranchConfig) error {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	branch := strings.TrimSpace(cfg.Branch)
+	if branch == steam.BranchPublic {
+		branch = ""
+	}
+	if branch != "" && !branchNameRe.MatchString(branch) {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	pin := strings.TrimSpace(cfg.PinnedBuildID)
+	switch {
+	case pin == "" || pin == inst.BuildID:
+	case inst.BuildID == "":
+		return fmt.Errorf("install the server before pinning a build")
+	default:
+		return fmt.Errorf("%s has build %s installed; only the installed build can be pinned", inst.Name, inst.BuildID)
+	}
+
+	updates := map[string]interface{}{
+		"branch":          branch,
+		"pinned_build_id": pin,
+	}
+	switch {
+	case branch == "":
+		updates["beta_password"] = ""
+	case cfg.BetaPassword != "":
+		enc, err := crypto.Encrypt(cfg.BetaPassword, a.encKey)
+		if err != nil {
+			return err
+		}
+		updates["beta_password"] = enc
+	}
+	if branch != inst.Branch {
+		// The last check was against another branch's head.
+		updates["latest_build_id"] = ""
+		updates["build_checked_at"] = nil
+	}
+	if err := a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Updates(updates).Error; err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("SetInstanceBranch failed")
+		return err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("branch", branchName(branch)).Str("pinned", pin).Msg("Instance branch updated")
+	return nil
+}
+
+// instanceBranch returns the branch SteamCMD should install for inst.
+func (a *App) instanceBranch(inst *models.ServerInstance) (steam.Branch, error) {
+	b := steam.Branch{Name: inst.Branch}
+	if inst.BetaPassword != "" {
+		pw, err := crypto.Decrypt(inst.BetaPassword, a.encKey)
+		if err != nil {
+			return b, fmt.Errorf("decrypt beta password: %w", err)
+		}
+		b.Password = pw
+	}
+	return b, nil
+}
+
+// checkNotPinned refuses SteamCMD work that would move a pinned instance off its build.
+func checkNotPinned(inst *models.ServerInstance) error {
+	if inst.PinnedBuildID == "" {
+		return nil
+	}
+	return fmt.Errorf("%s is pinned to build %s; unpin it to install or update", inst.Name, inst.PinnedBuildID)
+}
+
+func (a *App) checkServerUpdates() {
+	a.CheckForServerUpdates()
+}
+
+// runServerUpdate is the update workflow used by scheduled and automatic updates: a running
+// server is warned, stopped, updated and started again.
+func (a *App) runServerUpdate(instanceID string) error {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	// Check the pin before stopping anything; scheduled updates of pinned instances are skipped.
+	if err := checkNotPinned(inst); err != nil {
+		logger.Log.Info().Str("instance", instanceID).Msg("Skipping update of pinned instance")
+		return err
+	}
+
+	status := a.instanceMgr.GetStatus(instanceID)
+	wasRunning := status == "running" || status == "starting"
+	if wasRunning {
+		_, _ = a.SendRCON(instanceID, "say Server is restarting for a CS2 update")
+		if err := a.StopInstance(instanceID); err != nil {
+			logger.Log.Error().Err(err).Str("instance", instanceID).Msg("Stop for update failed")
+			return err
+		}
+	}
+
+	jobID, err := a.UpdateCS2Server(instanceID)
+	if err == nil {
+		err = a.steamJobs.Wait(jobID)
+	}
+	if err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("Server update failed")
+	}
+
+	if wasRunning {
+		if startErr := a.StartInstance(instanceID); startErr != nil {
+			logger.Log.Error().Err(startErr).Str("instance", instanceID).Msg("Restart after update failed")
+		}
+	}
+	return err
+}
+
+// recordInstalledBuild stores the build SteamCMD just installed, read from the app manifest.
+func (a *App) recordInstalledBuild(instanceID, installPath string) {
+	m, err := steam.ReadAppManifest(installPath)
+	if err != nil {
+		logger.Log.Warn().Err(err).Str("instance", instanceID).Msg("Read app manifest failed")
+		return
+	}
+	a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("build_id", m.BuildID)
+}
+
 // ── Skins ─────────────────────────────────────────────────────────────
 
 // GetSkins returns skins filtered by rarity, weaponType, and search (LIKE on name).
@@ -847,45 +1475,425 @@ func (a *App) GetPlugins(instanceID string) ([]instance.PluginInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	plugins, err := instance.GetInstalledPlugins(inst.InstallPath)
+	cat, err := instance.LoadCatalog(a.cfg.AppDataDir)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("GetPlugins failed")
+		return nil, err
+	}
+	plugins, err := instance.GetInstalledPlugins(inst.InstallPath, cat)
 	if err != nil {
 		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("GetPlugins failed")
 		return nil, err
 	}
+	if a.instanceMgr.GetStatus(instanceID) == "running" {
+		loaded, err := a.GetLoadedPlugins(instanceID)
+		if err != nil {
+			// The on-disk view is still useful without the live one.
+			logger.Log.Debug().Err(err).Str("instance", instanceID).Msg("GetPlugins: live plugin state unavailable")
+		} else {
+			plugins = instance.MergeLoadedPlugins(plugins, loaded)
+		}
+	}
 	return plugins, nil
 }
 
-// InstallPlugin installs Metamod, CounterStrikeSharp, or WeaponPaints by name.
-func (a *App) InstallPlugin(instanceID string, pluginName string) error {
-	inst, err := a.GetInstance(instanceID)
+// GetLoadedPlugins returns the plugins CounterStrikeSharp has loaded on a running instance.
+func (a *App) GetLoadedPlugins(instanceID string) ([]instance.LoadedPlugin, error) {
+	out, err := a.SendRCON(instanceID, "css_plugins list")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	pluginName = strings.ToLower(strings.TrimSpace(pluginName))
-	switch pluginName {
-	case "metamod", "metamod:source":
-		if err := instance.InstallMetamod(inst.InstallPath); err != nil {
-			logger.Log.Error().Err(err).Str("plugin", "Metamod").Msg("InstallPlugin failed")
-			return err
-		}
-	case "counterstrikesharp", "cssharp", "css":
-		if err := instance.InstallCounterStrikeSharp(inst.InstallPath); err != nil {
-			logger.Log.Error().Err(err).Str("plugin", "CounterStrikeSharp").Msg("InstallPlugin failed")
-			return err
-		}
-	case "weaponpaints":
-		if err := instance.InstallWeaponPaints(inst.InstallPath); err != nil {
-			logger.Log.Error().Err(err).Str("plugin", "WeaponPaints").Msg("InstallPlugin failed")
-			return err
-		}
-	default:
-		return fmt.Errorf("unknown plugin: %s (use: Metamod, CounterStrikeSharp, WeaponPaints)", pluginName)
+	if err := instance.CSSCommandError(out); err != nil {
+		return nil, err
 	}
-	logger.Log.Info().Str("instance", instanceID).Str("plugin", pluginName).Msg("plugin installed")
-	return nil
+	return instance.ParseCSSPluginList(out), nil
 }
 
-// ── Match Stats ────────────────────────────────────────────────────────
+// LoadPlugin loads a CounterStrikeSharp plugin, by its directory name, on a running instance.
+func (a *App) LoadPlugin(instanceID string, name string) error {
+	return a.cssPluginAction(instanceID, "load", name)
+}
+
+// UnloadPlugin unloads a CounterStrikeSharp plugin, by name or list ID, on a running instance.
+func (a *App) UnloadPlugin(instanceID string, name string) error {
+	return a.cssPluginAction(instanceID, "unload", name)
+}
+
+// ReloadPlugin reloads a CounterStrikeSharp plugin, by name or list ID, on a running instance,
+// e.g. to pick up an edited config.
+func (a *App) ReloadPlugin(instanceID string, name string) error {
+	return a.cssPluginAction(instanceID, "reload", name)
+}
+
+func (a *App) cssPluginAction(instanceID, action, name string) error {
+	if a.instanceMgr.GetStatus(instanceID) != "running" {
+		return fmt.Errorf("the server must be running to %s plugins", action)
+	}
+	cmd, err := instance.CSSPluginCommand(action, name)
+	if err != nil {
+		return err
+	}
+	out, err := a.SendRCON(instanceID, cmd)
+	if err == nil {
+		err = instance.CSSCommandError(out)
+	}
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", name).Str("action", action).Msg("css_plugins command failed")
+		return err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("plugin", name).Str("action", action).Msg("plugin command sent")
+	return nil
+}
+
+// CheckPluginUpdates returns the installed plugins with the version the catalog would
+// install for each, flagging those with an update available. It looks up GitHub and other
+// sources for plugins that aren't pinned.
+func (a *App) CheckPluginUpdates(instanceID string) ([]instance.PluginInfo, error) {
+	plugins, err := a.GetPlugins(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	cat, err := instance.LoadCatalog(a.cfg.AppDataDir)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("CheckPluginUpdates failed")
+		return nil, err
+	}
+	cat.CheckUpdates(plugins)
+	return plugins, nil
+}
+
+// InstallPlugin installs a catalog plugin by ID, name or alias, installing missing
+// dependencies first, then re-applies the stock-file patches plugins need.
+func (a *App) InstallPlugin(instanceID string, pluginName string) error {
+	inst, cat, err := a.pluginTarget(instanceID, "installing a plugin")
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("InstallPlugin failed")
+		return err
+	}
+	installed, err := cat.InstallPlugin(inst.InstallPath, pluginName)
+	for _, r := range installed {
+		logger.Log.Info().Str("instance", instanceID).Str("plugin", r.ID).Str("version", r.Version).Msg("plugin installed")
+	}
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Msg("InstallPlugin failed")
+		return err
+	}
+	if _, err := instance.ApplyStockPatches(inst.InstallPath); err != nil {
+		return fmt.Errorf("plugin installed but stock files could not be patched: %w", err)
+	}
+	return nil
+}
+
+// GetBundledPlugins returns CS2Admin's own plugins as installed on an instance, flagging
+// those whose installed build differs from the one shipped with the app.
+func (a *App) GetBundledPlugins(instanceID string) ([]instance.BundledPluginStatus, error) {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return instance.BundledStatus(inst.InstallPath), nil
+}
+
+// InstallBundledPlugin installs or upgrades one of CS2Admin's own plugins from the build
+// shipped with the app, installing CounterStrikeSharp first if it is missing. Installing
+// the skins plugin also exports the skin database for it.
+func (a *App) InstallBundledPlugin(instanceID string, name string) error {
+	inst, cat, err := a.pluginTarget(instanceID, "installing a plugin")
+	if err != nil {
+		return err
+	}
+	if css, ok := cat.Lookup("counterstrikesharp"); ok && !css.Installed(inst.InstallPath) {
+		if _, err := cat.InstallPlugin(inst.InstallPath, css.ID); err != nil {
+			logger.Log.Error().Err(err).Str("plugin", name).Msg("InstallBundledPlugin failed")
+			return fmt.Errorf("install CounterStrikeSharp: %w", err)
+		}
+		if _, err := instance.ApplyStockPatches(inst.InstallPath); err != nil {
+			return fmt.Errorf("CounterStrikeSharp installed but stock files could not be patched: %w", err)
+		}
+	}
+	m, err := instance.InstallBundledPlugin(inst.InstallPath, name)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", name).Msg("InstallBundledPlugin failed")
+		return err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("plugin", m.Name).Str("version", m.Version).Msg("bundled plugin installed")
+	if m.Name == instance.SkinsPlugin {
+		var count int64
+		a.db.Model(&models.Skin{}).Count(&count)
+		if count > 0 {
+			return a.ExportSkinDatabaseJSON(instanceID)
+		}
+	}
+	return nil
+}
+
+// SetMatchTracking turns match tracking through the CS2AdminStats plugin on or off.
+func (a *App) SetMatchTracking(instanceID string, enabled bool) error {
+	return a.db.Model(&models.ServerInstance{}).Where("id = ?", instanceID).Update("track_matches", enabled).Error
+}
+
+// checkStatsPlugin warns, in the log and on "instance-warning:<id>", when an instance that
+// tracks matches is missing the stats plugin or runs a different build than the app's.
+func (a *App) checkStatsPlugin(inst *models.ServerInstance) {
+	for _, st := range instance.BundledStatus(inst.InstallPath) {
+		if st.Name != instance.StatsPlugin {
+			continue
+		}
+		var msg string
+		switch {
+		case !st.Installed:
+			msg = "Match tracking is on but the CS2AdminStats plugin isn't installed, so matches won't be recorded. Install it from the Plugins tab."
+		case !st.Enabled:
+			msg = "Match tracking is on but the CS2AdminStats plugin is disabled, so matches won't be recorded."
+		case st.Mismatch:
+			msg = fmt.Sprintf("CS2AdminStats %s is installed but this version of CS2 Admin ships %s. Update it from the Plugins tab.", st.InstalledVersion, st.Version)
+		}
+		if msg != "" {
+			logger.Log.Warn().Str("instance", inst.ID.String()).Str("version", st.InstalledVersion).Msg("stats plugin check: " + msg)
+			wailsruntime.EventsEmit(a.ctx, "instance-warning:"+inst.ID.String(), msg)
+		}
+	}
+}
+
+// PluginPackageResult is the outcome of installing a local plugin package on one instance.
+type PluginPackageResult struct {
+	InstanceID   string `json:"instance_id"`
+	InstanceName string `json:"instance_name"`
+	Plugin       string `json:"plugin"`
+	Version      string `json:"version,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// InstallPluginFromFile installs a plugin package zip from disk.
+func (a *App) InstallPluginFromFile(instanceID string, zipPath string) error {
+	return a.installPluginPackageOne(instanceID, zipPath)
+}
+
+// InstallPluginFromFolder installs a plugin from a folder, such as a plugin's build output.
+func (a *App) InstallPluginFromFolder(instanceID string, dir string) error {
+	return a.installPluginPackageOne(instanceID, dir)
+}
+
+func (a *App) installPluginPackageOne(instanceID, src string) error {
+	results, err := a.InstallPluginPackage([]string{instanceID}, src)
+	if err != nil {
+		return err
+	}
+	if results[0].Error != "" {
+		return errors.New(results[0].Error)
+	}
+	return nil
+}
+
+// InstallPluginPackage installs a plugin package, a zip or a folder, on each of the given
+// instances. The package layout is detected once; each instance gets an install manifest
+// and keeps the version it replaces for rollback. Per-instance failures are reported in the
+// results rather than stopping the others.
+func (a *App) InstallPluginPackage(instanceIDs []string, src string) ([]PluginPackageResult, error) {
+	pkg, err := instance.OpenLocalPackage(src)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("source", src).Msg("InstallPluginPackage failed")
+		return nil, err
+	}
+	defer pkg.Close()
+
+	results := make([]PluginPackageResult, 0, len(instanceIDs))
+	for _, id := range instanceIDs {
+		res := PluginPackageResult{InstanceID: id, Plugin: pkg.Name}
+		err := func() error {
+			if inst, err := a.GetInstance(id); err == nil {
+				res.InstanceName = inst.Name
+			}
+			inst, _, err := a.pluginTarget(id, "installing a plugin")
+			if err != nil {
+				return err
+			}
+			m, err := pkg.Install(inst.InstallPath)
+			if err != nil {
+				return err
+			}
+			res.Version = m.Version
+			if _, err := instance.ApplyStockPatches(inst.InstallPath); err != nil {
+				return fmt.Errorf("plugin installed but stock files could not be patched: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			res.Error = err.Error()
+			logger.Log.Error().Err(err).Str("instance", id).Str("plugin", pkg.Name).Msg("InstallPluginPackage failed")
+		} else {
+			logger.Log.Info().Str("instance", id).Str("plugin", pkg.Name).Str("version", res.Version).Str("source", src).Msg("plugin installed from package")
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// UninstallPlugin removes the files a plugin installed, leaving any modified since, and
+// returns what was removed and kept.
+func (a *App) UninstallPlugin(instanceID string, pluginName string) (*instance.UninstallResult, error) {
+	inst, cat, err := a.pluginTarget(instanceID, "uninstalling a plugin")
+	if err != nil {
+		return nil, err
+	}
+	res, err := cat.Uninstall(inst.InstallPath, pluginName)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Msg("UninstallPlugin failed")
+		return nil, err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("plugin", pluginName).Int("removed", res.Removed).Int("kept", len(res.Kept)).Msg("plugin uninstalled")
+	return res, nil
+}
+
+// RollbackPlugin restores the plugin version kept by its last update. Rolling back again
+// returns to the newer version.
+func (a *App) RollbackPlugin(instanceID string, pluginName string) error {
+	inst, cat, err := a.pluginTarget(instanceID, "rolling back a plugin")
+	if err != nil {
+		return err
+	}
+	m, err := instance.RollbackPlugin(inst.InstallPath, cat.ManifestID(pluginName))
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Msg("RollbackPlugin failed")
+		return err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("plugin", pluginName).Str("version", m.Version).Msg("plugin rolled back")
+	return nil
+}
+
+// SetPluginEnabled enables or disables a CounterStrikeSharp plugin by moving it in or out of
+// the plugins/disabled folder.
+func (a *App) SetPluginEnabled(instanceID string, pluginName string, enabled bool) error {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	cat, err := instance.LoadCatalog(a.cfg.AppDataDir)
+	if err != nil {
+		return err
+	}
+	dir, err := cat.PluginDir(pluginName)
+	if err != nil {
+		return err
+	}
+	if err := instance.SetPluginEnabled(inst.InstallPath, dir, enabled); err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Msg("SetPluginEnabled failed")
+		return err
+	}
+	return nil
+}
+
+// GetPluginConfigs lists a plugin's config files.
+func (a *App) GetPluginConfigs(instanceID string, pluginName string) ([]instance.PluginConfigFile, error) {
+	inst, cat, err := a.pluginCatalog(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return cat.PluginConfigs(inst.InstallPath, pluginName)
+}
+
+// ReadPluginConfig returns one of a plugin's config files with the schema edits are checked
+// against.
+func (a *App) ReadPluginConfig(instanceID string, pluginName string, path string) (*instance.PluginConfig, error) {
+	inst, cat, err := a.pluginCatalog(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := cat.ReadPluginConfig(inst.InstallPath, pluginName, path)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Str("path", path).Msg("ReadPluginConfig failed")
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ValidatePluginConfig returns the problems with content as a new version of a config file.
+func (a *App) ValidatePluginConfig(instanceID string, pluginName string, path string, content string) ([]string, error) {
+	inst, cat, err := a.pluginCatalog(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return cat.ValidatePluginConfig(inst.InstallPath, pluginName, path, content)
+}
+
+// SavePluginConfig validates and writes a plugin config file, keeping a backup of the old
+// one. With reload set, a CounterStrikeSharp plugin loaded on the running server is reloaded
+// so the change takes effect.
+func (a *App) SavePluginConfig(instanceID string, pluginName string, path string, content string, reload bool) error {
+	inst, cat, err := a.pluginCatalog(instanceID)
+	if err != nil {
+		return err
+	}
+	if err := cat.SavePluginConfig(inst.InstallPath, pluginName, path, content); err != nil {
+		logger.Log.Error().Err(err).Str("plugin", pluginName).Str("path", path).Msg("SavePluginConfig failed")
+		return err
+	}
+	logger.Log.Info().Str("instance", instanceID).Str("plugin", pluginName).Str("path", path).Msg("plugin config saved")
+	if !reload || a.instanceMgr.GetStatus(instanceID) != "running" {
+		return nil
+	}
+	plugins, err := a.GetPlugins(instanceID)
+	if err != nil {
+		return fmt.Errorf("config saved, but the plugin could not be reloaded: %w", err)
+	}
+	for _, p := range plugins {
+		if (strings.EqualFold(p.Name, pluginName) || strings.EqualFold(p.CatalogID, pluginName)) && p.LoadState == "loaded" {
+			if err := a.ReloadPlugin(instanceID, strconv.Itoa(p.LoadedID)); err != nil {
+				return fmt.Errorf("config saved, but the plugin could not be reloaded: %w", err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// pluginCatalog loads the instance and the plugin catalog.
+func (a *App) pluginCatalog(instanceID string) (*models.ServerInstance, *instance.Catalog, error) {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cat, err := instance.LoadCatalog(a.cfg.AppDataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, cat, nil
+}
+
+// pluginTarget loads the instance and plugin catalog for an operation that replaces or
+// removes plugin files. A running server holds its plugin DLLs open, and on Windows they
+// are locked, so a commit would fail partway.
+func (a *App) pluginTarget(instanceID, what string) (*models.ServerInstance, *instance.Catalog, error) {
+	if status := a.instanceMgr.GetStatus(instanceID); status == "running" || status == "starting" {
+		return nil, nil, fmt.Errorf("stop the server before %s", what)
+	}
+	return a.pluginCatalog(instanceID)
+}
+
+// GetPluginCatalog returns the installable plugins: the built-in catalog with the remote
+// index and local catalog file applied.
+func (a *App) GetPluginCatalog() ([]instance.CatalogPlugin, error) {
+	cat, err := instance.LoadCatalog(a.cfg.AppDataDir)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("GetPluginCatalog failed")
+		return nil, err
+	}
+	return cat.List(), nil
+}
+
+// RefreshPluginCatalog downloads the remote plugin index, if one is configured.
+func (a *App) RefreshPluginCatalog() error {
+	if a.cfg.PluginCatalogURL == "" {
+		return nil
+	}
+	if err := instance.FetchRemoteCatalog(a.cfg.PluginCatalogURL, a.cfg.AppDataDir); err != nil {
+		logger.Log.Error().Err(err).Msg("RefreshPluginCatalog failed")
+		return err
+	}
+	return nil
+}
+
+// ── Match Stats ────────────────────────────────────────────────────────
 
 // GetMatches returns matches for an instance.
 func (a *App) GetMatches(instanceID string) ([]models.Match, error) {
@@ -1053,37 +2061,188 @@ func (a *App) CreateBackup(instanceID string, backupType string) (*models.Backup
 	if err != nil {
 		return nil, err
 	}
-	backupDir := a.cfg.BackupDir
-	if backupDir == "" && a.cfg.AppDataDir != "" {
-		backupDir = filepath.Join(a.cfg.AppDataDir, "backups")
-	}
 	bType := backup.BackupType(backupType)
-	if bType != backup.BackupFull && bType != backup.BackupConfigOnly && bType != backup.BackupMapsOnly && bType != backup.BackupPluginsOnly {
+	switch bType {
+	case backup.BackupFull, backup.BackupConfigOnly, backup.BackupMapsOnly, backup.BackupPluginsOnly, backup.BackupCustomOnly:
+	default:
 		bType = backup.BackupFull
 	}
-	b, err := backup.Create(a.db, instanceID, inst.InstallPath, backupDir, bType)
+	passphrase, err := a.backupPassphrase()
+	if err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("CreateBackup failed")
+		return nil, err
+	}
+	// Back up a live server gently: lower priority and fewer compression threads.
+	status := a.instanceMgr.GetStatus(instanceID)
+	b, err := backup.Create(a.db, instanceID, inst.InstallPath, a.backupDir(), bType, backup.CreateOptions{
+		Passphrase:  passphrase,
+		Format:      a.cfg.BackupFormat,
+		Level:       a.cfg.BackupCompressionLevel,
+		Concurrency: a.cfg.BackupConcurrency,
+		LowPriority: status == "running" || status == "starting",
+		Progress: func(p backup.Progress) {
+			wailsruntime.EventsEmit(a.ctx, "backup-progress:"+instanceID, p)
+		},
+	})
 	if err != nil {
 		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("CreateBackup failed")
 		return nil, err
 	}
+	if _, err := backup.ApplyRetention(a.db, instanceID, string(bType), false); err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("Backup retention failed")
+	}
+	go a.autoUploadBackup(b.ID.String())
 	return b, nil
 }
 
-// RestoreBackup restores a backup by ID.
+// RestoreBackup restores a backup by ID. The instance must be stopped; files the restore
+// overwrites are first saved in a pre_restore backup.
 func (a *App) RestoreBackup(backupID string) error {
+	passphrase, _ := a.backupPassphrase()
+	_, err := a.restoreBackup(backupID, nil, false, passphrase)
+	return err
+}
+
+// RestoreBackupPaths restores only the given files or directories from a backup. With
+// stopIfRunning a running instance is stopped for the restore and started again afterwards.
+func (a *App) RestoreBackupPaths(backupID string, paths []string, stopIfRunning bool) (*backup.RestorePlan, error) {
+	passphrase, _ := a.backupPassphrase()
+	return a.restoreBackup(backupID, paths, stopIfRunning, passphrase)
+}
+
+// RestoreBackupWithPassphrase restores an encrypted backup made under a different passphrase
+// than the current one, e.g. one copied over from a previous install.
+func (a *App) RestoreBackupWithPassphrase(backupID string, passphrase string, paths []string, stopIfRunning bool) (*backup.RestorePlan, error) {
+	return a.restoreBackup(backupID, paths, stopIfRunning, passphrase)
+}
+
+// PreviewRestore returns what restoring the backup (or just paths) would add and overwrite.
+func (a *App) PreviewRestore(backupID string, paths []string) (*backup.RestorePlan, error) {
+	inst, err := a.backupInstance(backupID)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, _ := a.backupPassphrase()
+	plan, err := backup.Restore(a.db, backupID, inst.InstallPath, backup.RestoreOptions{
+		Paths:      paths,
+		DryRun:     true,
+		Passphrase: passphrase,
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Str("backup", backupID).Msg("PreviewRestore failed")
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (a *App) restoreBackup(backupID string, paths []string, stopIfRunning bool, passphrase string) (*backup.RestorePlan, error) {
+	inst, err := a.backupInstance(backupID)
+	if err != nil {
+		return nil, err
+	}
+	instanceID := inst.ID.String()
+
+	wasRunning := false
+	status := a.instanceMgr.GetStatus(instanceID)
+	if status == "running" || status == "starting" {
+		if !stopIfRunning {
+			return nil, fmt.Errorf("cannot restore into a running instance; stop it first")
+		}
+		if err := a.StopInstance(instanceID); err != nil {
+			return nil, fmt.Errorf("stop instance: %w", err)
+		}
+		wasRunning = true
+	}
+
+	plan, err := backup.Restore(a.db, backupID, inst.InstallPath, backup.RestoreOptions{
+		Paths:           paths,
+		SafetyBackupDir: a.backupDir(),
+		Passphrase:      passphrase,
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Str("backup", backupID).Msg("RestoreBackup failed")
+	}
+
+	if wasRunning {
+		if startErr := a.StartInstance(instanceID); startErr != nil {
+			logger.Log.Error().Err(startErr).Str("instance", instanceID).Msg("Restart after restore failed")
+		}
+	}
+	return plan, err
+}
+
+// backupInstance returns the instance a backup belongs to.
+func (a *App) backupInstance(backupID string) (*models.ServerInstance, error) {
 	var b models.Backup
 	if err := a.db.First(&b, "id = ?", backupID).Error; err != nil {
-		return fmt.Errorf("backup not found: %w", err)
+		return nil, fmt.Errorf("backup not found: %w", err)
 	}
 	inst, err := a.GetInstance(b.InstanceID.String())
 	if err != nil {
-		return fmt.Errorf("instance not found: %w", err)
+		return nil, fmt.Errorf("instance not found: %w", err)
 	}
-	if err := backup.Restore(a.db, backupID, inst.InstallPath); err != nil {
-		logger.Log.Error().Err(err).Str("backup", backupID).Msg("RestoreBackup failed")
-		return err
+	return inst, nil
+}
+
+// VerifyBackup re-reads a backup and checks every file against its integrity manifest.
+func (a *App) VerifyBackup(backupID string) (*backup.VerifyResult, error) {
+	r, err := backup.VerifyBackup(a.db, backupID)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("backup", backupID).Msg("VerifyBackup failed")
+		return nil, err
 	}
-	return nil
+	return r, nil
+}
+
+// VerifyAllBackups checks every backup and alerts about the corrupt or missing ones.
+func (a *App) VerifyAllBackups() ([]backup.VerifyResult, error) {
+	results, err := backup.VerifyAll(a.db)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("VerifyAllBackups failed")
+		return nil, err
+	}
+	a.alertBackupProblems(results)
+	return results, nil
+}
+
+// verifyAllBackups is the scheduled form of VerifyAllBackups.
+func (a *App) verifyAllBackups() {
+	a.VerifyAllBackups()
+}
+
+// alertBackupProblems notifies via toast, Discord and webhook when backups failed verification.
+func (a *App) alertBackupProblems(results []backup.VerifyResult) {
+	var bad []backup.VerifyResult
+	for _, r := range results {
+		if r.Status != backup.VerifyOK {
+			bad = append(bad, r)
+		}
+	}
+	if len(bad) == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(bad))
+	for _, r := range bad {
+		line := r.BackupID + " (" + r.Status + ")"
+		if len(r.Problems) > 0 {
+			line += ": " + r.Problems[0]
+		}
+		lines = append(lines, line)
+	}
+	title := "CS2 Admin: Backup Verification Failed"
+	msg := fmt.Sprintf("%d of %d backups are corrupt or missing:\n%s", len(bad), len(results), strings.Join(lines, "\n"))
+
+	n := notify.New()
+	n.SetDiscordURL(a.cfg.DiscordWebhook)
+	if err := n.SendToast(title, msg); err != nil {
+		logger.Log.Error().Err(err).Msg("Backup alert toast failed")
+	}
+	if a.cfg.DiscordWebhook != "" {
+		_ = n.SendDiscord(title, msg, 0xFF0000)
+	}
+	payload, _ := json.Marshal(bad)
+	_ = n.SendWebhook("backup_verify_failed", string(payload))
 }
 
 // GetBackups returns backups for an instance.
@@ -1091,6 +2250,251 @@ func (a *App) GetBackups(instanceID string) ([]models.Backup, error) {
 	return backup.List(a.db, instanceID)
 }
 
+// SetBackupPassphrase turns on encryption for new backups; an empty passphrase turns it off.
+// The passphrase is kept encrypted with the machine key. Existing backups keep the passphrase
+// they were made with, so write it down: it's needed to restore them on another machine.
+func (a *App) SetBackupPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return a.db.Where("key = ?", "backup_passphrase").Delete(&models.AppSetting{}).Error
+	}
+	if len(passphrase) < 8 {
+		return fmt.Errorf("backup passphrase must be at least 8 characters")
+	}
+	enc, err := crypto.Encrypt(passphrase, a.encKey)
+	if err != nil {
+		return fmt.Errorf("encrypt passphrase: %w", err)
+	}
+	var s models.AppSetting
+	if a.db.Where("key = ?", "backup_passphrase").First(&s).Error != nil {
+		return a.db.Create(&models.AppSetting{Key: "backup_passphrase", Value: enc}).Error
+	}
+	return a.db.Model(&s).Update("value", enc).Error
+}
+
+// HasBackupPassphrase reports whether new backups are encrypted.
+func (a *App) HasBackupPassphrase() bool {
+	var count int64
+	a.db.Model(&models.AppSetting{}).Where("key = ?", "backup_passphrase").Count(&count)
+	return count > 0
+}
+
+// backupPassphrase returns the stored backup passphrase, or "" when encryption is off. It
+// fails rather than return "" when a passphrase is set but unreadable (e.g. the machine key
+// changed), so backups never silently fall back to plaintext.
+func (a *App) backupPassphrase() (string, error) {
+	var s models.AppSetting
+	if err := a.db.Where("key = ?", "backup_passphrase").First(&s).Error; err != nil {
+		return "", nil
+	}
+	passphrase, err := crypto.Decrypt(s.Value, a.encKey)
+	if err != nil {
+		return "", fmt.Errorf("backup passphrase can't be decrypted; set it again: %w", err)
+	}
+	return passphrase, nil
+}
+
+// ── App State ─────────────────────────────────────────────────────────
+
+// BackupAppState backs up the app's own database and config, plus the logs if asked. With a
+// backup passphrase set the archive is encrypted and carries the secrets, so it can be
+// restored on another machine.
+func (a *App) BackupAppState(includeLogs bool) (*backup.AppStateBackup, error) {
+	passphrase, err := a.backupPassphrase()
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("BackupAppState failed")
+		return nil, err
+	}
+	b, err := backup.CreateAppState(a.db, a.cfg, a.backupDir(), a.encKey, backup.AppStateOptions{
+		IncludeLogs: includeLogs,
+		Passphrase:  passphrase,
+		AppVersion:  appVersion,
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("BackupAppState failed")
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetAppStateBackups lists app-state backups, newest first.
+func (a *App) GetAppStateBackups() ([]backup.AppStateBackup, error) {
+	return backup.ListAppState(a.backupDir())
+}
+
+// InspectAppStateBackup reads where an app-state backup came from, including the install
+// paths of its instances, so the UI can ask how to remap them.
+func (a *App) InspectAppStateBackup(path string, passphrase string) (*backup.AppStateMeta, error) {
+	if passphrase == "" {
+		passphrase, _ = a.backupPassphrase()
+	}
+	return backup.InspectAppState(path, passphrase)
+}
+
+// RestoreAppState stages an app-state backup to replace the current database and config on
+// the next start. pathMap rewrites old path prefixes to new ones (e.g. the old servers
+// directory to this machine's); secrets are re-encrypted for this machine.
+func (a *App) RestoreAppState(path string, passphrase string, pathMap map[string]string) (*backup.AppStateRestoreResult, error) {
+	if passphrase == "" {
+		passphrase, _ = a.backupPassphrase()
+	}
+	res, err := backup.StageAppStateRestore(path, a.cfg, a.encKey, backup.AppStateRestoreOptions{
+		Passphrase: passphrase,
+		PathMap:    pathMap,
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Str("path", path).Msg("RestoreAppState failed")
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *App) scheduledAppStateBackup() {
+	if a.cfg.AppStateBackupKeep < 1 {
+		return
+	}
+	if _, err := a.BackupAppState(false); err != nil {
+		return
+	}
+	if err := backup.RotateAppState(a.backupDir(), a.cfg.AppStateBackupKeep); err != nil {
+		logger.Log.Error().Err(err).Msg("App-state backup rotation failed")
+	}
+}
+
+// BackupTargetConfig is the input structure for creating/updating backup targets.
+// Empty secrets keep the ones already stored.
+type BackupTargetConfig struct {
+	ID         string                `json:"id"`
+	Name       string                `json:"name"`
+	Kind       string                `json:"kind"`
+	AutoUpload bool                  `json:"auto_upload"`
+	Settings   backup.TargetSettings `json:"settings"`
+	Secrets    backup.TargetSecrets  `json:"secrets"`
+}
+
+// GetBackupTargets returns all configured backup targets.
+func (a *App) GetBackupTargets() ([]models.BackupTarget, error) {
+	var targets []models.BackupTarget
+	if err := a.db.Order("name").Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// SaveBackupTarget creates a backup target, or updates it when cfg.ID is set.
+func (a *App) SaveBackupTarget(cfg BackupTargetConfig) (*models.BackupTarget, error) {
+	switch cfg.Kind {
+	case backup.TargetLocal, backup.TargetS3, backup.TargetSFTP:
+	default:
+		return nil, fmt.Errorf("invalid backup target kind: %s", cfg.Kind)
+	}
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("backup target name is required")
+	}
+
+	var t models.BackupTarget
+	if cfg.ID != "" {
+		if err := a.db.First(&t, "id = ?", cfg.ID).Error; err != nil {
+			return nil, fmt.Errorf("backup target not found: %w", err)
+		}
+	}
+	settings, err := json.Marshal(cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+	t.Name = cfg.Name
+	t.Kind = cfg.Kind
+	t.AutoUpload = cfg.AutoUpload
+	t.Config = string(settings)
+	if cfg.Secrets != (backup.TargetSecrets{}) {
+		secrets, err := json.Marshal(cfg.Secrets)
+		if err != nil {
+			return nil, err
+		}
+		if t.Secret, err = crypto.Encrypt(string(secrets), a.encKey); err != nil {
+			return nil, fmt.Errorf("encrypt target secrets: %w", err)
+		}
+	}
+
+	if err := a.db.Save(&t).Error; err != nil {
+		logger.Log.Error().Err(err).Str("target", cfg.Name).Msg("SaveBackupTarget failed")
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteBackupTarget removes a backup target. Targets still holding backups can't be removed.
+func (a *App) DeleteBackupTarget(targetID string) error {
+	var count int64
+	if err := a.db.Model(&models.Backup{}).Where("remote_target_id = ?", targetID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("target still holds %d backups; delete them first", count)
+	}
+	return a.db.Where("id = ?", targetID).Delete(&models.BackupTarget{}).Error
+}
+
+// TestBackupTarget connects to a target and writes, then removes, a small probe file.
+func (a *App) TestBackupTarget(targetID string) error {
+	t, err := backup.OpenTarget(a.db, targetID)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	probe, err := os.CreateTemp("", "cs2admin-probe-*")
+	if err != nil {
+		return err
+	}
+	probe.WriteString("cs2admin")
+	probe.Close()
+	defer os.Remove(probe.Name())
+
+	key := ".cs2admin-probe"
+	if err := t.Put(a.ctx, key, probe.Name()); err != nil {
+		logger.Log.Error().Err(err).Str("target", targetID).Msg("TestBackupTarget failed")
+		return fmt.Errorf("write probe: %w", err)
+	}
+	return t.Delete(a.ctx, key)
+}
+
+// UploadBackup copies a backup to a backup target.
+func (a *App) UploadBackup(backupID string, targetID string) error {
+	if err := backup.Upload(a.ctx, a.db, backupID, targetID); err != nil {
+		logger.Log.Error().Err(err).Str("backup", backupID).Str("target", targetID).Msg("UploadBackup failed")
+		return err
+	}
+	return nil
+}
+
+// GetRemoteBackups lists the backups stored on a target.
+func (a *App) GetRemoteBackups(targetID string) ([]backup.RemoteBackup, error) {
+	return backup.ListRemote(a.ctx, a.db, targetID)
+}
+
+// autoUploadBackup copies a new backup to the first target with auto-upload enabled.
+func (a *App) autoUploadBackup(backupID string) {
+	var t models.BackupTarget
+	if err := a.db.Where("auto_upload = ?", true).Order("created_at").First(&t).Error; err != nil {
+		return
+	}
+	a.UploadBackup(backupID, t.ID.String())
+}
+
+// RecordStockBaseline re-hashes the instance's game files as the stock baseline used by
+// custom-content backups. Run it after validating a clean install.
+func (a *App) RecordStockBaseline(instanceID string) error {
+	inst, err := a.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	if _, err := backup.RecordBaseline(inst.InstallPath, a.backupDir(), instanceID); err != nil {
+		logger.Log.Error().Err(err).Str("instance", instanceID).Msg("RecordStockBaseline failed")
+		return err
+	}
+	return nil
+}
+
 // DeleteBackup deletes a backup by ID.
 func (a *App) DeleteBackup(backupID string) error {
 	if err := backup.Delete(a.db, backupID); err != nil {
@@ -1100,6 +2504,37 @@ func (a *App) DeleteBackup(backupID string) error {
 	return nil
 }
 
+// SetBackupPinned pins or unpins a backup so retention never prunes it.
+func (a *App) SetBackupPinned(backupID string, pinned bool) error {
+	return backup.SetPinned(a.db, backupID, pinned)
+}
+
+// GetRetentionPolicies returns the backup retention policies for an instance.
+func (a *App) GetRetentionPolicies(instanceID string) ([]models.RetentionPolicy, error) {
+	return backup.ListPolicies(a.db, instanceID)
+}
+
+// SaveRetentionPolicy creates or replaces the retention policy for an instance and backup type.
+func (a *App) SaveRetentionPolicy(policy models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	p, err := backup.SavePolicy(a.db, policy)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("SaveRetentionPolicy failed")
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeleteRetentionPolicy removes a retention policy by ID.
+func (a *App) DeleteRetentionPolicy(policyID string) error {
+	return backup.DeletePolicy(a.db, policyID)
+}
+
+// PreviewRetention returns what the instance's policy for backupType would keep and delete,
+// without deleting anything.
+func (a *App) PreviewRetention(instanceID string, backupType string) ([]backup.RetentionDecision, error) {
+	return backup.ApplyRetention(a.db, instanceID, backupType, true)
+}
+
 // ── File Manager ───────────────────────────────────────────────────────
 
 // ListFiles lists files in the instance's install directory at the given relative path.
@@ -1175,6 +2610,21 @@ func (a *App) LogAudit(action string, target string, details string) {
 
 // ── Internal Helpers ──────────────────────────────────────────────────
 
+// backupDir returns the configured backup directory, defaulting to <AppDataDir>/backups.
+func (a *App) backupDir() string {
+	if a.cfg.BackupDir == "" && a.cfg.AppDataDir != "" {
+		return filepath.Join(a.cfg.AppDataDir, "backups")
+	}
+	return a.cfg.BackupDir
+}
+
+// recordStockBaseline records the stock baseline in the background, logging failures.
+func (a *App) recordStockBaseline(instanceID, installPath string) {
+	if _, err := backup.RecordBaseline(installPath, a.backupDir(), instanceID); err != nil {
+		logger.Log.Warn().Err(err).Str("instance", instanceID).Msg("record stock baseline failed")
+	}
+}
+
 func (a *App) nextAvailablePort() int {
 	var inst models.ServerInstance
 	if err := a.db.Order("port desc").First(&inst).Error; err != nil {
@@ -10,7 +10,7 @@ import {
   ScrollArea,
 } from "@/components/ui";
 import { cn } from "@/lib/utils";
-import type { Backup } from "@/types";
+import type { Backup, BackupProgress } from "@/types";
 import { HardDrive, Plus, RotateCcw, Trash2 } from "lucide-react";
 
 const BACKUP_TYPES = [
@@ -18,6 +18,7 @@ const BACKUP_TYPES = [
   { value: "config", label: "Config Only" },
   { value: "maps", label: "Maps Only" },
   { value: "plugins", label: "Plugins Only" },
+  { value: "custom", label: "Custom Content Only" },
 ];
 
 // Mock when Wails not available
@@ -56,6 +57,7 @@ export function BackupsTab({ instanceId }: BackupsTabProps) {
   const [backupType, setBackupType] = useState("full");
   const [loading, setLoading] = useState(true);
   const [creating, setCreating] = useState(false);
+  const [progress, setProgress] = useState<BackupProgress | null>(null);
   const [actionId, setActionId] = useState<string | null>(null);
 
   const hasWails = typeof window !== "undefined" && !!(window as any).go?.main?.App;
@@ -79,6 +81,17 @@ export function BackupsTab({ instanceId }: BackupsTabProps) {
     load();
   }, [instanceId, hasWails]);
 
+  useEffect(() => {
+    if (!hasWails || !creating) return;
+    const unsub = (window as any).runtime?.EventsOn?.("backup-progress:" + instanceId, (p: BackupProgress) => {
+      setProgress(p);
+    });
+    return () => {
+      if (typeof unsub === "function") unsub();
+      setProgress(null);
+    };
+  }, [instanceId, hasWails, creating]);
+
   const handleCreate = async () => {
     setCreating(true);
     try {
@@ -147,6 +160,13 @@ export function BackupsTab({ instanceId }: BackupsTabProps) {
             <Plus className="mr-2 h-4 w-4" />
             Create
           </Button>
+          {creating && progress && (
+            <p className="text-sm text-muted-foreground">
+              {progress.stage === "scanning"
+                ? "Scanning files..."
+                : `${progress.total_bytes > 0 ? `${progress.percent.toFixed(0)}% · ` : ""}${progress.files} files · ${formatSize(progress.bytes)} · ${formatSize(Math.round(progress.bytes_per_sec))}/s`}
+            </p>
+          )}
         </CardContent>
       </Card>
 
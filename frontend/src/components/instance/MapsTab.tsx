@@ -10,7 +10,7 @@ import {
   ScrollArea,
 } from "@/components/ui";
 import { cn } from "@/lib/utils";
-import type { MapInfo } from "@/types";
+import type { MapInfo, SteamJob } from "@/types";
 import { Map as MapIcon, Download, ChevronUp, ChevronDown } from "lucide-react";
 
 const MAP_IMAGES: Record<string, string> = {
@@ -86,8 +86,12 @@ export function MapsTab({ instanceId }: MapsTabProps) {
 
   useEffect(() => {
     if (!hasWails || !isDownloading) return;
-    const unsub = (window as any).runtime?.EventsOn?.("progress:" + instanceId, (p: { percent?: number; message?: string }) => {
-      setDownloadProgress({ percent: p?.percent ?? 0, message: p?.message ?? "" });
+    const unsub = (window as any).runtime?.EventsOn?.("steamcmd-job", (job: SteamJob) => {
+      if (job.instance_id !== instanceId || job.kind !== "workshop") return;
+      setDownloadProgress({
+        percent: job.progress?.Percent ?? 0,
+        message: job.progress?.Message ?? (job.status === "queued" ? "Waiting for SteamCMD..." : ""),
+      });
     });
     return () => {
       if (typeof unsub === "function") unsub();
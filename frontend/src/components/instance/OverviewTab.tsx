@@ -1,6 +1,8 @@
 import { useEffect, useState, useRef } from "react";
-import { Play, Square, RotateCw, Server, Clock, Users, Cpu, HardDrive, Activity, Wifi } from "lucide-react";
+import { Play, Square, RotateCw, Server, Clock, Users, Cpu, HardDrive, Activity, Wifi, Pin, AlertTriangle, Wrench } from "lucide-react";
 import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
 import {
   Card,
   CardContent,
@@ -11,7 +13,7 @@ import {
 import { Badge } from "@/components/ui/badge";
 import { useAppStore } from "@/stores/app-store";
 import { cn } from "@/lib/utils";
-import type { ServerInstance } from "@/types";
+import type { BranchConfig, RepairReport, ServerInstance, SteamJob } from "@/types";
 
 export interface OverviewTabProps {
   instanceId: string;
@@ -65,6 +67,48 @@ export function OverviewTab({ instanceId }: OverviewTabProps) {
   const [uptime, setUptime] = useState(0);
   const startedAtRef = useRef<number | null>(null);
   const [actionLoading, setActionLoading] = useState<string | null>(null);
+  const [branch, setBranch] = useState(instance?.branch || "public");
+  const [betaPassword, setBetaPassword] = useState("");
+  const [pinnedBuild, setPinnedBuild] = useState(instance?.pinned_build_id ?? "");
+  const [savingBranch, setSavingBranch] = useState(false);
+  const [branchError, setBranchError] = useState<string | null>(null);
+  const [repairJobId, setRepairJobId] = useState<string | null>(null);
+  const [repairPercent, setRepairPercent] = useState(0);
+  const [repairReport, setRepairReport] = useState<RepairReport | null>(null);
+  const [repairError, setRepairError] = useState<string | null>(null);
+
+  // Last repair report, and the new one when a repair finishes
+  useEffect(() => {
+    const app = (window as any).go?.main?.App;
+    app?.GetRepairReport?.(instanceId).then((r: RepairReport | null) => setRepairReport(r)).catch(console.error);
+    const eventName = `repair-report:${instanceId}`;
+    (window as any).runtime?.EventsOn?.(eventName, (r: RepairReport) => setRepairReport(r));
+    return () => {
+      (window as any).runtime?.EventsOff?.(eventName);
+    };
+  }, [instanceId]);
+
+  useEffect(() => {
+    if (!repairJobId) return;
+    const eventName = `steamcmd-job:${repairJobId}`;
+    (window as any).runtime?.EventsOn?.(eventName, (job: SteamJob) => {
+      setRepairPercent(job.progress?.Percent ?? 0);
+      if (job.status === "failed" || job.status === "cancelled") {
+        setRepairError(job.error ?? job.status);
+        setRepairJobId(null);
+      } else if (job.status === "done") {
+        setRepairJobId(null);
+      }
+    });
+    return () => {
+      (window as any).runtime?.EventsOff?.(eventName);
+    };
+  }, [repairJobId]);
+
+  useEffect(() => {
+    setBranch(instance?.branch || "public");
+    setPinnedBuild(instance?.pinned_build_id ?? "");
+  }, [instance?.branch, instance?.pinned_build_id]);
 
   // Track uptime
   useEffect(() => {
@@ -151,7 +195,44 @@ export function OverviewTab({ instanceId }: OverviewTabProps) {
     }
   };
 
+  const handleSaveBranch = async () => {
+    setSavingBranch(true);
+    setBranchError(null);
+    try {
+      const cfg: BranchConfig = {
+        branch: branch.trim(),
+        beta_password: betaPassword,
+        pinned_build_id: pinnedBuild.trim(),
+      };
+      await (window as any).go?.main?.App?.SetInstanceBranch?.(instanceId, cfg);
+      const isPublic = !cfg.branch || cfg.branch === "public";
+      updateInstance(instanceId, {
+        branch: isPublic ? "" : cfg.branch,
+        pinned_build_id: cfg.pinned_build_id,
+        ...(cfg.branch !== (instance.branch || "public") ? { latest_build_id: "", build_checked_at: null } : {}),
+      });
+      setBetaPassword("");
+    } catch (err) {
+      setBranchError(String((err as Error)?.message ?? err));
+    } finally {
+      setSavingBranch(false);
+    }
+  };
+
+  const handleRepair = async () => {
+    setRepairError(null);
+    setRepairPercent(0);
+    try {
+      const jobId = await (window as any).go?.main?.App?.RepairCS2Server?.(instanceId);
+      if (jobId) setRepairJobId(jobId);
+    } catch (err) {
+      setRepairError(String((err as Error)?.message ?? err));
+    }
+  };
+
   const isRunning = instance.status === "running";
+  const behindHead =
+    !!instance.build_id && !!instance.latest_build_id && instance.build_id !== instance.latest_build_id;
   const ipPort = `127.0.0.1:${instance.port}`;
 
   return (
@@ -211,6 +292,112 @@ export function OverviewTab({ instanceId }: OverviewTabProps) {
         </CardContent>
       </Card>
 
+      {/* CS2 build: branch, pin and how far behind the branch head we are */}
+      <Card className="border-border">
+        <CardHeader className="pb-3">
+          <CardTitle className="flex items-center gap-2 text-base">
+            CS2 build
+            {instance.pinned_build_id && (
+              <Badge variant="secondary" className="gap-1">
+                <Pin className="h-3 w-3" /> pinned
+              </Badge>
+            )}
+          </CardTitle>
+          <CardDescription>
+            Installed {instance.build_id || "—"} &bull; {instance.branch || "public"} head {instance.latest_build_id || "unknown"}
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-3">
+          {behindHead && (
+            <div className="flex items-start gap-2 rounded-lg border border-amber-500/50 bg-amber-500/10 p-3 text-sm text-amber-600 dark:text-amber-400">
+              <AlertTriangle className="mt-0.5 h-4 w-4 shrink-0" />
+              {instance.pinned_build_id
+                ? `Pinned to build ${instance.pinned_build_id}; the ${instance.branch || "public"} branch is at ${instance.latest_build_id}. Updates are blocked until you unpin.`
+                : `Build ${instance.latest_build_id} is available on the ${instance.branch || "public"} branch.`}
+            </div>
+          )}
+          <div className="grid gap-3 sm:grid-cols-3">
+            <div>
+              <Label htmlFor="branch">Branch</Label>
+              <Input id="branch" value={branch} onChange={(e) => setBranch(e.target.value)} placeholder="public" className="mt-1" />
+            </div>
+            <div>
+              <Label htmlFor="beta-password">Beta password</Label>
+              <Input
+                id="beta-password"
+                type="password"
+                value={betaPassword}
+                onChange={(e) => setBetaPassword(e.target.value)}
+                placeholder="unchanged"
+                disabled={!branch.trim() || branch.trim() === "public"}
+                className="mt-1"
+              />
+            </div>
+            <div>
+              <Label htmlFor="pinned-build">Pinned build</Label>
+              <div className="mt-1 flex gap-2">
+                {/* SteamCMD can only install a branch's head, so only the installed build can be pinned. */}
+                <Input id="pinned-build" value={pinnedBuild} readOnly placeholder="not pinned" />
+                {instance.build_id && !pinnedBuild && (
+                  <Button size="sm" variant="outline" onClick={() => setPinnedBuild(instance.build_id)}>
+                    Pin current
+                  </Button>
+                )}
+                {pinnedBuild && (
+                  <Button size="sm" variant="outline" onClick={() => setPinnedBuild("")}>
+                    Unpin
+                  </Button>
+                )}
+              </div>
+            </div>
+          </div>
+          {branchError && <p className="text-sm text-destructive">{branchError}</p>}
+          <div className="flex flex-wrap gap-2">
+            <Button size="sm" onClick={handleSaveBranch} disabled={savingBranch}>
+              {savingBranch ? "Saving..." : "Save build settings"}
+            </Button>
+            <Button size="sm" variant="outline" onClick={handleRepair} disabled={!!repairJobId || isRunning}>
+              <Wrench className="mr-1.5 h-4 w-4" />
+              {repairJobId ? `Verifying... ${Math.round(repairPercent)}%` : "Verify & repair"}
+            </Button>
+          </div>
+          {repairError && <p className="text-sm text-destructive">{repairError}</p>}
+          {repairReport && !repairJobId && (
+            <div className="space-y-1 rounded-lg border border-border p-3 text-sm">
+              <p>
+                Last repair {new Date(repairReport.finished_at).toLocaleString()}: {repairReport.repaired?.length ?? 0} repaired,{" "}
+                {repairReport.restored?.length ?? 0} restored
+              </p>
+              {!!repairReport.reverted?.length && (
+                <div className="text-amber-600 dark:text-amber-400">
+                  <p>Your changes to these stock files were reverted:</p>
+                  <ul className="ml-4 list-disc font-mono text-xs">
+                    {repairReport.reverted.map((f) => (
+                      <li key={f}>{f}</li>
+                    ))}
+                  </ul>
+                </div>
+              )}
+              {repairReport.patches?.map((p) => (
+                <p key={p.name} className={cn("text-xs", p.error ? "text-destructive" : "text-muted-foreground")}>
+                  {p.error ? `Patch ${p.name} failed: ${p.error}` : p.changed ? `Re-applied ${p.name}` : `${p.name} already in place`}
+                </p>
+              ))}
+              {[...(repairReport.repaired ?? []), ...(repairReport.restored ?? [])].length > 0 && (
+                <details className="text-xs text-muted-foreground">
+                  <summary className="cursor-pointer">Files</summary>
+                  <ul className="ml-4 list-disc font-mono">
+                    {[...(repairReport.repaired ?? []), ...(repairReport.restored ?? [])].map((f) => (
+                      <li key={f}>{f}</li>
+                    ))}
+                  </ul>
+                </details>
+              )}
+            </div>
+          )}
+        </CardContent>
+      </Card>
+
       {/* Live stats */}
       <div className="grid gap-4 sm:grid-cols-2">
         <Card className="border-border">
@@ -0,0 +1,200 @@
+import { useEffect, useState } from "react";
+import {
+  Button,
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "@/components/ui";
+import { cn } from "@/lib/utils";
+import type { ConfigSchema, PluginConfig, PluginConfigFile } from "@/types";
+import { AlertTriangle, Check, X } from "lucide-react";
+
+interface PluginConfigDialogProps {
+  instanceId: string;
+  plugin: string; // catalog ID or plugin directory name
+  title: string;
+  // Set while the plugin is loaded on the running server, so saving can reload it.
+  canReload: boolean;
+  onClose: () => void;
+}
+
+const app = () => (window as any).go?.main?.App;
+
+export function PluginConfigDialog({ instanceId, plugin, title, canReload, onClose }: PluginConfigDialogProps) {
+  const [files, setFiles] = useState<PluginConfigFile[]>([]);
+  const [selected, setSelected] = useState<string | null>(null);
+  const [config, setConfig] = useState<PluginConfig | null>(null);
+  const [content, setContent] = useState("");
+  const [problems, setProblems] = useState<string[]>([]);
+  const [error, setError] = useState<string | null>(null);
+  const [saving, setSaving] = useState(false);
+  const [saved, setSaved] = useState(false);
+
+  useEffect(() => {
+    app()
+      ?.GetPluginConfigs?.(instanceId, plugin)
+      .then((list: PluginConfigFile[]) => {
+        const l = Array.isArray(list) ? list : [];
+        setFiles(l);
+        setSelected(l.find((f) => f.exists)?.path ?? l[0]?.path ?? null);
+      })
+      .catch((e: unknown) => setError(String(e)));
+  }, [instanceId, plugin]);
+
+  useEffect(() => {
+    if (!selected) return;
+    setError(null);
+    setSaved(false);
+    app()
+      ?.ReadPluginConfig?.(instanceId, plugin, selected)
+      .then((cfg: PluginConfig) => {
+        setConfig(cfg);
+        setContent(cfg.content);
+        setProblems([]);
+      })
+      .catch((e: unknown) => setError(String(e)));
+  }, [instanceId, plugin, selected]);
+
+  // Validate as the user types, once they pause.
+  useEffect(() => {
+    if (!config || content === config.content) {
+      setProblems([]);
+      return;
+    }
+    const t = setTimeout(() => {
+      app()
+        ?.ValidatePluginConfig?.(instanceId, plugin, config.path, content)
+        .then((p: string[] | null) => setProblems(p ?? []))
+        .catch((e: unknown) => setError(String(e)));
+    }, 400);
+    return () => clearTimeout(t);
+  }, [instanceId, plugin, config, content]);
+
+  const save = async (reload: boolean) => {
+    if (!config) return;
+    setSaving(true);
+    setError(null);
+    try {
+      await app()?.SavePluginConfig?.(instanceId, plugin, config.path, content, reload);
+      setConfig({ ...config, content, exists: true });
+      setSaved(true);
+    } catch (e) {
+      setError(String(e));
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  const fields = Object.entries(config?.schema?.properties ?? {}).sort(([a], [b]) => a.localeCompare(b));
+  const dirty = !!config && content !== config.content;
+
+  return (
+    <Dialog open onOpenChange={(open) => !open && onClose()}>
+      <DialogContent className="max-w-4xl border-border bg-background" onClick={(e) => e.stopPropagation()}>
+        <button
+          onClick={onClose}
+          className="absolute right-4 top-4 z-10 rounded-sm opacity-70 transition-opacity hover:opacity-100"
+          aria-label="Close"
+        >
+          <X className="h-4 w-4" />
+        </button>
+        <DialogHeader>
+          <DialogTitle>Configure {title}</DialogTitle>
+          <DialogDescription>
+            Edits are checked before saving; the previous file is kept with a .cs2admin.bak extension.
+          </DialogDescription>
+        </DialogHeader>
+
+        {files.length === 0 ? (
+          <p className="py-6 text-center text-sm text-muted-foreground">
+            No config files found. CounterStrikeSharp plugins write theirs the first time they load.
+          </p>
+        ) : (
+          <div className="space-y-3">
+            <div className="flex flex-wrap gap-1">
+              {files.map((f) => (
+                <Button
+                  key={f.path}
+                  size="sm"
+                  variant={f.path === selected ? "default" : "outline"}
+                  onClick={() => setSelected(f.path)}
+                  title={f.path}
+                >
+                  {f.path.split("/").pop()}
+                  {!f.exists && <span className="ml-1 text-xs opacity-70">(new)</span>}
+                </Button>
+              ))}
+            </div>
+            <div className="flex gap-3">
+              <textarea
+                value={content}
+                onChange={(e) => {
+                  setContent(e.target.value);
+                  setSaved(false);
+                }}
+                className={cn(
+                  "h-96 flex-1 resize-none rounded-md border bg-muted/20 p-3 font-mono text-sm",
+                  problems.length > 0 ? "border-destructive" : "border-border"
+                )}
+                spellCheck={false}
+              />
+              {fields.length > 0 && (
+                <div className="h-96 w-64 overflow-y-auto rounded-md border border-border p-2 text-xs">
+                  <p className="mb-2 text-muted-foreground">
+                    {config?.schema?.inferred ? "Settings (types from the current file)" : "Settings"}
+                  </p>
+                  {fields.map(([key, s]) => (
+                    <div key={key} className="mb-1.5">
+                      <span className="font-mono">{key}</span>
+                      <span className="ml-1 text-muted-foreground">{describe(s)}</span>
+                      {s.description && <p className="text-muted-foreground">{s.description}</p>}
+                    </div>
+                  ))}
+                </div>
+              )}
+            </div>
+            {problems.length > 0 && (
+              <ul className="space-y-0.5 text-xs text-destructive">
+                {problems.map((p) => (
+                  <li key={p} className="flex items-center gap-1">
+                    <AlertTriangle className="h-3 w-3 shrink-0" />
+                    {p}
+                  </li>
+                ))}
+              </ul>
+            )}
+          </div>
+        )}
+        {error && <p className="text-sm text-destructive">{error}</p>}
+
+        <DialogFooter className="items-center">
+          {saved && (
+            <span className="mr-auto flex items-center gap-1 text-sm text-emerald-400">
+              <Check className="h-4 w-4" />
+              Saved
+            </span>
+          )}
+          <Button variant="outline" disabled={!dirty || saving || problems.length > 0} onClick={() => save(false)}>
+            Save
+          </Button>
+          {canReload && (
+            <Button disabled={!dirty || saving || problems.length > 0} onClick={() => save(true)}>
+              Save &amp; reload plugin
+            </Button>
+          )}
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+}
+
+function describe(s: ConfigSchema): string {
+  const parts = [s.type || "any"];
+  if (s.enum?.length) parts.push(`one of ${s.enum.join(", ")}`);
+  if (s.minimum !== undefined) parts.push(`≥ ${s.minimum}`);
+  if (s.maximum !== undefined) parts.push(`≤ ${s.maximum}`);
+  return parts.join(", ");
+}
@@ -8,19 +8,18 @@ import {
   CardTitle,
 } from "@/components/ui";
 import { cn } from "@/lib/utils";
-import type { PluginInfo } from "@/types";
-import { Puzzle, Download, Check } from "lucide-react";
+import type { BundledPluginStatus, CatalogPlugin, PluginInfo, PluginPackageResult, ServerInstance } from "@/types";
+import { Puzzle, Download, Check, AlertTriangle, RefreshCw, Trash2, Undo2, Play, Square, RotateCw, Settings, FolderOpen, FileArchive, BarChart3 } from "lucide-react";
+import { PluginConfigDialog } from "./PluginConfigDialog";
 
-const AVAILABLE_PLUGINS = [
-  { name: "Metamod:Source", installName: "metamod", description: "Metamod plugin loader" },
-  { name: "CounterStrikeSharp", installName: "counterstrikesharp", description: "C# plugin framework" },
-  { name: "WeaponPaints", installName: "weaponpaints", description: "Weapon skin plugin" },
+// Mock when Wails not available
+const MOCK_CATALOG: CatalogPlugin[] = [
+  { id: "metamod", name: "Metamod:Source", description: "Metamod plugin loader", version: "", source: { type: "url", assets: {} }, install_root: "", expect_dir: "", detect: "" },
+  { id: "counterstrikesharp", name: "CounterStrikeSharp", description: "C# plugin framework", version: "", source: { type: "github", assets: {} }, install_root: "", expect_dir: "", detect: "", depends: ["metamod"] },
 ];
 
-// Mock when Wails not available
 const MOCK_PLUGINS: PluginInfo[] = [
-  { name: "metamod", installed: true, version: "2.0", path: "", enabled: true },
-  { name: "counterstrikesharp", installed: false, version: "", path: "", enabled: false },
+  { name: "metamod", installed: true, version: "2.0.0-dev+1384", path: "", enabled: true, patch_status: "patched", catalog_id: "metamod", managed: true, update_available: false },
 ];
 
 interface PluginsTabProps {
@@ -29,8 +28,19 @@ interface PluginsTabProps {
 
 export function PluginsTab({ instanceId }: PluginsTabProps) {
   const [plugins, setPlugins] = useState<PluginInfo[]>([]);
+  const [catalog, setCatalog] = useState<CatalogPlugin[]>([]);
+  const [error, setError] = useState<string | null>(null);
+  const [checking, setChecking] = useState(false);
+  const [busy, setBusy] = useState<string | null>(null);
   const [loading, setLoading] = useState(true);
   const [installing, setInstalling] = useState<string | null>(null);
+  const [configuring, setConfiguring] = useState<PluginInfo | null>(null);
+  const [packagePath, setPackagePath] = useState("");
+  const [instances, setInstances] = useState<ServerInstance[]>([]);
+  const [targets, setTargets] = useState<string[]>([instanceId]);
+  const [packageResults, setPackageResults] = useState<PluginPackageResult[]>([]);
+  const [bundled, setBundled] = useState<BundledPluginStatus[]>([]);
+  const [trackMatches, setTrackMatches] = useState(false);
 
   const hasWails = typeof window !== "undefined" && !!(window as any).go?.main?.App;
 
@@ -39,10 +49,15 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
       setLoading(true);
       try {
         if (hasWails) {
-          const list = await (window as any).go?.main?.App.GetPlugins?.(instanceId) ?? [];
+          const [list, cat] = await Promise.all([
+            (window as any).go?.main?.App.GetPlugins?.(instanceId) ?? [],
+            (window as any).go?.main?.App.GetPluginCatalog?.() ?? [],
+          ]);
           setPlugins(Array.isArray(list) ? list : []);
+          setCatalog(Array.isArray(cat) ? cat : []);
         } else {
           setPlugins(MOCK_PLUGINS);
+          setCatalog(MOCK_CATALOG);
         }
       } catch {
         setPlugins([]);
@@ -64,6 +79,7 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
 
   const handleInstall = async (installName: string) => {
     setInstalling(installName);
+    setError(null);
     try {
       if (hasWails) {
         await (window as any).go?.main?.App?.InstallPlugin?.(instanceId, installName);
@@ -72,13 +88,122 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
       }
     } catch (e) {
       console.error(e);
+      setError(String(e));
     } finally {
       setInstalling(null);
     }
   };
 
+  const handleCheckUpdates = async () => {
+    if (!hasWails) return;
+    setChecking(true);
+    setError(null);
+    try {
+      const list = await (window as any).go?.main?.App?.CheckPluginUpdates?.(instanceId) ?? [];
+      setPlugins(Array.isArray(list) ? list : []);
+    } catch (e) {
+      setError(String(e));
+    } finally {
+      setChecking(false);
+    }
+  };
+
+  const loadBundled = async () => {
+    const list = await (window as any).go?.main?.App?.GetBundledPlugins?.(instanceId) ?? [];
+    setBundled(Array.isArray(list) ? list : []);
+  };
+
+  const reload = async () => {
+    const list = await (window as any).go?.main?.App.GetPlugins?.(instanceId) ?? [];
+    setPlugins(Array.isArray(list) ? list : []);
+    await loadBundled();
+  };
+
+  // Runs a per-plugin action and refreshes the list; busy holds the plugin name meanwhile.
+  const runAction = async (name: string, action: () => Promise<unknown>) => {
+    if (!hasWails) return;
+    setBusy(name);
+    setError(null);
+    try {
+      const res: any = await action();
+      if (res?.kept?.length) {
+        setError(`Left ${res.kept.length} modified file(s) in place: ${res.kept.join(", ")}`);
+      }
+      await reload();
+    } catch (e) {
+      setError(String(e));
+    } finally {
+      setBusy(null);
+    }
+  };
+
+  const handleUninstall = (name: string) => {
+    if (!window.confirm(`Uninstall ${name}? Files you modified are kept.`)) return;
+    runAction(name, () => (window as any).go?.main?.App?.UninstallPlugin?.(instanceId, name));
+  };
+
+  const handleRollback = (name: string) =>
+    runAction(name, () => (window as any).go?.main?.App?.RollbackPlugin?.(instanceId, name));
+
+  const handleToggle = (name: string, enabled: boolean) =>
+    runAction(name, () => (window as any).go?.main?.App?.SetPluginEnabled?.(instanceId, name, enabled));
+
+  // Live actions go through RCON; a loaded plugin is addressed by its list number, an
+  // unloaded one by its directory name.
+  const handleLive = (p: PluginInfo, action: "Load" | "Unload" | "Reload") => {
+    const target = action !== "Load" && p.loaded_id ? String(p.loaded_id) : p.path.split(/[\\/]/).pop() || p.name;
+    runAction(p.name, () => (window as any).go?.main?.App?.[`${action}Plugin`]?.(instanceId, target));
+  };
+
+  // Only CounterStrikeSharp plugins can be moved into plugins/disabled.
+  const canDisable = (p: PluginInfo) => /counterstrikesharp[\\/]plugins[\\/]/i.test(p.path);
+
   const installedPlugins = plugins.filter((p) => p.installed);
 
+  useEffect(() => {
+    setTargets([instanceId]);
+    if (!hasWails) return;
+    (window as any).go?.main?.App?.GetInstances?.()
+      .then((list: ServerInstance[]) => setInstances(Array.isArray(list) ? list : []))
+      .catch(() => setInstances([]));
+  }, [instanceId, hasWails]);
+
+  useEffect(() => {
+    if (!hasWails) return;
+    loadBundled().catch(() => setBundled([]));
+    (window as any).go?.main?.App?.GetInstance?.(instanceId)
+      .then((inst: ServerInstance) => setTrackMatches(!!inst?.track_matches))
+      .catch(() => setTrackMatches(false));
+  }, [instanceId, hasWails]);
+
+  const handleInstallBundled = (name: string) =>
+    runAction(name, () => (window as any).go?.main?.App?.InstallBundledPlugin?.(instanceId, name));
+
+  const handleTrackMatches = async (enabled: boolean) => {
+    setError(null);
+    try {
+      await (window as any).go?.main?.App?.SetMatchTracking?.(instanceId, enabled);
+      setTrackMatches(enabled);
+    } catch (e) {
+      setError(String(e));
+    }
+  };
+
+  const statsPlugin = bundled.find((b) => b.name === "CS2AdminStats");
+
+  const browsePackage = async (folder: boolean) => {
+    const app = (window as any).go?.main?.App;
+    const p = await (folder ? app?.BrowseDirectory?.() : app?.BrowsePluginPackage?.());
+    if (p) setPackagePath(p);
+  };
+
+  // Installs the chosen zip or folder on every ticked instance.
+  const handleInstallPackage = () =>
+    runAction("package", async () => {
+      const res = await (window as any).go?.main?.App?.InstallPluginPackage?.(targets, packagePath);
+      setPackageResults(Array.isArray(res) ? res : []);
+    });
+
   return (
     <div className="space-y-6">
       <Card>
@@ -94,12 +219,14 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
             <p className="py-8 text-center text-muted-foreground">Loading...</p>
           ) : (
             <div className="space-y-3">
-              {AVAILABLE_PLUGINS.map((p) => {
-                const info = getPluginInfo(p.installName);
-                const installed = isInstalled(p.installName);
+              {error && <p className="text-sm text-destructive">{error}</p>}
+              {catalog.map((p) => {
+                const info = getPluginInfo(p.id);
+                const installed = isInstalled(p.id);
+                const requires = (p.depends ?? []).map((d) => catalog.find((c) => c.id === d)?.name ?? d);
                 return (
                   <div
-                    key={p.installName}
+                    key={p.id}
                     className={cn(
                       "flex flex-wrap items-center justify-between gap-4 rounded-lg border border-border bg-muted/20 p-4",
                       "sm:flex-nowrap"
@@ -116,18 +243,38 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
                         )}
                       </div>
                       <p className="mt-0.5 text-sm text-muted-foreground">{p.description}</p>
+                      {requires.length > 0 && (
+                        <p className="mt-1 text-xs text-muted-foreground">Requires {requires.join(", ")} (installed automatically)</p>
+                      )}
                       {info?.version && (
                         <p className="mt-1 text-xs text-muted-foreground">Version: {info.version}</p>
                       )}
+                      {info?.update_available && (
+                        <p className="mt-1 text-xs text-amber-400">Update available: {info.latest_version}</p>
+                      )}
+                      {installed && !info?.managed && !info?.update_available && info?.latest_version && (
+                        <p className="mt-1 text-xs text-muted-foreground">Latest release: {info.latest_version}</p>
+                      )}
+                      {info?.patch_status === "patched" && (
+                        <p className="mt-1 text-xs text-muted-foreground">gameinfo.gi patched</p>
+                      )}
+                      {info?.patch_status && info.patch_status !== "patched" && (
+                        <p className="mt-1 flex items-center gap-1 text-xs text-amber-400">
+                          <AlertTriangle className="h-3 w-3" />
+                          {info.patch_status === "missing"
+                            ? "gameinfo.gi is not patched, so Metamod won't load. Click Update to re-apply the patch."
+                            : `gameinfo.gi could not be checked: ${info.patch_error}`}
+                        </p>
+                      )}
                     </div>
                     <Button
                       size="sm"
-                      variant={installed ? "outline" : "default"}
-                      onClick={() => handleInstall(p.installName)}
+                      variant={installed && !info?.update_available ? "outline" : "default"}
+                      onClick={() => handleInstall(p.id)}
                       disabled={installing !== null}
                     >
                       <Download className="mr-1.5 h-4 w-4" />
-                      {installing === p.installName ? "Installing..." : installed ? "Update" : "Install"}
+                      {installing === p.id ? "Installing..." : installed ? "Update" : "Install"}
                     </Button>
                   </div>
                 );
@@ -137,10 +284,132 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
         </CardContent>
       </Card>
 
+      {bundled.length > 0 && (
+        <Card>
+          <CardHeader>
+            <CardTitle className="flex items-center gap-2">
+              <BarChart3 className="h-5 w-5" />
+              CS2Admin Plugins
+            </CardTitle>
+            <CardDescription>Plugins shipped with this version of CS2Admin; CounterStrikeSharp is installed with them</CardDescription>
+          </CardHeader>
+          <CardContent className="space-y-3">
+            {bundled.map((b) => (
+              <div
+                key={b.name}
+                className="flex flex-wrap items-center justify-between gap-4 rounded-lg border border-border bg-muted/20 p-4 sm:flex-nowrap"
+              >
+                <div>
+                  <div className="flex items-center gap-2">
+                    <span className="font-medium">{b.name}</span>
+                    {b.installed && !b.mismatch && (
+                      <span className="flex items-center gap-1 rounded bg-emerald-500/20 px-2 py-0.5 text-xs text-emerald-400">
+                        <Check className="h-3 w-3" />
+                        {b.enabled ? "Installed" : "Disabled"}
+                      </span>
+                    )}
+                    {b.mismatch && (
+                      <span className="flex items-center gap-1 rounded bg-amber-500/20 px-2 py-0.5 text-xs text-amber-400">
+                        <AlertTriangle className="h-3 w-3" />
+                        Version mismatch
+                      </span>
+                    )}
+                  </div>
+                  <p className="mt-0.5 text-sm text-muted-foreground">{b.description}</p>
+                  <p className="mt-1 text-xs text-muted-foreground">
+                    {b.available ? `Bundled: ${b.version || "unknown version"}` : "Not bundled with this build of CS2Admin"}
+                    {b.installed && ` · Installed: ${b.installed_version || "unknown version"}`}
+                  </p>
+                </div>
+                <Button
+                  size="sm"
+                  variant={b.installed && !b.mismatch ? "outline" : "default"}
+                  disabled={!b.available || busy !== null}
+                  onClick={() => handleInstallBundled(b.name)}
+                >
+                  <Download className="mr-1.5 h-4 w-4" />
+                  {busy === b.name ? "Installing..." : !b.installed ? "Install" : b.mismatch ? "Upgrade" : "Reinstall"}
+                </Button>
+              </div>
+            ))}
+            <label className="flex items-center gap-2 text-sm">
+              <input type="checkbox" checked={trackMatches} onChange={(e) => handleTrackMatches(e.target.checked)} />
+              Track matches on this instance
+            </label>
+            {trackMatches && statsPlugin && (!statsPlugin.installed || !statsPlugin.enabled) && (
+              <p className="flex items-center gap-1 text-xs text-amber-400">
+                <AlertTriangle className="h-3 w-3" />
+                Match tracking needs {statsPlugin.name} installed and enabled.
+              </p>
+            )}
+          </CardContent>
+        </Card>
+      )}
+
       <Card>
         <CardHeader>
-          <CardTitle>Installed Plugins</CardTitle>
-          <CardDescription>Plugins currently installed on this instance</CardDescription>
+          <CardTitle className="flex items-center gap-2">
+            <FileArchive className="h-5 w-5" />
+            Install from Disk
+          </CardTitle>
+          <CardDescription>
+            Install a plugin zip or build folder: addons/ packages, a plugin folder, or bare plugin DLLs
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-3">
+          <div className="flex flex-wrap items-center gap-2">
+            <Button size="sm" variant="outline" disabled={!hasWails} onClick={() => browsePackage(false)}>
+              <FileArchive className="mr-1.5 h-4 w-4" />
+              Choose zip...
+            </Button>
+            <Button size="sm" variant="outline" disabled={!hasWails} onClick={() => browsePackage(true)}>
+              <FolderOpen className="mr-1.5 h-4 w-4" />
+              Choose folder...
+            </Button>
+            {packagePath && <span className="truncate font-mono text-xs text-muted-foreground">{packagePath}</span>}
+          </div>
+          {instances.length > 1 && (
+            <div className="flex flex-wrap gap-x-4 gap-y-1 text-sm">
+              {instances.map((inst) => (
+                <label key={inst.id} className="flex items-center gap-1.5">
+                  <input
+                    type="checkbox"
+                    checked={targets.includes(inst.id)}
+                    onChange={(e) =>
+                      setTargets(e.target.checked ? [...targets, inst.id] : targets.filter((t) => t !== inst.id))
+                    }
+                  />
+                  {inst.name}
+                </label>
+              ))}
+            </div>
+          )}
+          <Button size="sm" disabled={!packagePath || targets.length === 0 || busy !== null} onClick={handleInstallPackage}>
+            <Download className="mr-1.5 h-4 w-4" />
+            {busy === "package" ? "Installing..." : targets.length > 1 ? `Install on ${targets.length} instances` : "Install"}
+          </Button>
+          {packageResults.length > 0 && (
+            <ul className="space-y-0.5 text-xs">
+              {packageResults.map((r) => (
+                <li key={r.instance_id} className={r.error ? "text-destructive" : "text-emerald-400"}>
+                  {r.instance_name || r.instance_id}: {r.error ? r.error : `${r.plugin} ${r.version ?? ""} installed`}
+                </li>
+              ))}
+            </ul>
+          )}
+        </CardContent>
+      </Card>
+
+      <Card>
+        <CardHeader className="flex flex-row items-start justify-between space-y-0">
+          <div>
+            <CardTitle>Installed Plugins</CardTitle>
+            <CardDescription>Plugins currently installed on this instance</CardDescription>
+          </div>
+          <Button size="sm" variant="outline" onClick={handleCheckUpdates} disabled={checking || installedPlugins.length === 0}>
+            <RefreshCw className={cn("mr-1.5 h-4 w-4", checking && "animate-spin")} />
+            {checking ? "Checking..." : "Check for updates"}
+          </Button>
         </CardHeader>
         <CardContent>
           {installedPlugins.length === 0 ? (
@@ -149,17 +418,93 @@ export function PluginsTab({ instanceId }: PluginsTabProps) {
             <div className="space-y-2">
               {installedPlugins.map((p) => (
                 <div
-                  key={p.name}
+                  key={p.path || p.name}
                   className="flex items-center justify-between rounded-md border border-border bg-muted/20 px-4 py-3"
                 >
-                  <span className="font-medium capitalize">{p.name.replace(/([A-Z])/g, " $1").trim()}</span>
-                  <span className="text-sm text-muted-foreground">{p.version || "—"}</span>
+                  <span className={cn("font-medium", !p.enabled && "text-muted-foreground")}>
+                    {catalog.find((c) => c.id === p.catalog_id)?.name ?? p.name}
+                    {!p.enabled && <span className="ml-2 text-xs font-normal">(disabled)</span>}
+                    {p.load_state && (
+                      <span
+                        className={cn(
+                          "ml-2 rounded px-2 py-0.5 text-xs font-normal",
+                          p.load_state === "loaded" ? "bg-emerald-500/20 text-emerald-400" : "bg-muted text-muted-foreground"
+                        )}
+                        title={p.author ? `by ${p.author}` : undefined}
+                      >
+                        {p.load_state === "loaded" ? "Loaded" : p.load_state === "not loaded" ? "Not loaded" : p.load_state[0].toUpperCase() + p.load_state.slice(1)}
+                        {!p.path && " (not found on disk)"}
+                      </span>
+                    )}
+                  </span>
+                  <div className="flex items-center gap-2">
+                    <span className="text-sm text-muted-foreground">
+                      {p.version || "—"}
+                      {p.update_available && <span className="ml-2 text-amber-400">→ {p.latest_version}</span>}
+                    </span>
+                    {p.load_state && p.enabled && (
+                      p.load_state === "loaded" ? (
+                        <>
+                          <Button size="sm" variant="ghost" disabled={busy !== null} title="Reload" onClick={() => handleLive(p, "Reload")}>
+                            <RotateCw className="h-3.5 w-3.5" />
+                          </Button>
+                          <Button size="sm" variant="ghost" disabled={busy !== null} title="Unload" onClick={() => handleLive(p, "Unload")}>
+                            <Square className="h-3.5 w-3.5" />
+                          </Button>
+                        </>
+                      ) : (
+                        <Button size="sm" variant="ghost" disabled={busy !== null} title="Load" onClick={() => handleLive(p, "Load")}>
+                          <Play className="h-3.5 w-3.5" />
+                        </Button>
+                      )
+                    )}
+                    {canDisable(p) && (
+                      <Button size="sm" variant="ghost" disabled={busy !== null} onClick={() => handleToggle(p.name, !p.enabled)}>
+                        {p.enabled ? "Disable" : "Enable"}
+                      </Button>
+                    )}
+                    {(p.catalog_id || canDisable(p)) && (
+                      <Button size="sm" variant="ghost" disabled={!hasWails} title="Configure" onClick={() => setConfiguring(p)}>
+                        <Settings className="h-3.5 w-3.5" />
+                      </Button>
+                    )}
+                    {p.rollback_version && (
+                      <Button
+                        size="sm"
+                        variant="ghost"
+                        disabled={busy !== null}
+                        title={`Go back to ${p.rollback_version}`}
+                        onClick={() => handleRollback(p.name)}
+                      >
+                        <Undo2 className="mr-1 h-3.5 w-3.5" />
+                        {p.rollback_version}
+                      </Button>
+                    )}
+                    {p.managed && (
+                      <Button size="sm" variant="ghost" disabled={busy !== null} onClick={() => handleUninstall(p.name)}>
+                        <Trash2 className="h-3.5 w-3.5" />
+                      </Button>
+                    )}
+                  </div>
                 </div>
               ))}
             </div>
           )}
         </CardContent>
       </Card>
+
+      {configuring && (
+        <PluginConfigDialog
+          instanceId={instanceId}
+          plugin={configuring.catalog_id || configuring.name}
+          title={catalog.find((c) => c.id === configuring.catalog_id)?.name ?? configuring.name}
+          canReload={configuring.load_state === "loaded"}
+          onClose={() => {
+            setConfiguring(null);
+            reload();
+          }}
+        />
+      )}
     </div>
   );
 }
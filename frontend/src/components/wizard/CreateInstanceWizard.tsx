@@ -23,7 +23,7 @@ import { Label } from "@/components/ui/label";
 import { Slider } from "@/components/ui/slider";
 import { cn } from "@/lib/utils";
 import { useAppStore } from "@/stores/app-store";
-import type { ServerInstance, GameModePreset, Progress } from "@/types";
+import type { ServerInstance, GameModePreset, Progress, SteamJob } from "@/types";
 
 const STEPS = [
   { id: 1, title: "Basic Info", desc: "Server name and install path" },
@@ -66,11 +66,37 @@ function mapInstanceFromApi(r: Record<string, unknown>): ServerInstance {
     gslt_token: "",
     auto_restart: Boolean(r.AutoRestart ?? r.auto_restart ?? true),
     auto_start: Boolean(r.AutoStart ?? r.auto_start ?? false),
+    update_on_new_build: Boolean(
+      r.UpdateOnNewBuild ?? r.update_on_new_build ?? false
+    ),
+    build_id: String(r.BuildID ?? r.build_id ?? ""),
+    latest_build_id: String(r.LatestBuildID ?? r.latest_build_id ?? ""),
+    build_checked_at: (r.BuildCheckedAt ?? r.build_checked_at ?? null) as
+      | string
+      | null,
+    branch: String(r.Branch ?? r.branch ?? ""),
+    pinned_build_id: String(r.PinnedBuildID ?? r.pinned_build_id ?? ""),
+    track_matches: Boolean(r.TrackMatches ?? r.track_matches ?? false),
     created_at: String(r.CreatedAt ?? r.created_at ?? ""),
     updated_at: String(r.UpdatedAt ?? r.updated_at ?? ""),
   };
 }
 
+function formatSize(bytes: number): string {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  if (bytes < 1024 * 1024 * 1024) return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+  return `${(bytes / (1024 * 1024 * 1024)).toFixed(1)} GB`;
+}
+
+function formatEta(seconds: number): string {
+  const s = Math.round(seconds);
+  if (s < 60) return `${s}s`;
+  const m = Math.floor(s / 60);
+  if (m < 60) return `${m}m ${s % 60}s`;
+  return `${Math.floor(m / 60)}h ${m % 60}m`;
+}
+
 export interface CreateInstanceWizardProps {
   open: boolean;
   onOpenChange: (open: boolean) => void;
@@ -105,6 +131,7 @@ export function CreateInstanceWizard({
   const [isCreating, setIsCreating] = useState(false);
   const [isInstalling, setIsInstalling] = useState(false);
   const [installLines, setInstallLines] = useState<string[]>([]);
+  const [installJobId, setInstallJobId] = useState<string | null>(null);
 
   // Load presets
   useEffect(() => {
@@ -238,6 +265,7 @@ export function CreateInstanceWizard({
     setIsCreating(false);
     setIsInstalling(false);
     setInstallLines([]);
+    setInstallJobId(null);
   }, [defaultInstallPath, appConfig?.default_install_dir]);
 
   const handleClose = useCallback(() => {
@@ -287,6 +315,8 @@ export function CreateInstanceWizard({
           launch_args: "",
           auto_restart: true,
           auto_start: false,
+          update_on_new_build: false,
+          track_matches: false,
         };
         const result = await fn(cfg);
         const inst = mapInstanceFromApi(result ?? {});
@@ -310,14 +340,17 @@ export function CreateInstanceWizard({
     if (!createdInstance) return;
     setIsInstalling(true);
     setInstallError(null);
-    setProgress({ stage: "preparing", percent: 0, message: "Starting SteamCMD..." });
+    setInstallLines([]);
+    setProgress({ stage: "queued", percent: 0, message: "Waiting for SteamCMD..." });
     try {
       const installFn = (window as any).go?.main?.App?.InstallCS2Server;
       if (typeof installFn === "function") {
-        installFn(createdInstance.id).catch((err: Error) => {
-          setInstallError(err?.message ?? "Install failed");
-          setIsInstalling(false);
-        });
+        installFn(createdInstance.id)
+          .then((jobId: string) => setInstallJobId(jobId))
+          .catch((err: Error) => {
+            setInstallError(err?.message ?? "Install failed");
+            setIsInstalling(false);
+          });
       } else {
         setInstallError("Install function not available");
         setIsInstalling(false);
@@ -328,6 +361,11 @@ export function CreateInstanceWizard({
     }
   };
 
+  const handleCancelInstall = () => {
+    if (!installJobId) return;
+    (window as any).go?.main?.App?.CancelSteamCMDJob?.(installJobId).catch(console.error);
+  };
+
   const handleBack = () => {
     setStep((s) => Math.max(0, s - 1));
   };
@@ -346,43 +384,53 @@ export function CreateInstanceWizard({
     }
   };
 
-  // Progress listener for step 4
+  // SteamCMD job listener for step 4: progress, outcome and raw output for the mini terminal
   useEffect(() => {
-    if (step !== 3 || !createdInstance?.id) return;
-    const eventName = `progress:${createdInstance.id}`;
-    const cb = (data: unknown) => {
-      const d = data as Record<string, unknown>;
-      const stage = String(d?.Stage ?? d?.stage ?? "").toLowerCase();
-      setProgress({
-        stage,
-        percent: Number(d?.Percent ?? d?.percent ?? 0),
-        message: String(d?.Message ?? d?.message ?? ""),
-      });
-      if (stage === "complete") {
+    if (step !== 3 || !installJobId) return;
+    const jobEvent = `steamcmd-job:${installJobId}`;
+    const lineEvent = `steamcmd-line:${installJobId}`;
+    const applyJob = (job: SteamJob) => {
+      if (job.progress) {
+        setProgress({
+          stage: String(job.progress.Stage ?? "").toLowerCase(),
+          percent: Number(job.progress.Percent ?? 0),
+          message: String(job.progress.Message ?? ""),
+          bytes_done: job.progress.BytesDone,
+          bytes_total: job.progress.BytesTotal,
+          rate: job.progress.Rate,
+          eta_seconds: job.progress.ETASeconds,
+        });
+      } else if (job.status === "running") {
+        setProgress({ stage: "preparing", percent: 0, message: "Starting SteamCMD..." });
+      }
+      if (job.status === "done") {
+        setProgress({ stage: "complete", percent: 100, message: "Installation complete" });
+        setIsInstalling(false);
+      } else if (job.status === "failed" || job.status === "cancelled") {
+        setInstallError(job.status === "cancelled" ? "Installation cancelled" : job.error ?? "Install failed");
         setIsInstalling(false);
       }
     };
-    (window as any).runtime?.EventsOn?.(eventName, cb);
-    return () => {
-      (window as any).runtime?.EventsOff?.(eventName);
-    };
-  }, [step, createdInstance?.id]);
-
-  // SteamCMD raw output listener for mini terminal
-  useEffect(() => {
-    if (step !== 3 || !createdInstance?.id) return;
-    const lineEvent = `install-line:${createdInstance.id}`;
     const lineCb = (line: unknown) => {
       setInstallLines(prev => {
         const next = [...prev, String(line)];
         return next.length > 500 ? next.slice(-500) : next;
       });
     };
+    (window as any).runtime?.EventsOn?.(jobEvent, applyJob);
     (window as any).runtime?.EventsOn?.(lineEvent, lineCb);
+    // Catch up on anything emitted before we subscribed.
+    (window as any).go?.main?.App?.GetSteamCMDJob?.(installJobId)
+      .then((job: SteamJob) => {
+        setInstallLines(job.log ?? []);
+        applyJob(job);
+      })
+      .catch(console.error);
     return () => {
+      (window as any).runtime?.EventsOff?.(jobEvent);
       (window as any).runtime?.EventsOff?.(lineEvent);
     };
-  }, [step, createdInstance?.id]);
+  }, [step, installJobId]);
 
   const canNext =
     (step === 0 && name.trim() && installPath.trim()) ||
@@ -616,7 +664,16 @@ export function CreateInstanceWizard({
                         <span className="text-muted-foreground capitalize">
                           {progress?.stage || "Preparing..."}
                         </span>
-                        <span>{Math.round(progress?.percent ?? 0)}%</span>
+                        <span>
+                          {!!progress?.bytes_total && (
+                            <span className="mr-3 text-muted-foreground">
+                              {formatSize(progress.bytes_done ?? 0)} / {formatSize(progress.bytes_total)}
+                              {!!progress.rate && ` · ${formatSize(Math.round(progress.rate))}/s`}
+                              {!!progress.eta_seconds && ` · ${formatEta(progress.eta_seconds)} left`}
+                            </span>
+                          )}
+                          {Math.round(progress?.percent ?? 0)}%
+                        </span>
                       </div>
                       <div className="h-2.5 w-full overflow-hidden rounded-full bg-muted">
                         <div
@@ -634,6 +691,12 @@ export function CreateInstanceWizard({
                           {progress.message}
                         </p>
                       )}
+                      {isInstalling && installJobId && (
+                        <Button variant="outline" size="sm" onClick={handleCancelInstall}>
+                          <X className="mr-2 h-4 w-4" />
+                          Cancel install
+                        </Button>
+                      )}
                     </div>
                   )}
 
@@ -70,6 +70,17 @@ function mapInstanceFromApi(r: Record<string, unknown>): ServerInstance {
     gslt_token: "",
     auto_restart: Boolean(r.AutoRestart ?? r.auto_restart ?? false),
     auto_start: Boolean(r.AutoStart ?? r.auto_start ?? false),
+    update_on_new_build: Boolean(
+      r.UpdateOnNewBuild ?? r.update_on_new_build ?? false
+    ),
+    build_id: String(r.BuildID ?? r.build_id ?? ""),
+    latest_build_id: String(r.LatestBuildID ?? r.latest_build_id ?? ""),
+    build_checked_at: (r.BuildCheckedAt ?? r.build_checked_at ?? null) as
+      | string
+      | null,
+    branch: String(r.Branch ?? r.branch ?? ""),
+    pinned_build_id: String(r.PinnedBuildID ?? r.pinned_build_id ?? ""),
+    track_matches: Boolean(r.TrackMatches ?? r.track_matches ?? false),
     created_at: String(r.CreatedAt ?? r.created_at ?? ""),
     updated_at: String(r.UpdatedAt ?? r.updated_at ?? ""),
   };
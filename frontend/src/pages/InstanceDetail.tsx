@@ -1,4 +1,4 @@
-import { useEffect } from "react";
+import { useEffect, useState } from "react";
 import { useParams, useNavigate } from "react-router-dom";
 import {
   Play,
@@ -19,6 +19,8 @@ import {
   Calendar,
   Activity,
   Zap,
+  AlertTriangle,
+  X,
 } from "lucide-react";
 import { Button } from "@/components/ui/button";
 import { Badge } from "@/components/ui/badge";
@@ -86,6 +88,7 @@ export function InstanceDetail() {
   const updateInstance = useAppStore((s) => s.updateInstance);
 
   const instance = instances.find((i) => i.id === id);
+  const [warning, setWarning] = useState<string | null>(null);
 
   // Subscribe to status updates from backend
   useEffect(() => {
@@ -108,6 +111,25 @@ export function InstanceDetail() {
     };
   }, [id, updateInstance]);
 
+  // Warnings raised while starting the instance, such as a missing stats plugin
+  useEffect(() => {
+    setWarning(null);
+    if (!id) return;
+    const eventName = `instance-warning:${id}`;
+    try {
+      (window as any).runtime?.EventsOn?.(eventName, (msg: unknown) => setWarning(String(msg ?? "")));
+    } catch {
+      // no-op
+    }
+    return () => {
+      try {
+        (window as any).runtime?.EventsOff?.(eventName);
+      } catch {
+        // no-op
+      }
+    };
+  }, [id]);
+
   if (!id) {
     return (
       <div className="flex flex-col items-center justify-center p-12">
@@ -197,6 +219,15 @@ export function InstanceDetail() {
             </Button>
           </div>
         </div>
+        {warning && (
+          <div className="mt-3 flex items-center gap-2 rounded-md border border-amber-500/40 bg-amber-500/10 px-3 py-2 text-sm text-amber-400">
+            <AlertTriangle className="h-4 w-4 shrink-0" />
+            <span className="flex-1">{warning}</span>
+            <button onClick={() => setWarning(null)} aria-label="Dismiss" className="opacity-70 hover:opacity-100">
+              <X className="h-4 w-4" />
+            </button>
+          </div>
+        )}
       </div>
 
       {/* Tabs */}
@@ -10,6 +10,7 @@ import {
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
+import { Select } from "@/components/ui/select";
 import { Switch } from "@/components/ui/switch";
 import { useAppStore } from "@/stores/app-store";
 import { cn } from "@/lib/utils";
@@ -74,6 +75,12 @@ export function Settings() {
         start_with_windows: config.start_with_windows ?? false,
         auto_update: config.auto_update ?? true,
         discord_webhook: config.discord_webhook ?? "",
+        appstate_backup_keep: config.appstate_backup_keep ?? 7,
+        backup_format: config.backup_format ?? "zip",
+        backup_compression_level: config.backup_compression_level ?? 0,
+        backup_concurrency: config.backup_concurrency ?? 0,
+        steamcmd_concurrency: config.steamcmd_concurrency ?? 1,
+        plugin_catalog_url: config.plugin_catalog_url ?? "",
       };
       await App.UpdateAppConfig(cfg);
       setTheme((cfg.theme as "dark" | "light" | "system") || "system");
@@ -176,6 +183,29 @@ export function Settings() {
                 className="mt-1"
               />
             </div>
+            <div>
+              <Label htmlFor="steamcmd-jobs">Parallel SteamCMD jobs</Label>
+              <Input
+                id="steamcmd-jobs"
+                type="number"
+                min={1}
+                value={config.steamcmd_concurrency ?? 1}
+                onChange={(e) => setConfig((c) => ({ ...c, steamcmd_concurrency: Math.max(1, Number(e.target.value) || 1) }))}
+                className="mt-1"
+              />
+              <p className="mt-1 text-xs text-muted-foreground">Installs and updates are queued; jobs on the same install directory never overlap</p>
+            </div>
+            <div>
+              <Label htmlFor="plugin-catalog">Plugin catalog URL</Label>
+              <Input
+                id="plugin-catalog"
+                value={config.plugin_catalog_url ?? ""}
+                onChange={(e) => setConfig((c) => ({ ...c, plugin_catalog_url: e.target.value }))}
+                placeholder="Optional remote index (JSON or YAML)"
+                className="mt-1"
+              />
+              <p className="mt-1 text-xs text-muted-foreground">Adds to the built-in plugin list. A plugin-catalog.yaml in the app data folder overrides both</p>
+            </div>
             <div>
               <Label htmlFor="install">Default install directory</Label>
               <Input
@@ -199,6 +229,72 @@ export function Settings() {
           </CardContent>
         </Card>
 
+        <Card>
+          <CardHeader>
+            <CardTitle>Backups</CardTitle>
+            <CardDescription>
+              Archive format and compression for config, map and plugin backups. Full backups use the deduplicated snapshot store.
+            </CardDescription>
+          </CardHeader>
+          <CardContent className="space-y-4">
+            <div>
+              <Label htmlFor="backup-format">Format</Label>
+              <Select
+                id="backup-format"
+                value={config.backup_format ?? "zip"}
+                onChange={(e) => setConfig((c) => ({ ...c, backup_format: e.target.value as AppConfig["backup_format"] }))}
+                className="mt-1"
+              >
+                <option value="zip">zip (compatible)</option>
+                <option value="tar.zst">tar.zst (faster, multi-core)</option>
+              </Select>
+            </div>
+            {config.backup_format === "tar.zst" && (
+              <div className="grid grid-cols-2 gap-4">
+                <div>
+                  <Label htmlFor="backup-level">Compression level</Label>
+                  <Select
+                    id="backup-level"
+                    value={String(config.backup_compression_level ?? 0)}
+                    onChange={(e) => setConfig((c) => ({ ...c, backup_compression_level: Number(e.target.value) }))}
+                    className="mt-1"
+                  >
+                    <option value="0">Default</option>
+                    <option value="1">Fastest</option>
+                    <option value="2">Balanced</option>
+                    <option value="3">Better</option>
+                    <option value="4">Smallest</option>
+                  </Select>
+                </div>
+                <div>
+                  <Label htmlFor="backup-threads">Compression threads</Label>
+                  <Input
+                    id="backup-threads"
+                    type="number"
+                    min={0}
+                    value={config.backup_concurrency ?? 0}
+                    onChange={(e) => setConfig((c) => ({ ...c, backup_concurrency: Math.max(0, Number(e.target.value) || 0) }))}
+                    className="mt-1"
+                  />
+                  <p className="mt-1 text-xs text-muted-foreground">0 = automatic (half the cores while the server is running)</p>
+                </div>
+              </div>
+            )}
+            <div>
+              <Label htmlFor="appstate-keep">App-state backups to keep</Label>
+              <Input
+                id="appstate-keep"
+                type="number"
+                min={0}
+                value={config.appstate_backup_keep ?? 7}
+                onChange={(e) => setConfig((c) => ({ ...c, appstate_backup_keep: Math.max(0, Number(e.target.value) || 0) }))}
+                className="mt-1"
+              />
+              <p className="mt-1 text-xs text-muted-foreground">Daily copies of the admin database and settings; 0 turns them off</p>
+            </div>
+          </CardContent>
+        </Card>
+
         <Card>
           <CardHeader>
             <CardTitle>Startup</CardTitle>
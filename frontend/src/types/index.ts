@@ -20,6 +20,13 @@ export interface ServerInstance {
   gslt_token: string;
   auto_restart: boolean;
   auto_start: boolean;
+  update_on_new_build: boolean;
+  build_id: string;
+  latest_build_id: string;
+  build_checked_at: string | null;
+  branch: string;
+  pinned_build_id: string;
+  track_matches: boolean;
   created_at: string;
   updated_at: string;
 }
@@ -35,6 +42,42 @@ export interface InstanceConfig {
   launch_args: string;
   auto_restart: boolean;
   auto_start: boolean;
+  update_on_new_build: boolean;
+  track_matches: boolean;
+}
+
+export interface BuildStatus {
+  instance_id: string;
+  branch: string;
+  build_id: string;
+  latest_build_id: string;
+  pinned_build_id: string;
+  update_available: boolean;
+  checked_at: string | null;
+}
+
+export interface PatchResult {
+  name: string;
+  file: string;
+  changed: boolean;
+  error?: string;
+}
+
+export interface RepairReport {
+  instance_id: string;
+  build_id: string;
+  started_at: string;
+  finished_at: string;
+  repaired: string[] | null;
+  restored: string[] | null;
+  reverted: string[] | null;
+  patches: PatchResult[] | null;
+}
+
+export interface BranchConfig {
+  branch: string;
+  beta_password: string;
+  pinned_build_id: string;
 }
 
 export interface AppConfig {
@@ -49,6 +92,12 @@ export interface AppConfig {
   start_with_windows: boolean;
   auto_update: boolean;
   discord_webhook: string;
+  appstate_backup_keep: number;
+  backup_format: "zip" | "tar.zst";
+  backup_compression_level: number;
+  backup_concurrency: number;
+  steamcmd_concurrency: number;
+  plugin_catalog_url: string;
 }
 
 export interface CvarDef {
@@ -115,6 +164,10 @@ export interface Progress {
   stage: string;
   percent: number;
   message: string;
+  bytes_done?: number;
+  bytes_total?: number;
+  rate?: number;
+  eta_seconds?: number;
 }
 
 export interface BenchmarkResult {
@@ -194,7 +247,183 @@ export interface Backup {
   path: string;
   size_bytes: number;
   backup_type: string;
+  format?: string;
+  stored_bytes?: number;
+  report?: string;
+  pinned?: boolean;
+  manifest_sha256?: string;
+  verify_status?: "" | "ok" | "corrupt" | "missing";
+  verify_error?: string;
+  verified_at?: string | null;
+  remote_target_id?: string;
+  remote_key?: string;
+  uploaded_at?: string | null;
+  encrypted?: boolean;
+  created_at: string;
+}
+
+export interface RetentionPolicy {
+  id: string;
+  instance_id: string;
+  backup_type: string;
+  keep_last: number;
+  keep_daily: number;
+  keep_weekly: number;
+  keep_monthly: number;
+  max_total_bytes: number;
   created_at: string;
+  updated_at: string;
+}
+
+export interface BackupTarget {
+  id: string;
+  name: string;
+  kind: "local" | "s3" | "sftp";
+  config: string; // JSON-encoded BackupTargetSettings
+  auto_upload: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface BackupTargetSettings {
+  dir?: string;
+  endpoint?: string;
+  bucket?: string;
+  region?: string;
+  prefix?: string;
+  use_ssl?: boolean;
+  host?: string;
+  port?: number;
+  user?: string;
+  host_key?: string;
+}
+
+export interface BackupTargetSecrets {
+  access_key?: string;
+  secret_key?: string;
+  password?: string;
+  private_key?: string;
+}
+
+export interface BackupTargetConfig {
+  id?: string;
+  name: string;
+  kind: BackupTarget["kind"];
+  auto_upload: boolean;
+  settings: BackupTargetSettings;
+  secrets: BackupTargetSecrets;
+}
+
+export interface RemoteBackup {
+  key: string;
+  size: number;
+  mod_time: string;
+  backup_id?: string;
+}
+
+export interface RetentionDecision {
+  backup: Backup;
+  keep: boolean;
+  reason: string;
+}
+
+export interface RestorePlan {
+  added: string[] | null;
+  changed: string[] | null;
+  unchanged: string[] | null;
+  total_bytes: number;
+  safety_backup_id?: string;
+}
+
+export interface SteamProgress {
+  Stage: string;
+  Percent: number;
+  Message: string;
+  BytesDone: number;
+  BytesTotal: number;
+  Rate: number;
+  ETASeconds: number;
+  StageSeconds: number;
+  Stages: { Stage: string; Seconds: number }[] | null;
+}
+
+export interface SteamJob {
+  id: string;
+  kind: "install" | "update" | "workshop" | "app_info" | string;
+  instance_id?: string;
+  title: string;
+  status: "queued" | "running" | "done" | "failed" | "cancelled";
+  progress?: SteamProgress;
+  error?: string;
+  error_kind?:
+    | "update_failed"
+    | "network"
+    | "timeout"
+    | "no_subscription"
+    | "rate_limited"
+    | "disk_full"
+    | "disk_write"
+    | "unknown";
+  created_at: string;
+  started_at?: string;
+  finished_at?: string;
+  log?: string[];
+}
+
+export interface BackupProgress {
+  stage: "scanning" | "archiving" | "complete";
+  files: number;
+  bytes: number;
+  total_bytes: number;
+  percent: number;
+  bytes_per_sec: number;
+}
+
+export interface VerifyResult {
+  backup_id: string;
+  instance_id: string;
+  status: "ok" | "corrupt" | "missing";
+  files: number;
+  bytes: number;
+  problems: string[] | null;
+  legacy: boolean;
+  checked_at: string;
+}
+
+export interface AppStateBackup {
+  path: string;
+  name: string;
+  size_bytes: number;
+  created_at: string;
+  encrypted: boolean;
+}
+
+export interface AppStateInstance {
+  id: string;
+  name: string;
+  install_path: string;
+}
+
+export interface AppStateMeta {
+  version: number;
+  app_version: string;
+  created_at: string;
+  hostname: string;
+  app_data_dir: string;
+  backup_dir: string;
+  default_install_dir: string;
+  steamcmd_path: string;
+  instances: AppStateInstance[] | null;
+  has_secrets: boolean;
+  includes_logs: boolean;
+}
+
+export interface AppStateRestoreResult {
+  meta: AppStateMeta;
+  paths_remapped: number;
+  secrets_restored: number;
+  secrets_cleared: number;
+  restart_required: boolean;
 }
 
 export interface ScheduledTask {
@@ -215,6 +444,89 @@ export interface PluginInfo {
   version: string;
   path: string;
   enabled: boolean;
+  patch_status?: "patched" | "missing" | "error";
+  patch_error?: string;
+  catalog_id?: string;
+  managed: boolean;
+  installed_release?: string;
+  rollback_version?: string;
+  latest_version?: string;
+  update_available: boolean;
+  load_state?: "loaded" | "unloaded" | "loading" | "unregistered" | "not loaded";
+  loaded_id?: number;
+  loaded_version?: string;
+  author?: string;
+}
+
+export interface PluginPackageResult {
+  instance_id: string;
+  instance_name: string;
+  plugin: string;
+  version?: string;
+  error?: string;
+}
+
+export interface BundledPlugin {
+  name: string;
+  description: string;
+  version: string;
+  available: boolean;
+}
+
+export interface BundledPluginStatus extends BundledPlugin {
+  installed: boolean;
+  enabled: boolean;
+  installed_version?: string;
+  mismatch: boolean;
+}
+
+export interface PluginSource {
+  type: "github" | "url";
+  repo?: string;
+  assets: Record<string, string>;
+  latest?: Record<string, string>;
+}
+
+export interface CatalogPlugin {
+  id: string;
+  name: string;
+  description: string;
+  aliases?: string[];
+  version: string;
+  source: PluginSource;
+  install_root: string;
+  expect_dir: string;
+  detect: string;
+  checksums?: Record<string, string>;
+  minisign_key?: string;
+  depends?: string[];
+  config_paths?: string[];
+  config_schemas?: Record<string, ConfigSchema>;
+}
+
+export interface ConfigSchema {
+  type?: "object" | "array" | "string" | "number" | "integer" | "boolean";
+  description?: string;
+  properties?: Record<string, ConfigSchema>;
+  items?: ConfigSchema;
+  enum?: unknown[];
+  minimum?: number;
+  maximum?: number;
+  required?: string[];
+  inferred?: boolean;
+}
+
+export interface PluginConfigFile {
+  path: string;
+  format: "json" | "keyvalues" | "text";
+  exists: boolean;
+  size: number;
+  modified: string;
+}
+
+export interface PluginConfig extends PluginConfigFile {
+  content: string;
+  schema?: ConfigSchema;
 }
 
 export interface FileEntry {
@@ -0,0 +1,723 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cs2admin/internal/config"
+	"cs2admin/internal/pkg/crypto"
+	"cs2admin/internal/pkg/logger"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// App-state backups cover the admin app itself rather than a server: a consistent copy of
+// cs2admin.db, config.yaml and optionally the logs. They aren't tied to an instance, so they
+// are self-describing files under <backup dir>/appstate rather than Backup rows.
+//
+// Secrets in the database are sealed with the machine-bound key, which a new machine can't
+// derive. When the backup has a passphrase the plaintext secrets travel inside the encrypted
+// archive and are re-sealed with the new machine's key on restore; without one they can only
+// be recovered on the machine that made the backup.
+const (
+	appStateDir          = "appstate"
+	appStatePrefix       = "cs2admin_appstate_"
+	appStateDBEntry      = "cs2admin.db"
+	appStateConfigEntry  = "config.yaml"
+	appStateLogsDir      = "logs"
+	appStateMetaEntry    = ".cs2admin/appstate.json"
+	appStateSecretsEntry = ".cs2admin/secrets.json"
+
+	// appStateStaging holds a restore until the next start, when the database isn't open.
+	appStateStaging = "restore-staging"
+	appStateReady   = "ready.json"
+
+	appStateVersion = 1
+)
+
+// AppStateMeta describes the machine and layout an app-state backup was taken from.
+type AppStateMeta struct {
+	Version           int                `json:"version"`
+	AppVersion        string             `json:"app_version"`
+	CreatedAt         time.Time          `json:"created_at"`
+	Hostname          string             `json:"hostname"`
+	AppDataDir        string             `json:"app_data_dir"`
+	BackupDir         string             `json:"backup_dir"`
+	DefaultInstallDir string             `json:"default_install_dir"`
+	SteamCMDPath      string             `json:"steamcmd_path"`
+	Instances         []AppStateInstance `json:"instances"`
+	HasSecrets        bool               `json:"has_secrets"`
+	IncludesLogs      bool               `json:"includes_logs"`
+}
+
+// AppStateInstance is a server instance recorded in an app-state backup, so a restore can
+// offer to remap its install path.
+type AppStateInstance struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	InstallPath string `json:"install_path"`
+}
+
+// AppStateBackup is an app-state backup file on disk.
+type AppStateBackup struct {
+	Path      string    `json:"path"`
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// AppStateOptions controls CreateAppState.
+type AppStateOptions struct {
+	IncludeLogs bool
+	Passphrase  string
+	AppVersion  string
+}
+
+// AppStateRestoreOptions controls StageAppStateRestore.
+type AppStateRestoreOptions struct {
+	Passphrase string
+	// PathMap rewrites path prefixes from the old machine to the new one, e.g. a server
+	// install root. The app's own data and backup directories are mapped automatically.
+	PathMap map[string]string
+}
+
+// AppStateRestoreResult summarises a staged restore. It takes effect on the next start.
+type AppStateRestoreResult struct {
+	Meta            AppStateMeta `json:"meta"`
+	PathsRemapped   int          `json:"paths_remapped"`
+	SecretsRestored int          `json:"secrets_restored"`
+	SecretsCleared  int          `json:"secrets_cleared"`
+	RestartRequired bool         `json:"restart_required"`
+}
+
+// secretColumn is a database column holding values sealed with the machine key.
+type secretColumn struct {
+	Table  string
+	Column string
+	Where  string
+}
+
+var appStateSecretColumns = []secretColumn{
+	{Table: "server_instances", Column: "rcon_password"},
+	{Table: "server_instances", Column: "gslt_token"},
+	{Table: "server_instances", Column: "beta_password"},
+	{Table: "backup_targets", Column: "secret"},
+	{Table: "app_settings", Column: "value", Where: "key = 'backup_passphrase'"},
+}
+
+// appStateSecret is one decrypted secret carried inside an encrypted app-state backup.
+type appStateSecret struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	ID     string `json:"id"`
+	Value  string `json:"value"`
+}
+
+// CreateAppState writes an app-state backup of db and cfg into <backupDir>/appstate, the
+// same place ListAppState and RotateAppState look. encKey is the machine key the database
+// secrets are sealed with.
+func CreateAppState(db *gorm.DB, cfg *config.AppConfig, backupDir string, encKey []byte, opts AppStateOptions) (*AppStateBackup, error) {
+	dir := filepath.Join(backupDir, appStateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create app-state backup dir: %w", err)
+	}
+	// The staged copy holds the database and, with a passphrase, the plaintext secrets, so it
+	// goes in the private temp dir rather than the backup dir.
+	tmpDir, err := decryptTempDir()
+	if err != nil {
+		return nil, fmt.Errorf("create app-state staging dir: %w", err)
+	}
+	stage, err := os.MkdirTemp(tmpDir, appStateTempPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stage)
+
+	// VACUUM INTO takes a transactionally consistent copy while the app keeps running.
+	if err := db.Exec("VACUUM INTO ?", filepath.Join(stage, appStateDBEntry)).Error; err != nil {
+		return nil, fmt.Errorf("snapshot database: %w", err)
+	}
+	if err := copyFile(filepath.Join(cfg.AppDataDir, "config.yaml"), filepath.Join(stage, appStateConfigEntry)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("copy config: %w", err)
+	}
+	if opts.IncludeLogs && cfg.LogDir != "" {
+		if err := copyLogs(cfg.LogDir, filepath.Join(stage, appStateLogsDir)); err != nil {
+			return nil, fmt.Errorf("copy logs: %w", err)
+		}
+	}
+
+	meta := AppStateMeta{
+		Version:           appStateVersion,
+		AppVersion:        opts.AppVersion,
+		CreatedAt:         time.Now().UTC(),
+		AppDataDir:        cfg.AppDataDir,
+		BackupDir:         cfg.BackupDir,
+		DefaultInstallDir: cfg.DefaultInstallDir,
+		SteamCMDPath:      cfg.SteamCMDPath,
+		IncludesLogs:      opts.IncludeLogs,
+	}
+	meta.Hostname, _ = os.Hostname()
+	if err := db.Table("server_instances").Select("id, name, install_path").Scan(&meta.Instances).Error; err != nil {
+		return nil, fmt.Errorf("list instances: %w", err)
+	}
+
+	if opts.Passphrase != "" {
+		secrets, err := exportSecrets(db, encKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeJSON(filepath.Join(stage, appStateSecretsEntry), secrets); err != nil {
+			return nil, err
+		}
+		meta.HasSecrets = true
+	}
+	if err := writeJSON(filepath.Join(stage, appStateMetaEntry), meta); err != nil {
+		return nil, err
+	}
+
+	filename := appStatePrefix + time.Now().Format("20060102_150405") + ".zip"
+	if opts.Passphrase != "" {
+		filename += ".enc"
+	}
+	zipPath := filepath.Join(dir, filename)
+	all := func(rel, absPath string, info os.FileInfo) (bool, error) { return true, nil }
+	if _, err := writeArchive(zipPath, stage, []string{stage}, all, CreateOptions{Passphrase: opts.Passphrase}); err != nil {
+		os.Remove(zipPath)
+		return nil, fmt.Errorf("create zip: %w", err)
+	}
+
+	info, err := os.Stat(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info().
+		Str("path", zipPath).
+		Int64("size", info.Size()).
+		Bool("encrypted", opts.Passphrase != "").
+		Msg("backup: app state saved")
+	return &AppStateBackup{
+		Path:      zipPath,
+		Name:      filename,
+		SizeBytes: info.Size(),
+		CreatedAt: info.ModTime(),
+		Encrypted: opts.Passphrase != "",
+	}, nil
+}
+
+// exportSecrets decrypts every machine-sealed secret in db. Values that don't decrypt are
+// already lost and are left out.
+func exportSecrets(db *gorm.DB, encKey []byte) ([]appStateSecret, error) {
+	var out []appStateSecret
+	for _, sc := range appStateSecretColumns {
+		rows, err := selectSecrets(db, sc)
+		if err != nil {
+			return nil, fmt.Errorf("read %s.%s: %w", sc.Table, sc.Column, err)
+		}
+		for _, r := range rows {
+			plain, err := crypto.Decrypt(r.Value, encKey)
+			if err != nil {
+				logger.Log.Warn().Str("table", sc.Table).Str("id", r.ID).Msg("backup: secret can't be decrypted; leaving it out of the app-state backup")
+				continue
+			}
+			out = append(out, appStateSecret{Table: sc.Table, Column: sc.Column, ID: r.ID, Value: plain})
+		}
+	}
+	return out, nil
+}
+
+type secretRow struct {
+	ID    string
+	Value string
+}
+
+func selectSecrets(db *gorm.DB, sc secretColumn) ([]secretRow, error) {
+	q := db.Table(sc.Table).Select("CAST(id AS TEXT) AS id, " + sc.Column + " AS value").Where(sc.Column + " <> ''")
+	if sc.Where != "" {
+		q = q.Where(sc.Where)
+	}
+	var rows []secretRow
+	err := q.Scan(&rows).Error
+	return rows, err
+}
+
+// ListAppState returns the app-state backups in backupDir, newest first.
+func ListAppState(backupDir string) ([]AppStateBackup, error) {
+	entries, err := os.ReadDir(filepath.Join(backupDir, appStateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []AppStateBackup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, appStatePrefix) ||
+			!(strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".zip.enc")) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, AppStateBackup{
+			Path:      filepath.Join(backupDir, appStateDir, name),
+			Name:      name,
+			SizeBytes: info.Size(),
+			CreatedAt: info.ModTime(),
+			Encrypted: strings.HasSuffix(name, ".enc"),
+		})
+	}
+	// Names carry a sortable timestamp, which survives copying better than mtimes.
+	sort.Slice(out, func(i, j int) bool { return out[i].Name > out[j].Name })
+	return out, nil
+}
+
+// RotateAppState deletes all but the newest keep app-state backups.
+func RotateAppState(backupDir string, keep int) error {
+	if keep < 1 {
+		return nil
+	}
+	list, err := ListAppState(backupDir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, b := range list[min(keep, len(list)):] {
+		if err := os.Remove(b.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.Log.Info().Str("path", b.Path).Msg("backup: rotated out app-state backup")
+	}
+	return errors.Join(errs...)
+}
+
+// appStateArchive is an opened app-state backup with its manifest checked on read.
+type appStateArchive struct {
+	zr      *zip.ReadCloser
+	files   map[string]ManifestFile
+	cleanup func()
+}
+
+func openAppState(p, passphrase string) (*appStateArchive, error) {
+	zipPath := p
+	cleanup := func() {}
+	if isEncryptedFile(p) {
+		tmp, err := decryptToTemp(p, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		zipPath = tmp
+		cleanup = func() { os.Remove(tmp) }
+	}
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("open app-state backup: %w", err)
+	}
+	a := &appStateArchive{zr: zr, files: make(map[string]ManifestFile), cleanup: cleanup}
+
+	var m Manifest
+	data, err := a.raw(manifestEntry)
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	for _, f := range m.Files {
+		a.files[f.Path] = f
+	}
+	return a, nil
+}
+
+func (a *appStateArchive) Close() error {
+	err := a.zr.Close()
+	a.cleanup()
+	return err
+}
+
+func (a *appStateArchive) find(name string) *zip.File {
+	for _, f := range a.zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (a *appStateArchive) raw(name string) ([]byte, error) {
+	f := a.find(name)
+	if f == nil {
+		return nil, os.ErrNotExist
+	}
+	return readZipEntry(f)
+}
+
+// extract copies an entry to dest, failing if it doesn't match the manifest.
+func (a *appStateArchive) extract(name, dest string) error {
+	f, want := a.find(name), a.files[name]
+	if f == nil || want.Path == "" {
+		return fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), rc)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && (n != want.Size || hex.EncodeToString(h.Sum(nil)) != want.SHA256) {
+		err = fmt.Errorf("%s: checksum mismatch", name)
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
+
+func (a *appStateArchive) meta() (*AppStateMeta, error) {
+	data, err := a.raw(appStateMetaEntry)
+	if err != nil {
+		return nil, fmt.Errorf("not an app-state backup: %w", err)
+	}
+	var m AppStateMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse app-state metadata: %w", err)
+	}
+	if m.Version > appStateVersion {
+		return nil, fmt.Errorf("app-state backup version %d is newer than this app supports", m.Version)
+	}
+	return &m, nil
+}
+
+// InspectAppState reads an app-state backup's metadata, e.g. to build a PathMap.
+func InspectAppState(p, passphrase string) (*AppStateMeta, error) {
+	a, err := openAppState(p, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	return a.meta()
+}
+
+// StageAppStateRestore prepares an app-state backup to replace the current database and
+// config, with paths remapped and secrets re-sealed under encKey. The running app keeps its
+// database open, so the staged files are swapped in by ApplyStagedAppState on the next start.
+func StageAppStateRestore(p string, cfg *config.AppConfig, encKey []byte, opts AppStateRestoreOptions) (*AppStateRestoreResult, error) {
+	a, err := openAppState(p, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	meta, err := a.meta()
+	if err != nil {
+		return nil, err
+	}
+
+	staging := filepath.Join(cfg.AppDataDir, appStateStaging)
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			os.RemoveAll(staging)
+		}
+	}()
+
+	dbPath := filepath.Join(staging, appStateDBEntry)
+	if err := a.extract(appStateDBEntry, dbPath); err != nil {
+		return nil, fmt.Errorf("extract database: %w", err)
+	}
+
+	pathMap := make(map[string]string, len(opts.PathMap)+2)
+	for _, pair := range [][2]string{{meta.AppDataDir, cfg.AppDataDir}, {meta.BackupDir, cfg.BackupDir}} {
+		if pair[0] != "" && pair[1] != "" {
+			pathMap[pair[0]] = pair[1]
+		}
+	}
+	for from, to := range opts.PathMap {
+		pathMap[from] = to
+	}
+
+	var secrets []appStateSecret
+	if meta.HasSecrets {
+		data, err := a.raw(appStateSecretsEntry)
+		if err == nil {
+			err = json.Unmarshal(data, &secrets)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read secrets: %w", err)
+		}
+	}
+
+	res := &AppStateRestoreResult{Meta: *meta, RestartRequired: true}
+	if err := rewriteStagedDB(dbPath, pathMap, secrets, encKey, res); err != nil {
+		return nil, err
+	}
+
+	if a.find(appStateConfigEntry) != nil {
+		if err := stageConfig(a, staging, cfg, pathMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeJSON(filepath.Join(staging, appStateReady), res); err != nil {
+		return nil, err
+	}
+	ok = true
+	logger.Log.Info().
+		Str("path", p).
+		Int("paths_remapped", res.PathsRemapped).
+		Int("secrets_restored", res.SecretsRestored).
+		Int("secrets_cleared", res.SecretsCleared).
+		Msg("backup: app-state restore staged; restart to apply")
+	return res, nil
+}
+
+func rewriteStagedDB(dbPath string, pathMap map[string]string, secrets []appStateSecret, encKey []byte, res *AppStateRestoreResult) error {
+	sdb, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		return fmt.Errorf("open staged database: %w", err)
+	}
+	sqlDB, err := sdb.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	return sdb.Transaction(func(tx *gorm.DB) error {
+		for _, pc := range []struct{ table, column string }{
+			{"server_instances", "install_path"},
+			{"backups", "path"},
+		} {
+			var rows []secretRow
+			if err := tx.Table(pc.table).Select("id, " + pc.column + " AS value").Scan(&rows).Error; err != nil {
+				return fmt.Errorf("read %s.%s: %w", pc.table, pc.column, err)
+			}
+			for _, r := range rows {
+				mapped, changed := remapPath(r.Value, pathMap)
+				if !changed {
+					continue
+				}
+				if err := tx.Table(pc.table).Where("id = ?", r.ID).Update(pc.column, mapped).Error; err != nil {
+					return err
+				}
+				res.PathsRemapped++
+			}
+		}
+
+		restored := make(map[string]bool, len(secrets))
+		for _, s := range secrets {
+			sealed, err := crypto.Encrypt(s.Value, encKey)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table(s.Table).Where("id = ?", s.ID).Update(s.Column, sealed).Error; err != nil {
+				return fmt.Errorf("restore %s.%s: %w", s.Table, s.Column, err)
+			}
+			restored[s.Table+"/"+s.Column+"/"+s.ID] = true
+			res.SecretsRestored++
+		}
+
+		// Anything left is still sealed with the old key. It opens if this is the same machine;
+		// otherwise clear it so the app asks for it again instead of failing later.
+		for _, sc := range appStateSecretColumns {
+			rows, err := selectSecrets(tx, sc)
+			if err != nil {
+				return fmt.Errorf("read %s.%s: %w", sc.Table, sc.Column, err)
+			}
+			for _, r := range rows {
+				if restored[sc.Table+"/"+sc.Column+"/"+r.ID] {
+					continue
+				}
+				if _, err := crypto.Decrypt(r.Value, encKey); err == nil {
+					continue
+				}
+				q := tx.Table(sc.Table).Where("CAST(id AS TEXT) = ?", r.ID)
+				if sc.Table == "app_settings" {
+					err = q.Delete(nil).Error
+				} else {
+					err = q.Update(sc.Column, "").Error
+				}
+				if err != nil {
+					return err
+				}
+				res.SecretsCleared++
+			}
+		}
+		return nil
+	})
+}
+
+// stageConfig writes the backed-up config with its paths remapped. The app data directory
+// always stays the current one: that's where the config is read from.
+func stageConfig(a *appStateArchive, staging string, cfg *config.AppConfig, pathMap map[string]string) error {
+	raw := filepath.Join(staging, appStateConfigEntry+".orig")
+	if err := a.extract(appStateConfigEntry, raw); err != nil {
+		return fmt.Errorf("extract config: %w", err)
+	}
+	defer os.Remove(raw)
+	old, err := config.ReadFile(raw)
+	if err != nil {
+		return fmt.Errorf("read backed-up config: %w", err)
+	}
+	for _, p := range []*string{&old.LogDir, &old.SteamCMDPath, &old.DefaultInstallDir, &old.BackupDir} {
+		*p, _ = remapPath(*p, pathMap)
+	}
+	old.AppDataDir = cfg.AppDataDir
+	return old.SaveAs(filepath.Join(staging, appStateConfigEntry))
+}
+
+// remapPath rewrites p using the longest matching prefix in pathMap. Prefixes only match
+// whole path elements, so C:\srv doesn't rewrite C:\srv2.
+func remapPath(p string, pathMap map[string]string) (string, bool) {
+	best := ""
+	for from := range pathMap {
+		if len(from) > len(best) && pathHasPrefix(p, from) {
+			best = from
+		}
+	}
+	if best == "" || pathMap[best] == best {
+		return p, false
+	}
+	return pathMap[best] + p[len(best):], true
+}
+
+func pathHasPrefix(p, prefix string) bool {
+	prefix = strings.TrimRight(prefix, `/\`)
+	if prefix == "" || !strings.HasPrefix(strings.ToLower(p), strings.ToLower(prefix)) {
+		return false
+	}
+	return len(p) == len(prefix) || p[len(prefix)] == '/' || p[len(prefix)] == '\\'
+}
+
+// ApplyStagedAppState swaps in a restore staged by StageAppStateRestore. It must run before
+// the database is opened. The replaced database and config are kept alongside with a
+// .pre-restore suffix. It reports whether a restore was applied.
+func ApplyStagedAppState(appDataDir string) (bool, error) {
+	staging := filepath.Join(appDataDir, appStateStaging)
+	if _, err := os.Stat(filepath.Join(staging, appStateReady)); err != nil {
+		return false, nil
+	}
+
+	suffix := ".pre-restore-" + time.Now().Format("20060102_150405")
+	dbPath := filepath.Join(appDataDir, appStateDBEntry)
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+suffix); err != nil {
+			return false, fmt.Errorf("set aside current database: %w", err)
+		}
+	}
+	// The journal files belong to the database that was just moved aside.
+	for _, ext := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(dbPath + ext)
+	}
+	if err := os.Rename(filepath.Join(staging, appStateDBEntry), dbPath); err != nil {
+		return false, fmt.Errorf("install restored database: %w", err)
+	}
+
+	cfgPath := filepath.Join(appDataDir, appStateConfigEntry)
+	if _, err := os.Stat(filepath.Join(staging, appStateConfigEntry)); err == nil {
+		if _, err := os.Stat(cfgPath); err == nil {
+			if err := os.Rename(cfgPath, cfgPath+suffix); err != nil {
+				return true, fmt.Errorf("set aside current config: %w", err)
+			}
+		}
+		if err := os.Rename(filepath.Join(staging, appStateConfigEntry), cfgPath); err != nil {
+			return true, fmt.Errorf("install restored config: %w", err)
+		}
+	}
+	return true, os.RemoveAll(staging)
+}
+
+func isEncryptedFile(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(encMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, encMagic)
+}
+
+func writeJSON(p string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0600)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// copyLogs copies the log files; the current one is still being written, so a short read of
+// it is fine.
+func copyLogs(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return copyFile(p, filepath.Join(dst, rel))
+	})
+}
@@ -2,10 +2,16 @@ package backup
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"cs2admin/internal/models"
@@ -23,24 +29,50 @@ const (
 	BackupConfigOnly BackupType = "config"
 	BackupMapsOnly   BackupType = "maps"
 	BackupPluginsOnly BackupType = "plugins"
+	// BackupCustomOnly captures everything SteamCMD can't re-download: cfgs, addons, custom
+	// maps, plugin data and modified stock files. Needs a stock baseline (see RecordBaseline).
+	BackupCustomOnly BackupType = "custom"
+	// BackupPreRestore is the safety copy of files a restore is about to overwrite.
+	BackupPreRestore BackupType = "pre_restore"
 )
 
-// Create creates a zip backup based on type and saves the Backup record to DB.
-func Create(db *gorm.DB, instanceID string, installPath string, backupDir string, bType BackupType) (*models.Backup, error) {
+// Storage formats recorded on models.Backup.Format.
+const (
+	// FormatZip is a self-contained zip archive at Backup.Path.
+	FormatZip = "zip"
+	// FormatSnapshot is a manifest at Backup.Path whose file chunks live in the shared Store.
+	FormatSnapshot = "snapshot"
+	// FormatTarZst is a tar stream compressed with zstd at Backup.Path.
+	FormatTarZst = "tar.zst"
+)
+
+// CreateOptions tunes how a backup is written.
+type CreateOptions struct {
+	// Passphrase, if set, encrypts the backup (see encrypt.go). Encrypted full backups are
+	// written as zips: chunks under per-backup keys couldn't be shared with other snapshots.
+	Passphrase string
+	// Format is the archive format for backups that aren't snapshots: FormatZip (the
+	// default) or FormatTarZst.
+	Format string
+	// Level is the tar.zst compression level, 1 (fastest) to 4 (smallest); 0 is the default (2).
+	Level int
+	// Concurrency caps the goroutines compressing a tar.zst; 0 uses every CPU, or half of
+	// them with LowPriority.
+	Concurrency int
+	// LowPriority runs the backup at reduced CPU priority, for backing up a live server.
+	LowPriority bool
+	// Progress, if set, is called a few times a second while files are archived.
+	Progress func(Progress)
+}
+
+// Create creates a backup based on type and saves the Backup record to DB.
+// Full backups go into the deduplicated snapshot store; the narrower types stay plain zips.
+func Create(db *gorm.DB, instanceID string, installPath string, backupDir string, bType BackupType, opts CreateOptions) (*models.Backup, error) {
 	// Ensure backup dir exists
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
 		return nil, fmt.Errorf("create backup dir: %w", err)
 	}
 
-	shortID := instanceID
-	if len(instanceID) > 8 {
-		shortID = instanceID[:8]
-	}
-
-	ts := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("cs2admin_%s_%s_%s.zip", shortID, string(bType), ts)
-	zipPath := filepath.Join(backupDir, filename)
-
 	// Determine source paths to backup
 	var sources []string
 	switch bType {
@@ -52,44 +84,124 @@ func Create(db *gorm.DB, instanceID string, installPath string, backupDir string
 		sources = []string{filepath.Join(installPath, "game", "csgo", "maps")}
 	case BackupPluginsOnly:
 		sources = []string{filepath.Join(installPath, "game", "csgo", "addons")}
+	case BackupCustomOnly:
+		sources = []string{installPath}
 	default:
 		return nil, fmt.Errorf("invalid backup type: %s", bType)
 	}
 
-	// Create zip
-	if err := createZip(zipPath, installPath, sources); err != nil {
-		return nil, fmt.Errorf("create zip: %w", err)
+	if opts.LowPriority {
+		defer lowerPriority()()
+		if opts.Concurrency == 0 {
+			opts.Concurrency = max(1, runtime.NumCPU()/2)
+		}
+	}
+
+	if bType == BackupFull && opts.Passphrase == "" {
+		return createSnapshot(db, instanceID, installPath, backupDir, bType, sources, newProgressMeter(opts.Progress))
+	}
+
+	var filter *stockFilter
+	if bType == BackupCustomOnly {
+		base, err := LoadBaseline(backupDir, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		filter = newStockFilter(installPath, base)
+	}
+
+	return createArchive(db, instanceID, installPath, backupDir, bType, sources, filter, opts)
+}
+
+// createArchive archives sources (directories or single files under installPath) into
+// backupDir in opts.Format and records the Backup. filter, if set, selects files and supplies
+// the backup's report. A non-empty passphrase encrypts the archive.
+func createArchive(db *gorm.DB, instanceID, installPath, backupDir string, bType BackupType, sources []string, filter *stockFilter, opts CreateOptions) (*models.Backup, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatZip
+	}
+	ext, err := archiveExt(format)
+	if err != nil {
+		return nil, err
+	}
+
+	shortID := instanceID
+	if len(instanceID) > 8 {
+		shortID = instanceID[:8]
+	}
+
+	ts := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("cs2admin_%s_%s_%s%s", shortID, string(bType), ts, ext)
+	if opts.Passphrase != "" {
+		filename += ".enc"
+	}
+	archivePath := filepath.Join(backupDir, filename)
+
+	// Create archive
+	var include includeFunc
+	if filter != nil {
+		include = filter.include
+	}
+	opts.Format = format
+	digest, err := writeArchive(archivePath, installPath, sources, include, opts)
+	if err != nil {
+		os.Remove(archivePath)
+		return nil, fmt.Errorf("create %s: %w", format, err)
+	}
+
+	var report string
+	if filter != nil {
+		r := filter.finish()
+		if r.BaselineStale {
+			logger.Log.Warn().
+				Str("instance", instanceID).
+				Str("build", r.BuildID).
+				Str("baseline_build", r.BaselineBuildID).
+				Msg("backup: stock baseline is from an older build; updated stock files were included")
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			os.Remove(archivePath)
+			return nil, fmt.Errorf("marshal report: %w", err)
+		}
+		report = string(data)
 	}
 
 	// Get file size
-	info, err := os.Stat(zipPath)
+	info, err := os.Stat(archivePath)
 	if err != nil {
-		os.Remove(zipPath)
+		os.Remove(archivePath)
 		return nil, fmt.Errorf("stat backup file: %w", err)
 	}
 
 	// Save DB record
 	instUUID, err := uuid.Parse(instanceID)
 	if err != nil {
-		os.Remove(zipPath)
+		os.Remove(archivePath)
 		return nil, fmt.Errorf("invalid instance id: %w", err)
 	}
 
 	b := &models.Backup{
-		InstanceID: instUUID,
-		Path:       zipPath,
-		SizeBytes:  info.Size(),
-		BackupType: string(bType),
+		InstanceID:     instUUID,
+		Path:           archivePath,
+		SizeBytes:      info.Size(),
+		StoredBytes:    info.Size(),
+		BackupType:     string(bType),
+		Format:         format,
+		Report:         report,
+		ManifestSHA256: digest,
+		Encrypted:      opts.Passphrase != "",
 	}
 
 	if err := db.Create(b).Error; err != nil {
-		os.Remove(zipPath)
+		os.Remove(archivePath)
 		return nil, fmt.Errorf("save backup record: %w", err)
 	}
 
 	logger.Log.Info().
 		Str("instance", instanceID).
-		Str("path", zipPath).
+		Str("path", archivePath).
 		Int64("size", info.Size()).
 		Str("type", string(bType)).
 		Msg("backup: created")
@@ -97,15 +209,187 @@ func Create(db *gorm.DB, instanceID string, installPath string, backupDir string
 	return b, nil
 }
 
-func createZip(zipPath, rootPath string, sourceDirs []string) error {
-	f, err := os.Create(zipPath)
+// createSnapshot stores sources in the shared blob store, reusing chunks from the instance's
+// previous snapshot, and records the manifest as a Backup.
+func createSnapshot(db *gorm.DB, instanceID, installPath, backupDir string, bType BackupType, sources []string, progress *progressMeter) (*models.Backup, error) {
+	instUUID, err := uuid.Parse(instanceID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid instance id: %w", err)
+	}
+
+	store, err := OpenStore(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	progress.scan(sources)
+	snap, stats, err := store.CreateSnapshot(instanceID, installPath, sources, latestSnapshot(db, instanceID), progress)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+	progress.done()
+
+	b := &models.Backup{
+		ID:          uuid.New(),
+		InstanceID:  instUUID,
+		SizeBytes:   stats.TotalBytes,
+		StoredBytes: stats.StoredBytes,
+		BackupType:  string(bType),
+		Format:      FormatSnapshot,
+	}
+	manifestPath, err := store.WriteSnapshot(b.ID.String(), snap)
+	if err != nil {
+		return nil, err
+	}
+	b.Path = manifestPath
+	if b.ManifestSHA256, err = hashFile(manifestPath); err != nil {
+		os.Remove(manifestPath)
+		return nil, fmt.Errorf("hash snapshot manifest: %w", err)
+	}
+
+	if err := db.Create(b).Error; err != nil {
+		os.Remove(manifestPath)
+		return nil, fmt.Errorf("save backup record: %w", err)
+	}
+
+	logger.Log.Info().
+		Str("instance", instanceID).
+		Str("path", manifestPath).
+		Int("files", stats.Files).
+		Int("reused_files", stats.ReusedFiles).
+		Int("new_chunks", stats.NewChunks).
+		Int64("size", stats.TotalBytes).
+		Int64("stored", stats.StoredBytes).
+		Str("type", string(bType)).
+		Msg("backup: snapshot created")
+
+	return b, nil
+}
+
+// latestSnapshot returns the instance's most recent snapshot manifest, or nil if there is none
+// or it can't be read (the next snapshot then simply re-reads every file).
+func latestSnapshot(db *gorm.DB, instanceID string) *Snapshot {
+	var b models.Backup
+	if err := db.Where("instance_id = ? AND format = ?", instanceID, FormatSnapshot).
+		Order("created_at DESC").First(&b).Error; err != nil {
+		return nil
+	}
+	snap, err := ReadSnapshot(b.Path)
+	if err != nil {
+		logger.Log.Warn().Err(err).Str("path", b.Path).Msg("backup: previous snapshot unreadable")
+		return nil
+	}
+	return snap
+}
+
+// includeFunc decides whether a file (rel to the root, absolute path) goes into an archive.
+type includeFunc func(rel, path string, info os.FileInfo) (bool, error)
+
+// writeArchive writes sourceDirs as an opts.Format archive to archivePath, encrypted when
+// opts.Passphrase is set, and returns the digest verification checks: the manifest's for plain
+// archives, the whole file's for encrypted ones (so they can be verified without the passphrase).
+func writeArchive(archivePath, rootPath string, sourceDirs []string, include includeFunc, opts CreateOptions) (string, error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
 	}
 	defer f.Close()
 
-	w := zip.NewWriter(f)
-	defer w.Close()
+	var out io.Writer = f
+	var ew io.WriteCloser
+	if opts.Passphrase != "" {
+		if ew, err = newEncryptWriter(f, opts.Passphrase); err != nil {
+			return "", err
+		}
+		out = ew
+	}
+
+	aw, err := newArchiveWriter(out, opts)
+	if err != nil {
+		return "", err
+	}
+	progress := newProgressMeter(opts.Progress)
+	if include == nil {
+		progress.scan(sourceDirs)
+	}
+	digest, err := writeEntries(aw, rootPath, sourceDirs, include, progress)
+	if cerr := aw.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	progress.done()
+
+	if ew == nil {
+		return digest, f.Close()
+	}
+	if err := ew.Close(); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hashFile(archivePath)
+}
+
+// archiveWriter is what writeEntries needs from an archive format.
+type archiveWriter interface {
+	dir(rel string) error
+	file(rel string, size int64, mode os.FileMode, modTime time.Time, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(out io.Writer, opts CreateOptions) (archiveWriter, error) {
+	switch opts.Format {
+	case FormatZip, "":
+		return &zipWriter{w: zip.NewWriter(out)}, nil
+	case FormatTarZst:
+		return newTarZstWriter(out, opts.Level, opts.Concurrency)
+	default:
+		return nil, fmt.Errorf("unknown backup format: %s", opts.Format)
+	}
+}
+
+func archiveExt(format string) (string, error) {
+	switch format {
+	case FormatZip:
+		return ".zip", nil
+	case FormatTarZst:
+		return ".tar.zst", nil
+	default:
+		return "", fmt.Errorf("unknown backup format: %s", format)
+	}
+}
+
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func (z *zipWriter) dir(rel string) error {
+	_, err := z.w.Create(rel + "/")
+	return err
+}
+
+func (z *zipWriter) file(rel string, size int64, mode os.FileMode, modTime time.Time, r io.Reader) error {
+	zf, err := z.w.Create(rel)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zf, r)
+	return err
+}
+
+func (z *zipWriter) Close() error { return z.w.Close() }
+
+// writeEntries writes sourceDirs into aw with names relative to rootPath. With a non-nil
+// include only accepted files are written (and no directory entries). A Manifest of every
+// file goes in last; its sha256 is returned.
+func writeEntries(aw archiveWriter, rootPath string, sourceDirs []string, include includeFunc, progress *progressMeter) (string, error) {
+	manifest := Manifest{Version: manifestVersion, CreatedAt: time.Now().UTC()}
 
 	for _, dir := range sourceDirs {
 		if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -120,91 +404,61 @@ func createZip(zipPath, rootPath string, sourceDirs []string) error {
 			if err != nil {
 				rel = path
 			}
-			// Ensure forward slashes in zip
+			// Ensure forward slashes in archive
 			rel = filepath.ToSlash(rel)
 
 			if info.IsDir() {
-				rel += "/"
-				_, err := w.Create(rel)
-				return err
+				if include != nil {
+					return nil
+				}
+				return aw.dir(rel)
 			}
 
-			zf, err := w.Create(rel)
-			if err != nil {
-				return err
+			if include != nil {
+				ok, err := include(rel, path, info)
+				if err != nil || !ok {
+					return err
+				}
 			}
 
 			in, err := os.Open(path)
 			if err != nil {
 				return err
 			}
-			_, err = io.Copy(zf, in)
-			in.Close()
-			return err
+			defer in.Close()
+			h := sha256.New()
+			cr := &countingReader{r: io.TeeReader(meterReader{in, progress}, h)}
+			if err := aw.file(rel, info.Size(), info.Mode().Perm(), info.ModTime(), cr); err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+			progress.file()
+			manifest.Files = append(manifest.Files, ManifestFile{Path: rel, Size: cr.n, SHA256: hex.EncodeToString(h.Sum(nil))})
+			return nil
 		}); err != nil {
-			return err
+			return "", err
 		}
 	}
 
-	return nil
-}
-
-// Restore extracts the backup zip to installPath.
-func Restore(db *gorm.DB, backupID string, installPath string) error {
-	var b models.Backup
-	if err := db.First(&b, "id = ?", backupID).Error; err != nil {
-		return fmt.Errorf("backup not found: %w", err)
-	}
-
-	if _, err := os.Stat(b.Path); err != nil {
-		return fmt.Errorf("backup file missing: %w", err)
-	}
-
-	r, err := zip.OpenReader(b.Path)
+	data, err := json.Marshal(manifest)
 	if err != nil {
-		return fmt.Errorf("open zip: %w", err)
+		return "", fmt.Errorf("marshal manifest: %w", err)
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		dest := filepath.Join(installPath, filepath.FromSlash(f.Name))
-
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(dest, 0755); err != nil {
-				return fmt.Errorf("mkdir %s: %w", dest, err)
-			}
-			continue
-		}
-
-		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
-			return fmt.Errorf("mkdir parent %s: %w", dest, err)
-		}
-
-		rc, err := f.Open()
-		if err != nil {
-			return fmt.Errorf("open zip entry %s: %w", f.Name, err)
-		}
-
-		out, err := os.Create(dest)
-		if err != nil {
-			rc.Close()
-			return fmt.Errorf("create %s: %w", dest, err)
-		}
-
-		_, err = io.Copy(out, rc)
-		rc.Close()
-		out.Close()
-		if err != nil {
-			return fmt.Errorf("write %s: %w", dest, err)
-		}
+	if err := aw.file(manifestEntry, int64(len(data)), 0644, manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return "", err
 	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-	logger.Log.Info().
-		Str("backup_id", backupID).
-		Str("install_path", installPath).
-		Msg("backup: restored")
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	return nil
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // List returns backups for the given instance.
@@ -214,7 +468,7 @@ func List(db *gorm.DB, instanceID string) ([]models.Backup, error) {
 	return backups, err
 }
 
-// Delete removes the backup file and DB record.
+// Delete removes the backup file, its remote copy and the DB record.
 func Delete(db *gorm.DB, backupID string) error {
 	var b models.Backup
 	if err := db.First(&b, "id = ?", backupID).Error; err != nil {
@@ -225,10 +479,27 @@ func Delete(db *gorm.DB, backupID string) error {
 		logger.Log.Warn().Err(err).Str("path", b.Path).Msg("backup: delete file failed")
 	}
 
+	if b.RemoteTargetID != "" {
+		if err := deleteRemote(context.Background(), db, &b); err != nil {
+			logger.Log.Warn().Err(err).Str("backup_id", backupID).Str("key", b.RemoteKey).Msg("backup: delete remote copy failed")
+		}
+	}
+
 	if err := db.Delete(&b).Error; err != nil {
 		return fmt.Errorf("delete backup record: %w", err)
 	}
 
+	if b.Format == FormatSnapshot {
+		// Drop the chunks that only this snapshot referenced.
+		store := &Store{root: filepath.Dir(filepath.Dir(b.Path))}
+		storeMu.Lock()
+		_, err := store.GC()
+		storeMu.Unlock()
+		if err != nil {
+			logger.Log.Warn().Err(err).Str("backup_id", backupID).Msg("backup: store gc failed")
+		}
+	}
+
 	logger.Log.Info().Str("backup_id", backupID).Msg("backup: deleted")
 	return nil
 }
@@ -0,0 +1,201 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cs2admin/internal/pkg/crypto"
+	"cs2admin/internal/pkg/logger"
+)
+
+// Encrypted backups are a zip run through crypto.NewStreamWriter under a random per-backup data
+// key. The data key is stored in the file header, wrapped with a key derived from the user's
+// passphrase, so the file is self-contained: it restores on any machine given the passphrase.
+//
+// Layout: encMagic | uint32 header length | header JSON | encrypted stream.
+var encMagic = []byte("CS2AENC1")
+
+// encKDFIterations is the PBKDF2-SHA256 work factor for new backups. Headers asking for
+// less than minKDFIterations or more than maxKDFIterations are refused: too few is weak,
+// too many would hang the app deriving the key.
+const (
+	encKDFIterations = 600_000
+	minKDFIterations = 100_000
+	maxKDFIterations = 10_000_000
+)
+
+// decryptTempPattern names the plaintext copies decryptToTemp writes, and appStateTempPattern
+// the directories CreateAppState stages a backup in; both live in decryptTempDir.
+const (
+	decryptTempPattern  = ".cs2admin-decrypt-*"
+	appStateTempPattern = ".cs2admin-appstate-*"
+)
+
+// ErrWrongPassphrase is returned when an encrypted backup can't be unlocked.
+var ErrWrongPassphrase = errors.New("wrong backup passphrase")
+
+// ErrPassphraseRequired is returned when restoring an encrypted backup without a passphrase.
+var ErrPassphraseRequired = errors.New("backup is encrypted; a passphrase is required")
+
+type encHeader struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`        // base64
+	WrappedKey string `json:"wrapped_key"` // data key sealed by crypto.Encrypt under the passphrase key
+}
+
+// newEncryptWriter writes the encrypted-backup header to w and returns a writer for the
+// plaintext. Closing it finishes the stream but does not close w.
+func newEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	salt, err := crypto.GenerateRandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := crypto.PassphraseKey(passphrase, salt, encKDFIterations)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := crypto.GenerateRandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := crypto.Encrypt(string(dataKey), kek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+
+	hdr, err := json.Marshal(encHeader{
+		KDF:        "pbkdf2-sha256",
+		Iterations: encKDFIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		WrappedKey: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prefix bytes.Buffer
+	prefix.Write(encMagic)
+	binary.Write(&prefix, binary.BigEndian, uint32(len(hdr)))
+	prefix.Write(hdr)
+	if _, err := w.Write(prefix.Bytes()); err != nil {
+		return nil, err
+	}
+	return crypto.NewStreamWriter(w, dataKey)
+}
+
+// newDecryptReader reads the encrypted-backup header from r and returns a reader for the
+// plaintext.
+func newDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	magic := make([]byte, len(encMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, encMagic) {
+		return nil, errors.New("not an encrypted backup")
+	}
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if n > 64<<10 {
+		return nil, errors.New("encrypted backup header too large")
+	}
+	raw := make([]byte, n)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	var hdr encHeader
+	if err := json.Unmarshal(raw, &hdr); err != nil {
+		return nil, fmt.Errorf("parse header: %w", err)
+	}
+	if hdr.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported key derivation: %s", hdr.KDF)
+	}
+	if hdr.Iterations < minKDFIterations || hdr.Iterations > maxKDFIterations {
+		return nil, fmt.Errorf("encrypted backup header asks for %d key derivation rounds; expected %d to %d",
+			hdr.Iterations, minKDFIterations, maxKDFIterations)
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(hdr.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("parse header: %w", err)
+	}
+	kek, err := crypto.PassphraseKey(passphrase, salt, hdr.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := crypto.Decrypt(hdr.WrappedKey, kek)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return crypto.NewStreamReader(r, []byte(dataKey))
+}
+
+// decryptTempDir is a private directory for plaintext copies of encrypted backups and
+// app-state staging, outside the backup directory so a crash can't leave secrets where
+// backups are synced and uploaded.
+func decryptTempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "cs2admin-decrypt")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// MkdirAll leaves an existing directory's mode alone.
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// decryptToTemp decrypts the backup at p into a private temp file, for formats that need
+// random access. The caller removes the returned file.
+func decryptToTemp(p, passphrase string) (string, error) {
+	in, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	plain, err := newDecryptReader(in, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := decryptTempDir()
+	if err != nil {
+		return "", fmt.Errorf("decrypt backup: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, decryptTempPattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, plain)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("decrypt backup: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// RemoveStaleDecrypts deletes plaintext copies of encrypted backups and app-state staging
+// directories left in the private temp dir by a crash.
+func RemoveStaleDecrypts() {
+	dir := filepath.Join(os.TempDir(), "cs2admin-decrypt")
+	patterns := []string{filepath.Join(dir, decryptTempPattern), filepath.Join(dir, appStateTempPattern)}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			if err := os.RemoveAll(m); err != nil {
+				logger.Log.Warn().Err(err).Str("path", m).Msg("backup: remove stale decrypted copy failed")
+			}
+		}
+	}
+}
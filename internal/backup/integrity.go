@@ -0,0 +1,369 @@
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cs2admin/internal/models"
+	"cs2admin/internal/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// manifestEntry is where a zip backup keeps its Manifest.
+const manifestEntry = ".cs2admin/manifest.json"
+
+const manifestVersion = 1
+
+// Verification outcomes recorded on models.Backup.VerifyStatus.
+const (
+	VerifyOK      = "ok"
+	VerifyCorrupt = "corrupt"
+	VerifyMissing = "missing"
+)
+
+// Manifest lists every file in a zip backup with its size and SHA-256.
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// ManifestFile is one file in a Manifest.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// VerifyResult is the outcome of checking one backup.
+type VerifyResult struct {
+	BackupID   string    `json:"backup_id"`
+	InstanceID string    `json:"instance_id"`
+	Status     string    `json:"status"`
+	Files      int       `json:"files"`
+	Bytes      int64     `json:"bytes"`
+	Problems   []string  `json:"problems"`
+	Legacy     bool      `json:"legacy"` // zip from before manifests; only the zip CRCs were checked
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// maxReportedProblems caps the problems kept per backup so one rotten archive can't flood the DB.
+const maxReportedProblems = 20
+
+func (r *VerifyResult) problem(format string, args ...any) {
+	if len(r.Problems) < maxReportedProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// VerifyBackup re-reads a backup, checks it against its manifest and records the outcome on
+// the Backup row.
+func VerifyBackup(db *gorm.DB, backupID string) (*VerifyResult, error) {
+	var b models.Backup
+	if err := db.First(&b, "id = ?", backupID).Error; err != nil {
+		return nil, fmt.Errorf("backup not found: %w", err)
+	}
+	r := verify(&b)
+	if err := recordVerify(db, &b, r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// VerifyAll checks every backup and returns the results, healthy ones included.
+func VerifyAll(db *gorm.DB) ([]VerifyResult, error) {
+	var backups []models.Backup
+	if err := db.Order("created_at").Find(&backups).Error; err != nil {
+		return nil, fmt.Errorf("load backups: %w", err)
+	}
+	results := make([]VerifyResult, 0, len(backups))
+	for i := range backups {
+		r := verify(&backups[i])
+		if err := recordVerify(db, &backups[i], r); err != nil {
+			logger.Log.Warn().Err(err).Str("backup_id", r.BackupID).Msg("backup: save verify result failed")
+		}
+		results = append(results, *r)
+	}
+	return results, nil
+}
+
+func recordVerify(db *gorm.DB, b *models.Backup, r *VerifyResult) error {
+	if r.Status != VerifyOK {
+		logger.Log.Warn().
+			Str("backup_id", r.BackupID).
+			Str("status", r.Status).
+			Strs("problems", r.Problems).
+			Msg("backup: verification failed")
+	}
+	return db.Model(b).Updates(map[string]interface{}{
+		"verify_status": r.Status,
+		"verify_error":  strings.Join(r.Problems, "\n"),
+		"verified_at":   r.CheckedAt,
+	}).Error
+}
+
+func verify(b *models.Backup) *VerifyResult {
+	r := &VerifyResult{
+		BackupID:   b.ID.String(),
+		InstanceID: b.InstanceID.String(),
+		Status:     VerifyOK,
+		CheckedAt:  time.Now().UTC(),
+	}
+	if _, err := os.Stat(b.Path); err != nil {
+		r.Status = VerifyMissing
+		r.problem("backup file: %v", err)
+		if b.RemoteKey != "" {
+			r.problem("a remote copy exists (%s); restoring downloads it", b.RemoteKey)
+		}
+		return r
+	}
+
+	switch {
+	case b.Encrypted:
+		verifyEncrypted(b, r)
+	case b.Format == FormatSnapshot:
+		verifySnapshot(b, r)
+	case b.Format == FormatTarZst:
+		verifyTarZst(b, r)
+	case b.Format == FormatZip || b.Format == "":
+		verifyZip(b, r)
+	default:
+		r.problem("unknown backup format: %s", b.Format)
+	}
+	if len(r.Problems) > 0 && r.Status == VerifyOK {
+		r.Status = VerifyCorrupt
+	}
+	return r
+}
+
+func verifyZip(b *models.Backup, r *VerifyResult) {
+	zr, err := zip.OpenReader(b.Path)
+	if err != nil {
+		r.problem("open zip: %v", err)
+		return
+	}
+	defer zr.Close()
+
+	entries := make(map[string]*zip.File, len(zr.File))
+	var mf *zip.File
+	for _, f := range zr.File {
+		if f.Name == manifestEntry {
+			mf = f
+		} else if !f.FileInfo().IsDir() {
+			entries[f.Name] = f
+		}
+	}
+
+	if mf == nil {
+		if b.ManifestSHA256 != "" {
+			r.problem("integrity manifest missing from archive")
+			return
+		}
+		// Zips from before manifests existed: reading each entry still checks its CRC32.
+		r.Legacy = true
+		for name, f := range entries {
+			n, _, err := hashZipEntry(f)
+			if err != nil {
+				r.problem("%s: %v", name, err)
+				continue
+			}
+			r.Files++
+			r.Bytes += n
+		}
+		return
+	}
+
+	data, err := readZipEntry(mf)
+	if err != nil {
+		r.problem("read manifest: %v", err)
+		return
+	}
+	if sum := sha256.Sum256(data); b.ManifestSHA256 != "" && hex.EncodeToString(sum[:]) != b.ManifestSHA256 {
+		r.problem("manifest digest does not match the recorded one")
+		return
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		r.problem("parse manifest: %v", err)
+		return
+	}
+
+	for _, want := range m.Files {
+		f, ok := entries[want.Path]
+		if !ok {
+			r.problem("%s: missing from archive", want.Path)
+			continue
+		}
+		delete(entries, want.Path)
+		n, sum, err := hashZipEntry(f)
+		switch {
+		case err != nil:
+			r.problem("%s: %v", want.Path, err)
+		case n != want.Size:
+			r.problem("%s: size %d, manifest says %d", want.Path, n, want.Size)
+		case sum != want.SHA256:
+			r.problem("%s: checksum mismatch", want.Path)
+		default:
+			r.Files++
+			r.Bytes += n
+		}
+	}
+	for name := range entries {
+		r.problem("%s: not in manifest", name)
+	}
+}
+
+// verifyTarZst reads the whole stream, which also checks zstd's frame checksums, and compares
+// every file with the manifest at its end.
+func verifyTarZst(b *models.Backup, r *VerifyResult) {
+	got, data, err := hashTarZst(b.Path)
+	if err != nil {
+		r.problem("read archive: %v", err)
+		return
+	}
+	if data == nil {
+		r.problem("integrity manifest missing from archive")
+		return
+	}
+	if sum := sha256.Sum256(data); b.ManifestSHA256 != "" && hex.EncodeToString(sum[:]) != b.ManifestSHA256 {
+		r.problem("manifest digest does not match the recorded one")
+		return
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		r.problem("parse manifest: %v", err)
+		return
+	}
+
+	for _, want := range m.Files {
+		f, ok := got[want.Path]
+		if !ok {
+			r.problem("%s: missing from archive", want.Path)
+			continue
+		}
+		delete(got, want.Path)
+		switch {
+		case f.Size != want.Size:
+			r.problem("%s: size %d, manifest says %d", want.Path, f.Size, want.Size)
+		case f.SHA256 != want.SHA256:
+			r.problem("%s: checksum mismatch", want.Path)
+		default:
+			r.Files++
+			r.Bytes += f.Size
+		}
+	}
+	for name := range got {
+		r.problem("%s: not in manifest", name)
+	}
+}
+
+// verifyEncrypted checks an encrypted backup against the digest of its ciphertext, so it can
+// run without the passphrase. A match proves the file is exactly what was written.
+func verifyEncrypted(b *models.Backup, r *VerifyResult) {
+	if b.ManifestSHA256 == "" {
+		r.problem("no recorded digest for encrypted backup")
+		return
+	}
+	sum, err := hashFile(b.Path)
+	if err != nil {
+		r.problem("read backup: %v", err)
+		return
+	}
+	if sum != b.ManifestSHA256 {
+		r.problem("encrypted backup does not match its recorded digest")
+		return
+	}
+	r.Files = 1
+	r.Bytes = b.SizeBytes
+}
+
+func verifySnapshot(b *models.Backup, r *VerifyResult) {
+	if b.ManifestSHA256 != "" {
+		sum, err := hashFile(b.Path)
+		if err != nil {
+			r.problem("read manifest: %v", err)
+			return
+		}
+		if sum != b.ManifestSHA256 {
+			r.problem("manifest digest does not match the recorded one")
+			return
+		}
+	}
+	snap, err := ReadSnapshot(b.Path)
+	if err != nil {
+		r.problem("%v", err)
+		return
+	}
+
+	store := &Store{root: filepath.Dir(filepath.Dir(b.Path))}
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	// Snapshots share chunks; check each one once.
+	chunkSizes := make(map[string]int64)
+	for _, f := range snap.Files {
+		var size int64
+		ok := true
+		for _, c := range f.Chunks {
+			n, seen := chunkSizes[c]
+			if !seen {
+				data, err := store.readChunk(c)
+				if err != nil {
+					r.problem("%s: %v", f.Path, err)
+					chunkSizes[c] = -1
+					ok = false
+					continue
+				}
+				n = int64(len(data))
+				chunkSizes[c] = n
+			}
+			if n < 0 {
+				ok = false
+				continue
+			}
+			size += n
+		}
+		if !ok {
+			continue
+		}
+		if size != f.Size {
+			r.problem("%s: chunks hold %d bytes, manifest says %d", f.Path, size, f.Size)
+			continue
+		}
+		r.Files++
+		r.Bytes += size
+	}
+}
+
+func readZipEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// hashZipEntry reads an entry to the end, which also makes archive/zip check its CRC32.
+func hashZipEntry(f *zip.File) (int64, string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return n, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package backup
+
+// lowerPriority is a no-op on non-Windows platforms.
+func lowerPriority() func() { return func() {} }
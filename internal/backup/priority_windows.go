@@ -0,0 +1,44 @@
+//go:build windows
+
+package backup
+
+import (
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	priorityMu    sync.Mutex
+	priorityUsers int
+	priorityPrev  uint32
+)
+
+// lowerPriority drops the app to below-normal CPU priority until the returned func is called,
+// so backing up a live server doesn't take CPU from it. Overlapping backups share one
+// lowering; the priority comes back when the last one finishes.
+func lowerPriority() func() {
+	priorityMu.Lock()
+	defer priorityMu.Unlock()
+
+	h := windows.CurrentProcess()
+	if priorityUsers == 0 {
+		prev, err := windows.GetPriorityClass(h)
+		if err != nil || windows.SetPriorityClass(h, windows.BELOW_NORMAL_PRIORITY_CLASS) != nil {
+			return func() {}
+		}
+		priorityPrev = prev
+	}
+	priorityUsers++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			priorityMu.Lock()
+			defer priorityMu.Unlock()
+			if priorityUsers--; priorityUsers == 0 {
+				_ = windows.SetPriorityClass(h, priorityPrev)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// progressInterval throttles progress callbacks; the UI doesn't need more than a few a second.
+const progressInterval = 250 * time.Millisecond
+
+// Progress reports how far a backup has got.
+type Progress struct {
+	Stage       string  `json:"stage"` // "scanning", "archiving", "complete"
+	Files       int     `json:"files"`
+	Bytes       int64   `json:"bytes"`
+	TotalBytes  int64   `json:"total_bytes"` // 0 when unknown (filtered backups)
+	Percent     float64 `json:"percent"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+}
+
+// progressMeter turns byte counts into throttled Progress callbacks. A nil meter does nothing.
+type progressMeter struct {
+	fn    func(Progress)
+	total int64
+	bytes int64
+	files int
+	start time.Time
+	last  time.Time
+}
+
+func newProgressMeter(fn func(Progress)) *progressMeter {
+	if fn == nil {
+		return nil
+	}
+	now := time.Now()
+	return &progressMeter{fn: fn, start: now, last: now}
+}
+
+// scan sizes sourceDirs up front so progress can show a percentage. Walking only stats files,
+// which is cheap next to reading them.
+func (m *progressMeter) scan(sourceDirs []string) {
+	if m == nil {
+		return
+	}
+	m.fn(Progress{Stage: "scanning"})
+	for _, dir := range sourceDirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				m.total += info.Size()
+			}
+			return nil
+		})
+	}
+}
+
+func (m *progressMeter) add(n int64) {
+	if m == nil {
+		return
+	}
+	m.bytes += n
+	if now := time.Now(); now.Sub(m.last) >= progressInterval {
+		m.last = now
+		m.emit("archiving")
+	}
+}
+
+func (m *progressMeter) file() {
+	if m != nil {
+		m.files++
+	}
+}
+
+func (m *progressMeter) done() {
+	if m != nil {
+		m.emit("complete")
+	}
+}
+
+func (m *progressMeter) emit(stage string) {
+	p := Progress{Stage: stage, Files: m.files, Bytes: m.bytes, TotalBytes: m.total}
+	if m.total > 0 {
+		p.Percent = min(100, float64(m.bytes)/float64(m.total)*100)
+	}
+	if secs := time.Since(m.start).Seconds(); secs > 0 {
+		p.BytesPerSec = float64(m.bytes) / secs
+	}
+	m.fn(p)
+}
+
+// meterReader counts bytes read through it into a progressMeter.
+type meterReader struct {
+	r io.Reader
+	m *progressMeter
+}
+
+func (r meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.m.add(int64(n))
+	return n, err
+}
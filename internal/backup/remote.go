@@ -0,0 +1,282 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cs2admin/internal/models"
+	"cs2admin/internal/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Remote layout: zip backups live under "<instance id>/<file name>"; snapshots mirror the
+// local store, so chunks shared between snapshots are uploaded once.
+const (
+	remoteManifests = "store/manifests/"
+	remoteBlobs     = "store/blobs/"
+)
+
+// RemoteBackup is an object on a target together with the backup it belongs to, if known.
+type RemoteBackup struct {
+	RemoteObject
+	BackupID string `json:"backup_id,omitempty"`
+}
+
+// Upload copies a backup to the target and records the remote location on the backup.
+func Upload(ctx context.Context, db *gorm.DB, backupID, targetID string) error {
+	var b models.Backup
+	if err := db.First(&b, "id = ?", backupID).Error; err != nil {
+		return fmt.Errorf("backup not found: %w", err)
+	}
+	if _, err := os.Stat(b.Path); err != nil {
+		return fmt.Errorf("backup file missing: %w", err)
+	}
+	t, err := OpenTarget(db, targetID)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	var key string
+	switch b.Format {
+	case FormatSnapshot:
+		key, err = uploadSnapshot(ctx, t, &b)
+	default:
+		key = path.Join(b.InstanceID.String(), filepath.Base(b.Path))
+		err = t.Put(ctx, key, b.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+
+	// Uploading elsewhere replaces the record of the old copy; remove it so it isn't orphaned.
+	if b.RemoteTargetID != "" && (b.RemoteTargetID != targetID || b.RemoteKey != key) {
+		if err := deleteRemote(ctx, db, &b); err != nil {
+			logger.Log.Warn().Err(err).Str("backup_id", backupID).Msg("backup: delete previous remote copy failed")
+		}
+	}
+
+	now := time.Now()
+	if err := db.Model(&b).Updates(map[string]interface{}{
+		"remote_target_id": targetID,
+		"remote_key":       key,
+		"uploaded_at":      &now,
+	}).Error; err != nil {
+		return fmt.Errorf("save remote location: %w", err)
+	}
+
+	logger.Log.Info().
+		Str("backup_id", backupID).
+		Str("target", targetID).
+		Str("key", key).
+		Msg("backup: uploaded")
+	return nil
+}
+
+func uploadSnapshot(ctx context.Context, t Target, b *models.Backup) (string, error) {
+	snap, err := ReadSnapshot(b.Path)
+	if err != nil {
+		return "", err
+	}
+	store := &Store{root: filepath.Dir(filepath.Dir(b.Path))}
+
+	// Chunks are immutable and named by content, so anything already there can be skipped.
+	existing, err := t.List(ctx, remoteBlobs)
+	if err != nil {
+		return "", fmt.Errorf("list remote chunks: %w", err)
+	}
+	have := make(map[string]bool, len(existing))
+	for _, o := range existing {
+		have[o.Key] = true
+	}
+
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	for _, f := range snap.Files {
+		for _, c := range f.Chunks {
+			key := remoteBlobKey(c)
+			if have[key] {
+				continue
+			}
+			if err := t.Put(ctx, key, store.blobPath(c)); err != nil {
+				return "", err
+			}
+			have[key] = true
+		}
+	}
+
+	// The manifest goes last: a snapshot is only visible remotely once all its chunks are.
+	key := remoteManifests + filepath.Base(b.Path)
+	if err := t.Put(ctx, key, b.Path); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func remoteBlobKey(hash string) string {
+	return remoteBlobs + hash[:2] + "/" + hash
+}
+
+// Fetch downloads the remote copy of a backup whose local file is gone. It is a no-op when the
+// local file exists.
+func Fetch(ctx context.Context, db *gorm.DB, b *models.Backup) error {
+	if _, err := os.Stat(b.Path); err == nil {
+		return nil
+	}
+	if b.RemoteTargetID == "" {
+		return fmt.Errorf("backup file missing and no remote copy: %s", b.Path)
+	}
+	t, err := OpenTarget(db, b.RemoteTargetID)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if b.Format == FormatSnapshot {
+		err = fetchSnapshot(ctx, t, b)
+	} else {
+		err = t.Get(ctx, b.RemoteKey, b.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("download remote copy: %w", err)
+	}
+	logger.Log.Info().Str("backup_id", b.ID.String()).Str("key", b.RemoteKey).Msg("backup: fetched remote copy")
+	return nil
+}
+
+func fetchSnapshot(ctx context.Context, t Target, b *models.Backup) error {
+	store := &Store{root: filepath.Dir(filepath.Dir(b.Path))}
+	tmp := b.Path + ".remote"
+	if err := t.Get(ctx, b.RemoteKey, tmp); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	snap, err := ReadSnapshot(tmp)
+	if err != nil {
+		return err
+	}
+
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	for _, f := range snap.Files {
+		for _, c := range f.Chunks {
+			if _, err := os.Stat(store.blobPath(c)); err == nil {
+				continue
+			}
+			if err := t.Get(ctx, remoteBlobKey(c), store.blobPath(c)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(tmp, b.Path)
+}
+
+// ListRemote lists the backups on a target, matched to their records where possible.
+func ListRemote(ctx context.Context, db *gorm.DB, targetID string) ([]RemoteBackup, error) {
+	t, err := OpenTarget(db, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	objs, err := t.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var known []models.Backup
+	if err := db.Where("remote_target_id = ?", targetID).Find(&known).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]string, len(known))
+	for _, b := range known {
+		byKey[b.RemoteKey] = b.ID.String()
+	}
+
+	var out []RemoteBackup
+	for _, o := range objs {
+		if strings.HasPrefix(o.Key, remoteBlobs) {
+			continue
+		}
+		out = append(out, RemoteBackup{RemoteObject: o, BackupID: byKey[o.Key]})
+	}
+	return out, nil
+}
+
+// deleteRemote removes the remote copy of b. For snapshots it also drops remote chunks no
+// other remote manifest references.
+func deleteRemote(ctx context.Context, db *gorm.DB, b *models.Backup) error {
+	t, err := OpenTarget(db, b.RemoteTargetID)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if err := t.Delete(ctx, b.RemoteKey); err != nil {
+		return err
+	}
+	if b.Format != FormatSnapshot {
+		return nil
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	return remoteGC(ctx, t)
+}
+
+// remoteGC deletes chunks on t that no remote manifest references.
+func remoteGC(ctx context.Context, t Target) error {
+	manifests, err := t.List(ctx, remoteManifests)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "cs2admin-gc-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	live := make(map[string]bool)
+	for i, m := range manifests {
+		local := filepath.Join(tmp, fmt.Sprintf("%d.json", i))
+		if err := t.Get(ctx, m.Key, local); err != nil {
+			return fmt.Errorf("read remote manifest %s: %w", m.Key, err)
+		}
+		data, err := os.ReadFile(local)
+		if err != nil {
+			return err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			// Don't guess: an unreadable manifest might reference anything.
+			return fmt.Errorf("parse remote manifest %s: %w", m.Key, err)
+		}
+		for _, f := range snap.Files {
+			for _, c := range f.Chunks {
+				live[remoteBlobKey(c)] = true
+			}
+		}
+	}
+
+	blobs, err := t.List(ctx, remoteBlobs)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, o := range blobs {
+		if !live[o.Key] {
+			if err := t.Delete(ctx, o.Key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
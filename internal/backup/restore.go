@@ -0,0 +1,483 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cs2admin/internal/models"
+	"cs2admin/internal/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnsafeEntry is returned when a backup contains entries that would land outside the
+// install path. Nothing is written in that case.
+var ErrUnsafeEntry = errors.New("backup contains entries outside the install path")
+
+// RestoreOptions controls a restore.
+type RestoreOptions struct {
+	// Paths limits the restore to these files or directories (slash-separated, relative to
+	// the install path). Empty restores everything.
+	Paths []string
+	// DryRun only computes the plan; nothing is written.
+	DryRun bool
+	// SafetyBackupDir, if set, receives a pre_restore backup of every file the restore is
+	// about to overwrite with different content.
+	SafetyBackupDir string
+	// Passphrase unlocks an encrypted backup. When set, the safety backup is encrypted with it too.
+	Passphrase string
+}
+
+// RestorePlan lists what a restore does (or, for a dry run, would do) to the install path.
+type RestorePlan struct {
+	Added          []string `json:"added"`     // files that don't exist yet
+	Changed        []string `json:"changed"`   // existing files overwritten with different content
+	Unchanged      []string `json:"unchanged"` // existing files identical to the backup
+	TotalBytes     int64    `json:"total_bytes"`
+	SafetyBackupID string   `json:"safety_backup_id,omitempty"`
+}
+
+// archiveEntry is one regular file in a backup.
+type archiveEntry struct {
+	Name    string // slash-separated, relative to the install path
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	open    func() (io.ReadCloser, error)
+	// sameAs reports whether the file at path already has this entry's content, using
+	// whatever checksum the format stores so the archive itself needn't be read.
+	sameAs func(path string) (bool, error)
+}
+
+// archive gives uniform access to the files of any backup format.
+type archive interface {
+	Entries() []archiveEntry
+	Close() error
+}
+
+func openArchive(b *models.Backup, passphrase string) (archive, error) {
+	if _, err := os.Stat(b.Path); err != nil {
+		return nil, fmt.Errorf("backup file missing: %w", err)
+	}
+	if b.Format == FormatSnapshot {
+		return openSnapshotArchive(b.Path)
+	}
+
+	p, cleanup := b.Path, func() {}
+	if b.Encrypted {
+		plain, err := decryptToTemp(b.Path, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		p, cleanup = plain, func() { os.Remove(plain) }
+	}
+	switch b.Format {
+	case FormatZip, "":
+		za, err := openZipArchive(p)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		za.cleanup = cleanup
+		return za, nil
+	case FormatTarZst:
+		ta, err := openTarArchive(p)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		ta.cleanup = cleanup
+		return ta, nil
+	default:
+		cleanup()
+		return nil, fmt.Errorf("unknown backup format: %s", b.Format)
+	}
+}
+
+type zipArchive struct {
+	r       *zip.ReadCloser
+	entries []archiveEntry
+	cleanup func() // removes the decrypted temp copy, if any
+}
+
+func openZipArchive(p string) (*zipArchive, error) {
+	r, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	za := &zipArchive{r: r}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || f.Name == manifestEntry {
+			continue
+		}
+		f := f
+		za.entries = append(za.entries, archiveEntry{
+			Name:    f.Name,
+			Size:    int64(f.UncompressedSize64),
+			Mode:    f.Mode().Perm(),
+			ModTime: f.Modified,
+			open:    f.Open,
+			sameAs: func(p string) (bool, error) {
+				if !sameSize(p, int64(f.UncompressedSize64)) {
+					return false, nil
+				}
+				sum, err := crc32File(p)
+				return sum == f.CRC32, err
+			},
+		})
+	}
+	return za, nil
+}
+
+func (za *zipArchive) Entries() []archiveEntry { return za.entries }
+func (za *zipArchive) Close() error {
+	err := za.r.Close()
+	if za.cleanup != nil {
+		za.cleanup()
+	}
+	return err
+}
+
+type snapshotArchive struct {
+	entries []archiveEntry
+}
+
+func openSnapshotArchive(manifestPath string) (*snapshotArchive, error) {
+	snap, err := ReadSnapshot(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	store := &Store{root: filepath.Dir(filepath.Dir(manifestPath))}
+	sa := &snapshotArchive{}
+	for _, f := range snap.Files {
+		f := f
+		sa.entries = append(sa.entries, archiveEntry{
+			Name:    f.Path,
+			Size:    f.Size,
+			Mode:    os.FileMode(f.Mode),
+			ModTime: f.ModTime,
+			open: func() (io.ReadCloser, error) {
+				return &chunkReader{store: store, chunks: f.Chunks}, nil
+			},
+			sameAs: func(p string) (bool, error) {
+				if !sameSize(p, f.Size) {
+					return false, nil
+				}
+				chunks, err := chunkHashes(p)
+				if err != nil {
+					return false, err
+				}
+				if len(chunks) != len(f.Chunks) {
+					return false, nil
+				}
+				for i := range chunks {
+					if chunks[i] != f.Chunks[i] {
+						return false, nil
+					}
+				}
+				return true, nil
+			},
+		})
+	}
+	return sa, nil
+}
+
+func (sa *snapshotArchive) Entries() []archiveEntry { return sa.entries }
+func (sa *snapshotArchive) Close() error            { return nil }
+
+// chunkReader streams a snapshot file by reading and verifying its chunks in order.
+type chunkReader struct {
+	store  *Store
+	chunks []string
+	buf    *bytes.Reader
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for c.buf == nil || c.buf.Len() == 0 {
+		if len(c.chunks) == 0 {
+			return 0, io.EOF
+		}
+		data, err := c.store.readChunk(c.chunks[0])
+		if err != nil {
+			return 0, err
+		}
+		c.chunks = c.chunks[1:]
+		c.buf = bytes.NewReader(data)
+	}
+	return c.buf.Read(p)
+}
+
+func (c *chunkReader) Close() error { return nil }
+
+// Restore restores a backup into installPath. Every entry is validated before anything is
+// written; a backup with path-escaping entries is rejected as a whole.
+func Restore(db *gorm.DB, backupID string, installPath string, opts RestoreOptions) (*RestorePlan, error) {
+	var b models.Backup
+	if err := db.First(&b, "id = ?", backupID).Error; err != nil {
+		return nil, fmt.Errorf("backup not found: %w", err)
+	}
+	if err := Fetch(context.Background(), db, &b); err != nil {
+		return nil, err
+	}
+
+	a, err := openArchive(&b, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	entries, err := selectEntries(a.Entries(), opts.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := planRestore(entries, installPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	if opts.SafetyBackupDir != "" && len(plan.Changed) > 0 {
+		sources := make([]string, len(plan.Changed))
+		for i, rel := range plan.Changed {
+			sources[i] = filepath.Join(installPath, filepath.FromSlash(rel))
+		}
+		safety, err := createArchive(db, b.InstanceID.String(), installPath, opts.SafetyBackupDir, BackupPreRestore, sources, nil, CreateOptions{Passphrase: opts.Passphrase})
+		if err != nil {
+			return nil, fmt.Errorf("pre-restore safety backup: %w", err)
+		}
+		plan.SafetyBackupID = safety.ID.String()
+	}
+
+	for _, e := range entries {
+		dest, _ := safeJoin(installPath, e.Name)
+		if err := restoreEntry(installPath, dest, e); err != nil {
+			return plan, err
+		}
+	}
+
+	logger.Log.Info().
+		Str("backup_id", backupID).
+		Str("install_path", installPath).
+		Int("added", len(plan.Added)).
+		Int("changed", len(plan.Changed)).
+		Str("safety_backup", plan.SafetyBackupID).
+		Msg("backup: restored")
+
+	return plan, nil
+}
+
+// selectEntries validates every entry name and keeps the ones under paths (all if empty).
+func selectEntries(all []archiveEntry, paths []string) ([]archiveEntry, error) {
+	var unsafe []string
+	for _, e := range all {
+		if _, err := safeJoin(".", e.Name); err != nil {
+			unsafe = append(unsafe, e.Name)
+		}
+	}
+	if len(unsafe) > 0 {
+		logger.Log.Warn().Strs("entries", unsafe).Msg("backup: rejected unsafe entries")
+		return nil, fmt.Errorf("%w: %s", ErrUnsafeEntry, strings.Join(unsafe, ", "))
+	}
+
+	if len(paths) == 0 {
+		return all, nil
+	}
+	want := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(filepath.ToSlash(p)), "/")
+		if p != "" && p != "." {
+			want = append(want, p)
+		}
+	}
+	var selected []archiveEntry
+	for _, e := range all {
+		name := path.Clean(e.Name)
+		for _, w := range want {
+			if name == w || strings.HasPrefix(name, w+"/") {
+				selected = append(selected, e)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of the selected paths are in this backup")
+	}
+	return selected, nil
+}
+
+func planRestore(entries []archiveEntry, installPath string) (*RestorePlan, error) {
+	plan := &RestorePlan{}
+	for _, e := range entries {
+		dest, _ := safeJoin(installPath, e.Name)
+		plan.TotalBytes += e.Size
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			plan.Added = append(plan.Added, e.Name)
+			continue
+		}
+		same, err := e.sameAs(dest)
+		if err != nil {
+			return nil, fmt.Errorf("compare %s: %w", e.Name, err)
+		}
+		if same {
+			plan.Unchanged = append(plan.Unchanged, e.Name)
+		} else {
+			plan.Changed = append(plan.Changed, e.Name)
+		}
+	}
+	sort.Strings(plan.Added)
+	sort.Strings(plan.Changed)
+	sort.Strings(plan.Unchanged)
+	return plan, nil
+}
+
+// safeJoin joins a slash-separated archive name onto root, rejecting absolute paths, volume
+// names and anything that climbs out of root.
+func safeJoin(root, name string) (string, error) {
+	local := filepath.FromSlash(strings.ReplaceAll(name, `\`, "/"))
+	if name == "" || !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeEntry, name)
+	}
+	return filepath.Join(root, local), nil
+}
+
+// restoreEntry writes e to dest through a temp file and rename, so a symlink at dest is
+// replaced rather than followed. The parent dir must resolve inside installPath; its
+// deepest existing ancestor is checked before any missing dirs are created, so a
+// symlinked dir can't get directories made outside the install.
+func restoreEntry(installPath, dest string, e archiveEntry) error {
+	dir := filepath.Dir(dest)
+	if err := checkInside(installPath, existingAncestor(dir)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("mkdir parent %s: %w", dest, err)
+	}
+	if err := checkInside(installPath, dir); err != nil {
+		return err
+	}
+
+	rc, err := e.open()
+	if err != nil {
+		return fmt.Errorf("open entry %s: %w", e.Name, err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(dir, ".cs2admin-restore-*")
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dest, err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("write %s: %w", dest, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write %s: %w", dest, err)
+	}
+
+	mode := e.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	_ = os.Chmod(tmpPath, mode)
+	if err := os.Rename(tmpPath, dest); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace %s: %w", dest, err)
+	}
+	if !e.ModTime.IsZero() {
+		_ = os.Chtimes(dest, e.ModTime, e.ModTime)
+	}
+	return nil
+}
+
+// checkInside fails if dir, after resolving symlinks, is not within root.
+func checkInside(root, dir string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("resolve install path: %w", err)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	rel, err := filepath.Rel(realRoot, realDir)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return fmt.Errorf("%w: %s", ErrUnsafeEntry, dir)
+	}
+	return nil
+}
+
+// existingAncestor returns dir, or its nearest ancestor that exists.
+func existingAncestor(dir string) string {
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+func sameSize(p string, size int64) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular() && info.Size() == size
+}
+
+func crc32File(p string) (uint32, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+// chunkHashes splits the file at p the same way the snapshot store does and hashes each chunk.
+func chunkHashes(p string) ([]string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hashes []string
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			hashes = append(hashes, hex.EncodeToString(sum[:]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cs2admin/internal/models"
+	"cs2admin/internal/pkg/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionDecision is the verdict for one backup when a policy is applied.
+type RetentionDecision struct {
+	Backup models.Backup `json:"backup"`
+	Keep   bool          `json:"keep"`
+	Reason string        `json:"reason"` // pinned, last, daily, weekly, monthly, no rule, expired, over size limit
+}
+
+// SavePolicy creates or replaces the retention policy for the policy's instance and backup type.
+func SavePolicy(db *gorm.DB, p models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	if p.InstanceID == uuid.Nil {
+		return nil, errors.New("invalid instance ID")
+	}
+	if p.BackupType == "" {
+		return nil, errors.New("backup type is required")
+	}
+	if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 || p.MaxTotalBytes < 0 {
+		return nil, errors.New("retention counts must not be negative")
+	}
+
+	var existing models.RetentionPolicy
+	err := db.Where("instance_id = ? AND backup_type = ?", p.InstanceID, p.BackupType).First(&existing).Error
+	if err == nil {
+		p.ID = existing.ID
+		p.CreatedAt = existing.CreatedAt
+		if err := db.Save(&p).Error; err != nil {
+			return nil, fmt.Errorf("update retention policy: %w", err)
+		}
+		return &p, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	p.ID = uuid.Nil
+	if err := db.Create(&p).Error; err != nil {
+		return nil, fmt.Errorf("create retention policy: %w", err)
+	}
+	return &p, nil
+}
+
+// ListPolicies returns the retention policies for an instance.
+func ListPolicies(db *gorm.DB, instanceID string) ([]models.RetentionPolicy, error) {
+	var policies []models.RetentionPolicy
+	err := db.Where("instance_id = ?", instanceID).Order("backup_type").Find(&policies).Error
+	return policies, err
+}
+
+// DeletePolicy removes a retention policy. Existing backups are left alone.
+func DeletePolicy(db *gorm.DB, policyID string) error {
+	return db.Delete(&models.RetentionPolicy{}, "id = ?", policyID).Error
+}
+
+// SetPinned pins or unpins a backup. Pinned backups are never pruned.
+func SetPinned(db *gorm.DB, backupID string, pinned bool) error {
+	res := db.Model(&models.Backup{}).Where("id = ?", backupID).Update("pinned", pinned)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("backup not found: %s", backupID)
+	}
+	return nil
+}
+
+// ApplyRetention evaluates the instance's policy for bType and, unless dryRun, deletes the
+// backups it doesn't keep. Without a policy nothing is pruned and nil is returned.
+func ApplyRetention(db *gorm.DB, instanceID string, bType string, dryRun bool) ([]RetentionDecision, error) {
+	var policy models.RetentionPolicy
+	err := db.Where("instance_id = ? AND backup_type = ?", instanceID, bType).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load retention policy: %w", err)
+	}
+	return applyPolicy(db, policy, dryRun)
+}
+
+// Sweep applies every retention policy. Used by the daily maintenance job.
+func Sweep(db *gorm.DB) error {
+	var policies []models.RetentionPolicy
+	if err := db.Find(&policies).Error; err != nil {
+		return fmt.Errorf("load retention policies: %w", err)
+	}
+	var errs []error
+	for _, p := range policies {
+		if _, err := applyPolicy(db, p, false); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", p.InstanceID, p.BackupType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func applyPolicy(db *gorm.DB, policy models.RetentionPolicy, dryRun bool) ([]RetentionDecision, error) {
+	var backups []models.Backup
+	if err := db.Where("instance_id = ? AND backup_type = ?", policy.InstanceID, policy.BackupType).
+		Order("created_at DESC").Find(&backups).Error; err != nil {
+		return nil, fmt.Errorf("load backups: %w", err)
+	}
+
+	decisions := PlanRetention(policy, backups, snapshotChunks(backups))
+	if dryRun {
+		return decisions, nil
+	}
+
+	pruned := 0
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		if err := Delete(db, d.Backup.ID.String()); err != nil {
+			return decisions, fmt.Errorf("prune %s: %w", d.Backup.ID, err)
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		logger.Log.Info().
+			Str("instance", policy.InstanceID.String()).
+			Str("type", policy.BackupType).
+			Int("pruned", pruned).
+			Msg("backup: retention applied")
+	}
+	return decisions, nil
+}
+
+// PlanRetention decides which backups a policy keeps, grandfather-father-son style:
+// the newest KeepLast, then the newest backup of each of the last KeepDaily days,
+// KeepWeekly ISO weeks and KeepMonthly months. Pinned backups are always kept. If
+// MaxTotalBytes is set, the oldest kept backups are dropped until the rest fit, except
+// pinned ones and the newest backup. A policy with no count rules keeps everything it
+// doesn't have to drop for size.
+//
+// chunks holds the blob sizes each snapshot references, by backup ID (see snapshotChunks).
+// Snapshots share chunks, so the size of the kept set counts each chunk once and a snapshot
+// only frees the chunks no kept snapshot still uses. Other backups count their file size.
+func PlanRetention(policy models.RetentionPolicy, backups []models.Backup, chunks map[uuid.UUID]map[string]int64) []RetentionDecision {
+	sorted := make([]models.Backup, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+	hasRules := policy.KeepLast > 0 || policy.KeepDaily > 0 || policy.KeepWeekly > 0 || policy.KeepMonthly > 0
+
+	decisions := make([]RetentionDecision, len(sorted))
+	for i, b := range sorted {
+		decisions[i] = RetentionDecision{Backup: b}
+		switch {
+		case b.Pinned:
+			decisions[i].Keep, decisions[i].Reason = true, "pinned"
+		case i < policy.KeepLast:
+			decisions[i].Keep, decisions[i].Reason = true, "last"
+		case !hasRules:
+			decisions[i].Keep, decisions[i].Reason = true, "no rule"
+		}
+	}
+
+	keepBuckets(decisions, policy.KeepDaily, "daily", func(t time.Time) string {
+		return t.Local().Format("2006-01-02")
+	})
+	keepBuckets(decisions, policy.KeepWeekly, "weekly", func(t time.Time) string {
+		y, w := t.Local().ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	})
+	keepBuckets(decisions, policy.KeepMonthly, "monthly", func(t time.Time) string {
+		return t.Local().Format("2006-01")
+	})
+
+	for i := range decisions {
+		if !decisions[i].Keep {
+			decisions[i].Reason = "expired"
+		}
+	}
+
+	if policy.MaxTotalBytes > 0 {
+		var size keptSize
+		for _, d := range decisions {
+			if d.Keep {
+				size.add(d.Backup, chunks)
+			}
+		}
+		for i := len(decisions) - 1; i > 0 && size.total > policy.MaxTotalBytes; i-- {
+			d := &decisions[i]
+			if !d.Keep || d.Backup.Pinned {
+				continue
+			}
+			d.Keep, d.Reason = false, "over size limit"
+			size.remove(d.Backup, chunks)
+		}
+	}
+
+	return decisions
+}
+
+// keepBuckets keeps the newest backup in each of the n most recent buckets.
+func keepBuckets(decisions []RetentionDecision, n int, reason string, bucket func(time.Time) string) {
+	if n <= 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	for i := range decisions {
+		key := bucket(decisions[i].Backup.CreatedAt)
+		if seen[key] {
+			continue
+		}
+		if len(seen) >= n {
+			return
+		}
+		seen[key] = true
+		if !decisions[i].Keep {
+			decisions[i].Keep, decisions[i].Reason = true, reason
+		}
+	}
+}
+
+// keptSize totals the disk space a set of backups uses, counting each snapshot chunk once.
+type keptSize struct {
+	total int64
+	refs  map[string]int // chunk hash -> kept snapshots referencing it
+}
+
+func (k *keptSize) add(b models.Backup, chunks map[uuid.UUID]map[string]int64) {
+	sizes, ok := chunks[b.ID]
+	if !ok {
+		k.total += storedSize(b)
+		return
+	}
+	if k.refs == nil {
+		k.refs = make(map[string]int)
+	}
+	for h, n := range sizes {
+		if k.refs[h] == 0 {
+			k.total += n
+		}
+		k.refs[h]++
+	}
+}
+
+func (k *keptSize) remove(b models.Backup, chunks map[uuid.UUID]map[string]int64) {
+	sizes, ok := chunks[b.ID]
+	if !ok {
+		k.total -= storedSize(b)
+		return
+	}
+	for h, n := range sizes {
+		k.refs[h]--
+		if k.refs[h] == 0 {
+			k.total -= n
+		}
+	}
+}
+
+// storedSize is the disk space of an archive backup, or of a snapshot whose manifest can't
+// be read, for which only its new chunks are known. Rows from before StoredBytes was
+// recorded fall back to the archive size.
+func storedSize(b models.Backup) int64 {
+	if b.Format == FormatSnapshot || b.StoredBytes > 0 {
+		return b.StoredBytes
+	}
+	return b.SizeBytes
+}
+
+// snapshotChunks reads the manifests of the snapshot backups and returns the size of each
+// chunk they reference, by backup ID. A chunk is chunkSize bytes except at the end of a file.
+func snapshotChunks(backups []models.Backup) map[uuid.UUID]map[string]int64 {
+	out := make(map[uuid.UUID]map[string]int64)
+	for _, b := range backups {
+		if b.Format != FormatSnapshot {
+			continue
+		}
+		snap, err := ReadSnapshot(b.Path)
+		if err != nil {
+			logger.Log.Warn().Err(err).Str("backup_id", b.ID.String()).Msg("backup: retention can't read snapshot manifest")
+			continue
+		}
+		sizes := make(map[string]int64)
+		for _, f := range snap.Files {
+			for i, h := range f.Chunks {
+				sizes[h] = min(chunkSize, f.Size-int64(i)*chunkSize)
+			}
+		}
+		out[b.ID] = sizes
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"cs2admin/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPlanRetentionSnapshotSize(t *testing.T) {
+	const mb = 1 << 20
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(hoursAgo int, stored int64) models.Backup {
+		return models.Backup{
+			ID:          uuid.New(),
+			Format:      FormatSnapshot,
+			SizeBytes:   20 << 30, // the logical install size, far over any limit
+			StoredBytes: stored,
+			CreatedAt:   t0.Add(-time.Duration(hoursAgo) * time.Hour),
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		limit int64
+		// chunks referenced by the snapshots, oldest first
+		chunks []map[string]int64
+		want   []bool // keep, oldest first
+	}{
+		{
+			// Unchanged snapshots store nothing new and share every chunk: 9 MB in all.
+			name:  "no-change snapshots fit",
+			limit: 10 * mb,
+			chunks: []map[string]int64{
+				{"a": 4 * mb, "b": 4 * mb},
+				{"a": 4 * mb, "b": 4 * mb},
+				{"a": 4 * mb, "b": 4 * mb, "c": 1 * mb},
+			},
+			want: []bool{true, true, true},
+		},
+		{
+			// Dropping the oldest frees only its own chunk d.
+			name:  "oldest frees its unique chunks",
+			limit: 6 * mb,
+			chunks: []map[string]int64{
+				{"a": 4 * mb, "d": 3 * mb},
+				{"a": 4 * mb},
+				{"a": 4 * mb, "c": 1 * mb},
+			},
+			want: []bool{false, true, true},
+		},
+		{
+			// Shared chunks stay until no kept snapshot uses them.
+			name:  "shared chunks free nothing",
+			limit: 8 * mb,
+			chunks: []map[string]int64{
+				{"a": 4 * mb, "b": 4 * mb},
+				{"a": 4 * mb, "b": 4 * mb},
+				{"a": 4 * mb, "b": 4 * mb, "c": 1 * mb},
+			},
+			want: []bool{false, false, true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var backups []models.Backup
+			chunks := make(map[uuid.UUID]map[string]int64)
+			for i, c := range tc.chunks {
+				b := snap(len(tc.chunks)-i, 0)
+				backups = append(backups, b)
+				chunks[b.ID] = c
+			}
+			decisions := PlanRetention(models.RetentionPolicy{MaxTotalBytes: tc.limit}, backups, chunks)
+			keep := make(map[uuid.UUID]bool)
+			for _, d := range decisions {
+				keep[d.Backup.ID] = d.Keep
+			}
+			for i, b := range backups {
+				if keep[b.ID] != tc.want[i] {
+					t.Errorf("snapshot %d: keep = %v, want %v", i, keep[b.ID], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanRetentionArchiveSize(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var backups []models.Backup
+	for i := 0; i < 4; i++ {
+		backups = append(backups, models.Backup{
+			ID:        uuid.New(),
+			Format:    FormatZip,
+			SizeBytes: 100, // a row from before StoredBytes was recorded
+			CreatedAt: t0.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	decisions := PlanRetention(models.RetentionPolicy{MaxTotalBytes: 250}, backups, nil)
+	var kept int
+	for i, d := range decisions {
+		if d.Keep {
+			kept++
+		} else if i < 2 {
+			t.Errorf("newest backup %d pruned", i)
+		}
+	}
+	if kept != 2 {
+		t.Errorf("kept %d backups, want 2", kept)
+	}
+}
+
+func TestSnapshotChunkSizes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &Snapshot{Version: 1, Files: []SnapshotFile{
+		{Path: "big.vpk", Size: chunkSize + 10, Chunks: []string{"x", "y"}},
+		{Path: "small.cfg", Size: 5, Chunks: []string{"z"}},
+	}}
+	id := uuid.New()
+	path, err := store.WriteSnapshot(id.String(), snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := snapshotChunks([]models.Backup{{ID: id, Format: FormatSnapshot, Path: path}})[id]
+	want := map[string]int64{"x": chunkSize, "y": 10, "z": 5}
+	for h, n := range want {
+		if got[h] != n {
+			t.Errorf("chunk %s: size %d, want %d", h, got[h], n)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cs2admin/internal/pkg/logger"
+	"cs2admin/internal/steam"
+)
+
+// ErrNoBaseline is returned for custom-content backups of an instance whose stock file
+// baseline has never been recorded.
+var ErrNoBaseline = errors.New("no stock file baseline recorded for this instance; run install or validate first")
+
+// customRoots are always treated as custom content, even when a file there is unchanged since
+// the baseline: they hold operator-owned data that SteamCMD will never restore.
+var customRoots = []string{
+	"game/csgo/addons/",
+	"game/csgo/cfg/",
+	"steamapps/workshop/",
+}
+
+// stockRoots are never backed up in custom-content mode: SteamCMD bookkeeping and caches that
+// it rebuilds on the next app_update.
+var stockRoots = []string{
+	"steamapps/",
+}
+
+// Baseline is the hash of every stock file taken right after install/update/validate, tied to the
+// build ID SteamCMD reported at the time.
+type Baseline struct {
+	BuildID   string                  `json:"build_id"`
+	CreatedAt time.Time               `json:"created_at"`
+	Files     map[string]BaselineFile `json:"files"`
+}
+
+// BaselineFile is one stock file in a Baseline.
+type BaselineFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// CustomReport describes what a custom-content backup captured and what it left to SteamCMD.
+type CustomReport struct {
+	BuildID         string               `json:"build_id"`
+	BaselineBuildID string               `json:"baseline_build_id"`
+	BaselineStale   bool                 `json:"baseline_stale"`
+	IncludedFiles   int                  `json:"included_files"`
+	IncludedBytes   int64                `json:"included_bytes"`
+	SkippedFiles    int                  `json:"skipped_files"`
+	SkippedBytes    int64                `json:"skipped_bytes"`
+	Modified        []string             `json:"modified"` // stock files that differ from the baseline
+	Added           []string             `json:"added"`    // files unknown to the baseline
+	SkippedByDir    map[string]SkipCount `json:"skipped_by_dir"`
+}
+
+// SkipCount totals skipped stock files under one directory.
+type SkipCount struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// BaselinePath returns where the stock baseline for instanceID is kept.
+func BaselinePath(backupDir, instanceID string) string {
+	return filepath.Join(backupDir, "baselines", instanceID+".json")
+}
+
+// RecordBaseline hashes every stock file under installPath and saves it as the instance's
+// baseline. Call it right after a successful install, update or validate.
+func RecordBaseline(installPath, backupDir, instanceID string) (*Baseline, error) {
+	base := &Baseline{
+		CreatedAt: time.Now().UTC(),
+		Files:     make(map[string]BaselineFile),
+	}
+	if m, err := steam.ReadAppManifest(installPath); err == nil {
+		base.BuildID = m.BuildID
+	} else {
+		logger.Log.Warn().Err(err).Str("instance", instanceID).Msg("backup: baseline without app manifest")
+	}
+
+	err := filepath.Walk(installPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(installPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if hasAnyPrefix(rel, customRoots) || hasAnyPrefix(rel, stockRoots) {
+			return nil
+		}
+		sum, err := hashFile(p)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", rel, err)
+		}
+		base.Files[rel] = BaselineFile{Size: info.Size(), ModTime: info.ModTime().UTC(), SHA256: sum}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dest := BaselinePath(backupDir, instanceID)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("create baseline dir: %w", err)
+	}
+	data, err := json.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("marshal baseline: %w", err)
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return nil, fmt.Errorf("write baseline: %w", err)
+	}
+
+	logger.Log.Info().
+		Str("instance", instanceID).
+		Str("build", base.BuildID).
+		Int("files", len(base.Files)).
+		Msg("backup: stock baseline recorded")
+	return base, nil
+}
+
+// LoadBaseline reads the instance's stock baseline.
+func LoadBaseline(backupDir, instanceID string) (*Baseline, error) {
+	data, err := os.ReadFile(BaselinePath(backupDir, instanceID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoBaseline
+		}
+		return nil, err
+	}
+	var base Baseline
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("parse baseline: %w", err)
+	}
+	return &base, nil
+}
+
+// stockFilter decides per file whether a custom-content backup includes it and fills in the
+// report as it goes.
+type stockFilter struct {
+	base   *Baseline
+	report *CustomReport
+}
+
+func newStockFilter(installPath string, base *Baseline) *stockFilter {
+	report := &CustomReport{
+		BaselineBuildID: base.BuildID,
+		SkippedByDir:    make(map[string]SkipCount),
+	}
+	if m, err := steam.ReadAppManifest(installPath); err == nil {
+		report.BuildID = m.BuildID
+	}
+	report.BaselineStale = report.BuildID != "" && base.BuildID != "" && report.BuildID != base.BuildID
+	return &stockFilter{base: base, report: report}
+}
+
+// include reports whether the file at absPath (rel to the install path) is custom content.
+func (f *stockFilter) include(rel, absPath string, info os.FileInfo) (bool, error) {
+	switch {
+	case hasAnyPrefix(rel, customRoots):
+		return f.keep(info), nil
+	case hasAnyPrefix(rel, stockRoots):
+		return f.skip(rel, info), nil
+	}
+
+	known, ok := f.base.Files[rel]
+	if !ok {
+		f.report.Added = append(f.report.Added, rel)
+		return f.keep(info), nil
+	}
+	if known.Size == info.Size() && known.ModTime.Equal(info.ModTime().UTC()) {
+		return f.skip(rel, info), nil
+	}
+	if known.Size == info.Size() {
+		// Touched but maybe not changed (e.g. copied around); only the content decides.
+		sum, err := hashFile(absPath)
+		if err != nil {
+			return false, fmt.Errorf("hash %s: %w", rel, err)
+		}
+		if sum == known.SHA256 {
+			return f.skip(rel, info), nil
+		}
+	}
+	f.report.Modified = append(f.report.Modified, rel)
+	return f.keep(info), nil
+}
+
+func (f *stockFilter) keep(info os.FileInfo) bool {
+	f.report.IncludedFiles++
+	f.report.IncludedBytes += info.Size()
+	return true
+}
+
+func (f *stockFilter) skip(rel string, info os.FileInfo) bool {
+	dir := path.Dir(rel)
+	c := f.report.SkippedByDir[dir]
+	c.Files++
+	c.Bytes += info.Size()
+	f.report.SkippedByDir[dir] = c
+	f.report.SkippedFiles++
+	f.report.SkippedBytes += info.Size()
+	return false
+}
+
+func (f *stockFilter) finish() *CustomReport {
+	sort.Strings(f.report.Modified)
+	sort.Strings(f.report.Added)
+	return f.report
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
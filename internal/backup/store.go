@@ -0,0 +1,359 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cs2admin/internal/pkg/logger"
+)
+
+// chunkSize is the fixed chunk size used to split files into blobs. Large enough to keep the
+// blob count manageable for multi-GB VPKs, small enough that a patched file only re-stores
+// the chunks that actually changed.
+const chunkSize = 4 << 20 // 4 MiB
+
+// storeMu keeps GC from deleting blobs that a snapshot in progress has already counted on
+// but not yet referenced from a written manifest. Snapshot creation holds the read lock,
+// GC the write lock.
+var storeMu sync.RWMutex
+
+// Store is a content-addressed blob store shared by all snapshot backups in a backup dir.
+// Layout:
+//
+//	<backupDir>/store/blobs/ab/abcdef...   (chunk data, named by SHA-256)
+//	<backupDir>/store/manifests/<id>.json  (one Snapshot per backup)
+type Store struct {
+	root string
+}
+
+// Snapshot is the manifest of one snapshot backup: every file and the chunks that rebuild it.
+type Snapshot struct {
+	Version    int            `json:"version"`
+	InstanceID string         `json:"instance_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Files      []SnapshotFile `json:"files"`
+}
+
+// SnapshotFile describes one file inside a snapshot. Path is relative to the install path
+// and always uses forward slashes.
+type SnapshotFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    uint32    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+	Chunks  []string  `json:"chunks"`
+}
+
+// SnapshotStats summarizes what a snapshot cost to create.
+type SnapshotStats struct {
+	Files       int
+	TotalBytes  int64 // logical size of all files in the snapshot
+	StoredBytes int64 // bytes of new blobs actually written to the store
+	NewChunks   int
+	ReusedFiles int // files taken from the previous snapshot without re-reading
+}
+
+// OpenStore opens (and creates if needed) the blob store under backupDir.
+func OpenStore(backupDir string) (*Store, error) {
+	s := &Store{root: filepath.Join(backupDir, "store")}
+	for _, d := range []string{s.blobsDir(), s.manifestsDir()} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("create store dir: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) blobsDir() string     { return filepath.Join(s.root, "blobs") }
+func (s *Store) manifestsDir() string { return filepath.Join(s.root, "manifests") }
+
+// ManifestPath returns the manifest path for the given backup ID.
+func (s *Store) ManifestPath(backupID string) string {
+	return filepath.Join(s.manifestsDir(), backupID+".json")
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.blobsDir(), hash[:2], hash)
+}
+
+// putChunk stores data under its SHA-256 and reports whether a new blob was written.
+func (s *Store) putChunk(data []byte) (string, bool, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	dest := s.blobPath(hash)
+
+	if _, err := os.Stat(dest); err == nil {
+		return hash, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", false, fmt.Errorf("create blob dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), hash+".tmp-*")
+	if err != nil {
+		return "", false, fmt.Errorf("create blob: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", false, fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", false, fmt.Errorf("close blob: %w", err)
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		os.Remove(tmpPath)
+		return "", false, fmt.Errorf("rename blob: %w", err)
+	}
+	return hash, true, nil
+}
+
+// readChunk reads a blob and verifies it still matches its hash.
+func (s *Store) readChunk(hash string) ([]byte, error) {
+	data, err := os.ReadFile(s.blobPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("read blob %s: %w", hash, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return nil, fmt.Errorf("blob %s is corrupt", hash)
+	}
+	return data, nil
+}
+
+// CreateSnapshot walks sourceDirs and stores every file under rootPath into the blob store.
+// Files whose size and mtime match prev (the instance's previous snapshot, may be nil) reuse
+// its chunk list without being read again. progress may be nil.
+func (s *Store) CreateSnapshot(instanceID, rootPath string, sourceDirs []string, prev *Snapshot, progress *progressMeter) (*Snapshot, SnapshotStats, error) {
+	var stats SnapshotStats
+
+	known := make(map[string]*SnapshotFile)
+	if prev != nil {
+		for i := range prev.Files {
+			known[prev.Files[i].Path] = &prev.Files[i]
+		}
+	}
+
+	snap := &Snapshot{
+		Version:    1,
+		InstanceID: instanceID,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	buf := make([]byte, chunkSize)
+	for _, dir := range sourceDirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil // skip missing dirs
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(rootPath, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			entry := SnapshotFile{
+				Path:    rel,
+				Size:    info.Size(),
+				Mode:    uint32(info.Mode().Perm()),
+				ModTime: info.ModTime().UTC(),
+			}
+
+			if old, ok := known[rel]; ok && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) {
+				entry.Chunks = old.Chunks
+				stats.ReusedFiles++
+				progress.add(entry.Size)
+			} else {
+				chunks, stored, newChunks, err := s.storeFile(path, buf, progress)
+				if err != nil {
+					return fmt.Errorf("store %s: %w", rel, err)
+				}
+				entry.Chunks = chunks
+				stats.StoredBytes += stored
+				stats.NewChunks += newChunks
+			}
+
+			snap.Files = append(snap.Files, entry)
+			stats.Files++
+			progress.file()
+			stats.TotalBytes += entry.Size
+			return nil
+		})
+		if err != nil {
+			return nil, stats, err
+		}
+	}
+
+	return snap, stats, nil
+}
+
+func (s *Store) storeFile(path string, buf []byte, progress *progressMeter) ([]string, int64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+
+	var (
+		chunks    []string
+		stored    int64
+		newChunks int
+	)
+	for {
+		n, err := io.ReadFull(f, buf)
+		progress.add(int64(n))
+		if n > 0 {
+			hash, isNew, perr := s.putChunk(buf[:n])
+			if perr != nil {
+				return nil, 0, 0, perr
+			}
+			chunks = append(chunks, hash)
+			if isNew {
+				stored += int64(n)
+				newChunks++
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return chunks, stored, newChunks, nil
+}
+
+// WriteSnapshot writes the manifest for backupID and returns its path.
+func (s *Store) WriteSnapshot(backupID string, snap *Snapshot) (string, error) {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return "", fmt.Errorf("marshal snapshot: %w", err)
+	}
+	path := s.ManifestPath(backupID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("rename snapshot: %w", err)
+	}
+	return path, nil
+}
+
+// ReadSnapshot loads a snapshot manifest from disk.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parse snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// RestoreFile rebuilds a single snapshot file at dest from its chunks.
+func (s *Store) RestoreFile(f SnapshotFile, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("mkdir parent %s: %w", dest, err)
+	}
+	mode := os.FileMode(f.Mode)
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dest, err)
+	}
+	for _, hash := range f.Chunks {
+		data, err := s.readChunk(hash)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			out.Close()
+			return fmt.Errorf("write %s: %w", dest, err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", dest, err)
+	}
+	// Keep the original mtime so the next snapshot can skip re-reading this file.
+	if !f.ModTime.IsZero() {
+		_ = os.Chtimes(dest, f.ModTime, f.ModTime)
+	}
+	return nil
+}
+
+// GC removes blobs no longer referenced by any manifest and returns the bytes freed.
+func (s *Store) GC() (int64, error) {
+	live := make(map[string]struct{})
+	manifests, err := os.ReadDir(s.manifestsDir())
+	if err != nil {
+		return 0, fmt.Errorf("read manifests: %w", err)
+	}
+	for _, m := range manifests {
+		if m.IsDir() || !strings.HasSuffix(m.Name(), ".json") {
+			continue
+		}
+		snap, err := ReadSnapshot(filepath.Join(s.manifestsDir(), m.Name()))
+		if err != nil {
+			// Never delete blobs when we can't tell what an unreadable manifest references.
+			return 0, fmt.Errorf("read manifest %s: %w", m.Name(), err)
+		}
+		for _, f := range snap.Files {
+			for _, h := range f.Chunks {
+				live[h] = struct{}{}
+			}
+		}
+	}
+
+	var freed int64
+	err = filepath.Walk(s.blobsDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if _, ok := live[info.Name()]; ok {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logger.Log.Warn().Err(err).Str("blob", path).Msg("backup: gc remove failed")
+			return nil
+		}
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return freed, err
+	}
+
+	logger.Log.Info().Int64("freed", freed).Int("live_blobs", len(live)).Msg("backup: store gc complete")
+	return freed, nil
+}
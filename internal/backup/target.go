@@ -0,0 +1,380 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cs2admin/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Target kinds recorded on models.BackupTarget.Kind.
+const (
+	TargetLocal = "local"
+	TargetS3    = "s3"
+	TargetSFTP  = "sftp"
+)
+
+// ErrRemoteNotFound is returned by Target.Stat for keys that don't exist.
+var ErrRemoteNotFound = errors.New("remote object not found")
+
+// Target is a destination backups are copied to. Keys are slash-separated and relative to
+// the target's own root (directory, bucket prefix).
+type Target interface {
+	// Put uploads localPath to key. An interrupted upload of the same file resumes where it
+	// stopped; the object only appears under key once complete.
+	Put(ctx context.Context, key, localPath string) error
+	// Get downloads key to localPath.
+	Get(ctx context.Context, key, localPath string) error
+	Stat(ctx context.Context, key string) (*RemoteObject, error)
+	// List returns every object under prefix, recursively.
+	List(ctx context.Context, prefix string) ([]RemoteObject, error)
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// RemoteObject is one object on a Target.
+type RemoteObject struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// TargetSettings are the non-secret settings of a target, stored as JSON in
+// models.BackupTarget.Config. Which fields apply depends on the kind.
+type TargetSettings struct {
+	Dir string `json:"dir,omitempty"` // local, sftp
+
+	Endpoint string `json:"endpoint,omitempty"` // s3: host[:port]
+	Bucket   string `json:"bucket,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	UseSSL   bool   `json:"use_ssl,omitempty"`
+
+	Host string `json:"host,omitempty"` // sftp
+	Port int    `json:"port,omitempty"`
+	User string `json:"user,omitempty"`
+	// HostKey is the server's SHA256 key fingerprint ("SHA256:..."); connections to any other
+	// key are refused.
+	HostKey string `json:"host_key,omitempty"`
+}
+
+// TargetSecrets are a target's credentials, stored encrypted in models.BackupTarget.Secret.
+type TargetSecrets struct {
+	AccessKey  string `json:"access_key,omitempty"` // s3
+	SecretKey  string `json:"secret_key,omitempty"`
+	Password   string `json:"password,omitempty"`    // sftp
+	PrivateKey string `json:"private_key,omitempty"` // sftp, PEM
+}
+
+var decryptFn func(string) (string, error)
+
+// SetDecryptFn sets how target secrets are decrypted. Must be called before targets are opened.
+func SetDecryptFn(fn func(string) (string, error)) {
+	decryptFn = fn
+}
+
+// OpenTarget connects to the target with the given ID.
+func OpenTarget(db *gorm.DB, targetID string) (Target, error) {
+	var t models.BackupTarget
+	if err := db.First(&t, "id = ?", targetID).Error; err != nil {
+		return nil, fmt.Errorf("backup target not found: %w", err)
+	}
+	return openTarget(&t)
+}
+
+func openTarget(t *models.BackupTarget) (Target, error) {
+	var settings TargetSettings
+	if t.Config != "" {
+		if err := json.Unmarshal([]byte(t.Config), &settings); err != nil {
+			return nil, fmt.Errorf("parse target config: %w", err)
+		}
+	}
+	var secrets TargetSecrets
+	if t.Secret != "" {
+		if decryptFn == nil {
+			return nil, errors.New("target secrets can't be decrypted: no decrypt function set")
+		}
+		plain, err := decryptFn(t.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt target secrets: %w", err)
+		}
+		if err := json.Unmarshal([]byte(plain), &secrets); err != nil {
+			return nil, fmt.Errorf("parse target secrets: %w", err)
+		}
+	}
+
+	switch t.Kind {
+	case TargetLocal:
+		return newLocalTarget(settings)
+	case TargetS3:
+		return newS3Target(settings, secrets)
+	case TargetSFTP:
+		return newSFTPTarget(settings, secrets)
+	default:
+		return nil, fmt.Errorf("unknown backup target kind: %s", t.Kind)
+	}
+}
+
+// localTarget copies backups to another directory, typically a second disk or a network share.
+type localTarget struct {
+	dir string
+}
+
+func newLocalTarget(s TargetSettings) (*localTarget, error) {
+	if s.Dir == "" {
+		return nil, errors.New("local target needs a directory")
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create target dir: %w", err)
+	}
+	return &localTarget{dir: s.Dir}, nil
+}
+
+func (t *localTarget) path(key string) (string, error) {
+	return safeJoin(t.dir, key)
+}
+
+func (t *localTarget) Put(ctx context.Context, key, localPath string) error {
+	dest, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Resume a previous partial copy of the same file.
+	stamp, err := stampFile(src)
+	if err != nil {
+		return err
+	}
+	part := dest + ".part"
+	saved, _ := os.ReadFile(part + partStampSuffix)
+	resume := stamp.matches(saved)
+	if !resume {
+		data, _ := json.Marshal(stamp)
+		if err := os.WriteFile(part+partStampSuffix, data, 0644); err != nil {
+			return err
+		}
+	}
+	out, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	offset, err := resumeOffset(out, src, resume)
+	if err == nil {
+		_, err = io.Copy(out, ctxReader{ctx, src})
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("copy %s (from byte %d): %w", key, offset, err)
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return err
+	}
+	os.Remove(part + partStampSuffix)
+	return nil
+}
+
+func (t *localTarget) Get(ctx context.Context, key, localPath string) error {
+	src, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileFrom(ctx, localPath, in)
+}
+
+func (t *localTarget) Stat(ctx context.Context, key string) (*RemoteObject, error) {
+	p, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrRemoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteObject{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (t *localTarget) List(ctx context.Context, prefix string) ([]RemoteObject, error) {
+	root := t.dir
+	if prefix != "" {
+		var err error
+		if root, err = t.path(prefix); err != nil {
+			return nil, err
+		}
+	}
+	var objs []RemoteObject
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() || isPartFile(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(t.dir, p)
+		if err != nil {
+			return err
+		}
+		objs = append(objs, RemoteObject{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objs, err
+}
+
+func (t *localTarget) Delete(ctx context.Context, key string) error {
+	p, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (t *localTarget) Close() error { return nil }
+
+// partStamp identifies the source a .part file is being written from. It is saved next to
+// the part file, so a leftover part from another archive with the same name is discarded
+// rather than appended to.
+type partStamp struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// partStampSuffix is appended to a .part file's name for its stamp.
+const partStampSuffix = ".src.json"
+
+// isPartFile reports whether p is an unfinished upload or its stamp.
+func isPartFile(p string) bool {
+	return strings.HasSuffix(p, ".part") || strings.HasSuffix(p, ".part"+partStampSuffix)
+}
+
+// stampFile hashes src and leaves it positioned at the start.
+func stampFile(src *os.File) (partStamp, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return partStamp{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return partStamp{}, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return partStamp{}, err
+	}
+	return partStamp{Size: info.Size(), ModTime: info.ModTime().UTC(), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// matches reports whether saved, the stamp read from next to a part file, is for the same
+// source as s.
+func (s partStamp) matches(saved []byte) bool {
+	var prev partStamp
+	if json.Unmarshal(saved, &prev) != nil {
+		return false
+	}
+	return prev.Size == s.Size && prev.ModTime.Equal(s.ModTime) && prev.SHA256 == s.SHA256
+}
+
+// resumeOffset positions out and src after what out already holds. The part file is only
+// resumed when resume is set (its stamp matches src) and it isn't longer than src;
+// otherwise it starts over.
+func resumeOffset(out interface {
+	io.Seeker
+	Truncate(int64) error
+}, src *os.File, resume bool) (int64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	have, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if !resume || have > info.Size() {
+		if err := out.Truncate(0); err != nil {
+			return 0, err
+		}
+		if have, err = out.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := src.Seek(have, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return have, nil
+}
+
+// writeFileFrom writes r to dest through a temp file so a failed download leaves nothing behind.
+func writeFileFrom(ctx context.Context, dest string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".download"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, ctxReader{ctx, r})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// ctxReader stops a copy once ctx is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// joinKey joins key onto a target-level prefix.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return path.Join(prefix, key)
+}
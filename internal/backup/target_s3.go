@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the multipart chunk size. Files up to this size go up in a single PUT.
+const s3PartSize = 16 << 20
+
+// s3Target stores backups in an S3-compatible bucket (AWS, MinIO, Backblaze B2, R2, ...).
+type s3Target struct {
+	core   *minio.Core
+	bucket string
+	prefix string
+}
+
+func newS3Target(s TargetSettings, sec TargetSecrets) (*s3Target, error) {
+	if s.Endpoint == "" || s.Bucket == "" {
+		return nil, errors.New("s3 target needs an endpoint and a bucket")
+	}
+	core, err := minio.NewCore(s.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(sec.AccessKey, sec.SecretKey, ""),
+		Secure: s.UseSSL,
+		Region: s.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
+	}
+	return &s3Target{core: core, bucket: s.Bucket, prefix: strings.Trim(s.Prefix, "/")}, nil
+}
+
+func (t *s3Target) Put(ctx context.Context, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	object := joinKey(t.prefix, key)
+	if info.Size() <= s3PartSize {
+		_, err := t.core.Client.PutObject(ctx, t.bucket, object, f, info.Size(), minio.PutObjectOptions{})
+		return err
+	}
+	return t.putMultipart(ctx, object, f, info.Size())
+}
+
+// putMultipart uploads f in s3PartSize parts, picking up an unfinished upload of the same object
+// and skipping the parts it already has.
+func (t *s3Target) putMultipart(ctx context.Context, object string, f *os.File, size int64) error {
+	uploadID, err := t.findUpload(ctx, object)
+	if err != nil {
+		return err
+	}
+	done := make(map[int]minio.ObjectPart)
+	if uploadID == "" {
+		uploadID, err = t.core.NewMultipartUpload(ctx, t.bucket, object, minio.PutObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("start multipart upload: %w", err)
+		}
+	} else if done, err = t.listParts(ctx, object, uploadID); err != nil {
+		return err
+	}
+
+	var parts []minio.CompletePart
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+s3PartSize {
+		partSize := min(s3PartSize, size-offset)
+		if p, ok := done[n]; ok && p.Size == partSize {
+			parts = append(parts, minio.CompletePart{PartNumber: n, ETag: p.ETag})
+			continue
+		}
+		p, err := t.core.PutObjectPart(ctx, t.bucket, object, uploadID, n,
+			io.NewSectionReader(f, offset, partSize), partSize, minio.PutObjectPartOptions{})
+		if err != nil {
+			// The upload is left open so the next attempt resumes from here.
+			return fmt.Errorf("upload part %d: %w", n, err)
+		}
+		parts = append(parts, minio.CompletePart{PartNumber: n, ETag: p.ETag})
+	}
+
+	if _, err := t.core.CompleteMultipartUpload(ctx, t.bucket, object, uploadID, parts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// findUpload returns the ID of the newest unfinished multipart upload of object, if any.
+func (t *s3Target) findUpload(ctx context.Context, object string) (string, error) {
+	res, err := t.core.ListMultipartUploads(ctx, t.bucket, object, "", "", "", 1000)
+	if err != nil {
+		return "", fmt.Errorf("list multipart uploads: %w", err)
+	}
+	var latest minio.ObjectMultipartInfo
+	for _, u := range res.Uploads {
+		if u.Key == object && u.Initiated.After(latest.Initiated) {
+			latest = u
+		}
+	}
+	return latest.UploadID, nil
+}
+
+func (t *s3Target) listParts(ctx context.Context, object, uploadID string) (map[int]minio.ObjectPart, error) {
+	parts := make(map[int]minio.ObjectPart)
+	marker := 0
+	for {
+		res, err := t.core.ListObjectParts(ctx, t.bucket, object, uploadID, marker, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("list uploaded parts: %w", err)
+		}
+		for _, p := range res.ObjectParts {
+			parts[p.PartNumber] = p
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+func (t *s3Target) Get(ctx context.Context, key, localPath string) error {
+	obj, err := t.core.Client.GetObject(ctx, t.bucket, joinKey(t.prefix, key), minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	return writeFileFrom(ctx, localPath, obj)
+}
+
+func (t *s3Target) Stat(ctx context.Context, key string) (*RemoteObject, error) {
+	info, err := t.core.Client.StatObject(ctx, t.bucket, joinKey(t.prefix, key), minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrRemoteNotFound
+		}
+		return nil, err
+	}
+	return &RemoteObject{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (t *s3Target) List(ctx context.Context, prefix string) ([]RemoteObject, error) {
+	full := joinKey(t.prefix, prefix)
+	if t.prefix != "" && prefix == "" {
+		full += "/"
+	}
+	var objs []RemoteObject
+	for info := range t.core.Client.ListObjects(ctx, t.bucket, minio.ListObjectsOptions{Prefix: full, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		key := info.Key
+		if t.prefix != "" {
+			key = strings.TrimPrefix(key, t.prefix+"/")
+		}
+		objs = append(objs, RemoteObject{Key: key, Size: info.Size, ModTime: info.LastModified})
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
+
+func (t *s3Target) Delete(ctx context.Context, key string) error {
+	return t.core.Client.RemoveObject(ctx, t.bucket, joinKey(t.prefix, key), minio.RemoveObjectOptions{})
+}
+
+func (t *s3Target) Close() error { return nil }
@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpTarget stores backups in a directory on an SSH server.
+type sftpTarget struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	dir    string
+}
+
+func newSFTPTarget(s TargetSettings, sec TargetSecrets) (*sftpTarget, error) {
+	if s.Host == "" || s.User == "" {
+		return nil, errors.New("sftp target needs a host and a user")
+	}
+	if s.HostKey == "" {
+		return nil, errors.New("sftp target needs the server's host key fingerprint")
+	}
+
+	var auth []ssh.AuthMethod
+	if sec.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if sec.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(sec.PrivateKey), []byte(sec.Password))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(sec.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else if sec.Password != "" {
+		auth = append(auth, ssh.Password(sec.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("sftp target needs a password or a private key")
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 22
+	}
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(s.Host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User: s.User,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != s.HostKey {
+				return fmt.Errorf("host key mismatch: server presented %s", got)
+			}
+			return nil
+		},
+		Timeout: 15 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ssh connect: %w", err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sftp session: %w", err)
+	}
+
+	dir := s.Dir
+	if dir == "" {
+		dir = "."
+	}
+	return &sftpTarget{conn: conn, client: client, dir: dir}, nil
+}
+
+func (t *sftpTarget) path(key string) (string, error) {
+	if _, err := safeJoin(".", key); err != nil {
+		return "", err
+	}
+	return path.Join(t.dir, key), nil
+}
+
+func (t *sftpTarget) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+	if info, err := t.client.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	}
+	if err := t.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	if err := t.client.Mkdir(dir); err != nil {
+		// Lost a race with another upload creating the same directory.
+		if info, serr := t.client.Stat(dir); serr == nil && info.IsDir() {
+			return nil
+		}
+		return fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+	return nil
+}
+
+func (t *sftpTarget) Put(ctx context.Context, key, localPath string) error {
+	dest, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if err := t.mkdirAll(path.Dir(dest)); err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Resume a previous partial upload of the same file.
+	stamp, err := stampFile(src)
+	if err != nil {
+		return err
+	}
+	part := dest + ".part"
+	resume := stamp.matches(t.readSmall(part + partStampSuffix))
+	if !resume {
+		if err := t.writeSmall(part+partStampSuffix, stamp); err != nil {
+			return err
+		}
+	}
+	out, err := t.client.OpenFile(part, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", part, err)
+	}
+	offset, err := resumeOffset(out, src, resume)
+	if err == nil {
+		_, err = io.Copy(out, ctxReader{ctx, src})
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("upload %s (from byte %d): %w", key, offset, err)
+	}
+
+	// SFTP v3 rename refuses to overwrite.
+	if err := t.client.Remove(dest); err != nil && !isSFTPNotExist(err) {
+		return fmt.Errorf("replace %s: %w", dest, err)
+	}
+	if err := t.client.Rename(part, dest); err != nil {
+		return err
+	}
+	t.client.Remove(part + partStampSuffix)
+	return nil
+}
+
+// readSmall reads a small remote file such as a part stamp, returning nil if it can't.
+func (t *sftpTarget) readSmall(p string) []byte {
+	f, err := t.client.Open(p)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(io.LimitReader(f, 4096))
+	return data
+}
+
+// writeSmall writes v as JSON to a remote file, replacing it.
+func (t *sftpTarget) writeSmall(p string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := t.client.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", p, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", p, err)
+	}
+	return f.Close()
+}
+
+func (t *sftpTarget) Get(ctx context.Context, key, localPath string) error {
+	src, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	in, err := t.client.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileFrom(ctx, localPath, in)
+}
+
+func (t *sftpTarget) Stat(ctx context.Context, key string) (*RemoteObject, error) {
+	p, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := t.client.Stat(p)
+	if err != nil {
+		if isSFTPNotExist(err) {
+			return nil, ErrRemoteNotFound
+		}
+		return nil, err
+	}
+	return &RemoteObject{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (t *sftpTarget) List(ctx context.Context, prefix string) ([]RemoteObject, error) {
+	root := t.dir
+	if prefix != "" {
+		var err error
+		if root, err = t.path(prefix); err != nil {
+			return nil, err
+		}
+	}
+	var objs []RemoteObject
+	walker := t.client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if isSFTPNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		info := walker.Stat()
+		if !info.Mode().IsRegular() || isPartFile(walker.Path()) {
+			continue
+		}
+		key := strings.TrimPrefix(walker.Path(), strings.TrimSuffix(t.dir, "/")+"/")
+		objs = append(objs, RemoteObject{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objs, nil
+}
+
+func (t *sftpTarget) Delete(ctx context.Context, key string) error {
+	p, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if err := t.client.Remove(p); err != nil && !isSFTPNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (t *sftpTarget) Close() error {
+	t.client.Close()
+	return t.conn.Close()
+}
+
+func isSFTPNotExist(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+	var se *sftp.StatusError
+	return errors.As(err, &se) && se.Code == 2 // SSH_FX_NO_SUCH_FILE
+}
@@ -21,6 +21,23 @@ type AppConfig struct {
 	StartWithWindows  bool   `mapstructure:"start_with_windows" json:"start_with_windows"`
 	AutoUpdate        bool   `mapstructure:"auto_update" json:"auto_update"`
 	DiscordWebhook    string `mapstructure:"discord_webhook" json:"discord_webhook"`
+
+	// AppStateBackupKeep is how many daily app-state backups to keep; 0 turns them off.
+	AppStateBackupKeep int `mapstructure:"appstate_backup_keep" json:"appstate_backup_keep"`
+
+	// BackupFormat is "zip" or "tar.zst" for backups that don't go into the snapshot store.
+	BackupFormat string `mapstructure:"backup_format" json:"backup_format"`
+	// BackupCompressionLevel is the tar.zst level, 1 (fastest) to 4 (smallest); 0 is the default.
+	BackupCompressionLevel int `mapstructure:"backup_compression_level" json:"backup_compression_level"`
+	// BackupConcurrency caps the CPUs used for compression; 0 picks automatically.
+	BackupConcurrency int `mapstructure:"backup_concurrency" json:"backup_concurrency"`
+
+	// SteamCMDConcurrency is how many SteamCMD jobs may run at once. SteamCMD shares state in
+	// its own directory, so anything above 1 is at your own risk.
+	SteamCMDConcurrency int `mapstructure:"steamcmd_concurrency" json:"steamcmd_concurrency"`
+
+	// PluginCatalogURL is an optional remote plugin index laid over the built-in catalog.
+	PluginCatalogURL string `mapstructure:"plugin_catalog_url" json:"plugin_catalog_url"`
 }
 
 // Load loads the configuration from %APPDATA%\CS2Admin\config.yaml.
@@ -55,6 +72,9 @@ func Load() (*AppConfig, error) {
 		StartWithWindows:  false,
 		AutoUpdate:        true,
 		DiscordWebhook:    "",
+
+		AppStateBackupKeep: 7,
+		BackupFormat:       "zip",
 	}
 
 	// Create app data dir
@@ -81,6 +101,12 @@ func Load() (*AppConfig, error) {
 	v.SetDefault("start_with_windows", false)
 	v.SetDefault("auto_update", true)
 	v.SetDefault("discord_webhook", "")
+	v.SetDefault("appstate_backup_keep", 7)
+	v.SetDefault("backup_format", "zip")
+	v.SetDefault("backup_compression_level", 0)
+	v.SetDefault("backup_concurrency", 0)
+	v.SetDefault("steamcmd_concurrency", 1)
+	v.SetDefault("plugin_catalog_url", "")
 
 	// Try to read existing config
 	if err := v.ReadInConfig(); err != nil {
@@ -106,8 +132,11 @@ func (c *AppConfig) Save() error {
 	if c.AppDataDir == "" {
 		return errors.New("app_data_dir is empty")
 	}
+	return c.SaveAs(filepath.Join(c.AppDataDir, "config.yaml"))
+}
 
-	configPath := filepath.Join(c.AppDataDir, "config.yaml")
+// SaveAs writes the configuration to the YAML file at configPath.
+func (c *AppConfig) SaveAs(configPath string) error {
 	v := viper.New()
 	v.SetConfigType("yaml")
 	v.SetConfigFile(configPath)
@@ -123,8 +152,29 @@ func (c *AppConfig) Save() error {
 	v.Set("start_with_windows", c.StartWithWindows)
 	v.Set("auto_update", c.AutoUpdate)
 	v.Set("discord_webhook", c.DiscordWebhook)
+	v.Set("appstate_backup_keep", c.AppStateBackupKeep)
+	v.Set("backup_format", c.BackupFormat)
+	v.Set("backup_compression_level", c.BackupCompressionLevel)
+	v.Set("backup_concurrency", c.BackupConcurrency)
+	v.Set("steamcmd_concurrency", c.SteamCMDConcurrency)
+	v.Set("plugin_catalog_url", c.PluginCatalogURL)
+
+	return v.WriteConfigAs(configPath)
+}
 
-	return v.WriteConfig()
+// ReadFile reads a config file without applying defaults or creating directories.
+func ReadFile(configPath string) (*AppConfig, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(configPath)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	cfg := &AppConfig{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 // GetDBPath returns the path to the cs2admin.db database file.
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CfgDocument is a .cfg file kept line by line, so it can be edited without losing
+// comments, blank lines, exec lines or other commands. A document that isn't edited
+// writes back byte-for-byte.
+//
+// A line is treated as a cvar when it is a bare `name`, `name value` or `name "value"`,
+// or `name several words` for a cvar in CvarDatabase, and name isn't a known command such
+// as exec or bind. Anything else, including lines with several `;`-separated commands,
+// is kept as written and never edited.
+type CfgDocument struct {
+	bom   string // UTF-8 byte order mark, if the file had one
+	eol   string // line ending used for new lines
+	lines []cfgLine
+}
+
+type cfgLine struct {
+	text string // without the line ending
+	eol  string // "\n", "\r\n" or "" for a last line without one
+
+	// Set for cvar lines: the name, and the span of the value in text, quotes included.
+	key      string
+	valStart int
+	valEnd   int
+	quoted   bool
+}
+
+func (l *cfgLine) isCvar() bool { return l.key != "" }
+
+// value returns the cvar value without its quotes.
+func (l *cfgLine) value() string {
+	v := l.text[l.valStart:l.valEnd]
+	if l.quoted {
+		v = strings.TrimPrefix(v, `"`)
+		v = strings.TrimSuffix(v, `"`)
+	}
+	return v
+}
+
+// cfgCommands are console commands that look like cvar lines (a name and at most one
+// argument) but aren't cvars; lines starting with them are never edited.
+var cfgCommands = map[string]bool{
+	"exec": true, "execifexists": true, "alias": true, "bind": true, "unbind": true,
+	"echo": true, "say": true, "say_team": true, "log": true, "status": true, "quit": true,
+	"map": true, "changelevel": true, "host_workshop_map": true, "host_workshop_collection": true,
+	"writeid": true, "writeip": true, "banid": true, "banip": true, "addip": true,
+	"removeid": true, "removeip": true, "kick": true, "kickid": true,
+	"mp_warmup_start": true, "mp_warmup_end": true, "mp_warmup_pause": true, "mp_restartgame": true,
+	"mp_swapteams": true, "mp_scrambleteams": true, "mp_pause_match": true, "mp_unpause_match": true,
+	"bot_add": true, "bot_add_t": true, "bot_add_ct": true, "bot_kick": true, "bot_kill": true,
+	"tv_record": true, "tv_stoprecord": true, "css_plugins": true, "meta": true,
+}
+
+// ParseCfg parses the contents of a .cfg file.
+func ParseCfg(data []byte) *CfgDocument {
+	doc := &CfgDocument{eol: "\n"}
+	s := string(data)
+	if rest, ok := strings.CutPrefix(s, "\ufeff"); ok {
+		doc.bom, s = "\ufeff", rest
+	}
+	if strings.Contains(s, "\r\n") {
+		doc.eol = "\r\n"
+	}
+	for s != "" {
+		var line cfgLine
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			line.text, line.eol, s = s[:i], "\n", s[i+1:]
+			if t, ok := strings.CutSuffix(line.text, "\r"); ok {
+				line.text, line.eol = t, "\r\n"
+			}
+		} else {
+			line.text, s = s, ""
+		}
+		line.parse()
+		doc.lines = append(doc.lines, line)
+	}
+	return doc
+}
+
+// ReadCfgDocument reads and parses a .cfg file.
+func ReadCfgDocument(path string) (*CfgDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCfg(data), nil
+}
+
+// Bytes returns the document as file contents.
+func (d *CfgDocument) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(d.bom)
+	for _, l := range d.lines {
+		b.WriteString(l.text)
+		b.WriteString(l.eol)
+	}
+	return b.Bytes()
+}
+
+// WriteFile writes the document to path.
+func (d *CfgDocument) WriteFile(path string) error {
+	return os.WriteFile(path, d.Bytes(), 0644)
+}
+
+// Cvars returns the cvars the document sets. When a cvar is set more than once the last
+// value wins, as it does in game.
+func (d *CfgDocument) Cvars() map[string]string {
+	result := make(map[string]string)
+	seen := make(map[string]string) // lowercased name -> name as written
+	for i := range d.lines {
+		l := &d.lines[i]
+		if !l.isCvar() {
+			continue
+		}
+		lower := strings.ToLower(l.key)
+		if prev, ok := seen[lower]; ok {
+			delete(result, prev)
+		}
+		seen[lower] = l.key
+		result[l.key] = l.value()
+	}
+	return result
+}
+
+// Get returns the value of a cvar. Names are matched case-insensitively.
+func (d *CfgDocument) Get(key string) (string, bool) {
+	if i := d.last(key); i >= 0 {
+		return d.lines[i].value(), true
+	}
+	return "", false
+}
+
+// Set sets a cvar. An existing line has its value replaced in place, keeping its
+// quoting, spacing and trailing comment; a new cvar is added after the last cvar line.
+func (d *CfgDocument) Set(key, value string) error {
+	if err := validateCfgCvar(key, value); err != nil {
+		return err
+	}
+	if i := d.last(key); i >= 0 {
+		l := &d.lines[i]
+		if l.value() == value {
+			return nil
+		}
+		quoted := l.quoted || needsCfgQuotes(value)
+		v := value
+		if quoted {
+			v = `"` + value + `"`
+		}
+		if l.valStart == l.valEnd {
+			// A bare name: the value goes after it.
+			l.text = l.text[:l.valStart] + " " + v + l.text[l.valEnd:]
+			l.valStart++
+		} else {
+			l.text = l.text[:l.valStart] + v + l.text[l.valEnd:]
+		}
+		l.valEnd = l.valStart + len(v)
+		l.quoted = quoted
+		return nil
+	}
+
+	line := cfgLine{text: key + ` "` + value + `"`}
+	line.parse()
+	at := len(d.lines)
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if d.lines[i].isCvar() {
+			at = i + 1
+			break
+		}
+	}
+	if at == len(d.lines) && at > 0 && d.lines[at-1].eol == "" {
+		// Keep a file that didn't end in a newline that way.
+		d.lines[at-1].eol = d.eol
+	} else {
+		line.eol = d.eol
+	}
+	d.lines = append(d.lines, cfgLine{})
+	copy(d.lines[at+1:], d.lines[at:])
+	d.lines[at] = line
+	return nil
+}
+
+// Unset removes every line setting the cvar and reports whether there were any.
+func (d *CfgDocument) Unset(key string) bool {
+	noFinalEOL := len(d.lines) > 0 && d.lines[len(d.lines)-1].eol == ""
+	kept := d.lines[:0]
+	removed := false
+	for _, l := range d.lines {
+		if l.isCvar() && strings.EqualFold(l.key, key) {
+			removed = true
+			continue
+		}
+		kept = append(kept, l)
+	}
+	if removed && noFinalEOL && len(kept) > 0 {
+		kept[len(kept)-1].eol = ""
+	}
+	d.lines = kept
+	return removed
+}
+
+// Sync makes the document set exactly cvars: each is set with Set, and cvar lines for
+// names not in cvars are removed. Comments and commands are left alone. New cvars are
+// added in name order.
+func (d *CfgDocument) Sync(cvars map[string]string) error {
+	want := make(map[string]bool, len(cvars))
+	keys := make([]string, 0, len(cvars))
+	for k := range cvars {
+		want[strings.ToLower(k)] = true
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := d.Set(k, cvars[k]); err != nil {
+			return err
+		}
+	}
+	for k := range d.Cvars() {
+		if !want[strings.ToLower(k)] {
+			d.Unset(k)
+		}
+	}
+	return nil
+}
+
+// last returns the index of the last line setting key, or -1.
+func (d *CfgDocument) last(key string) int {
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if d.lines[i].isCvar() && strings.EqualFold(d.lines[i].key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+type cfgToken struct {
+	start, end int // span in the line, quotes included
+	quoted     bool
+}
+
+// parse classifies the line, filling in key and the value span for cvar lines.
+func (l *cfgLine) parse() {
+	l.key, l.valStart, l.valEnd, l.quoted = "", 0, 0, false
+	toks, ok := tokenizeCfgLine(l.text)
+	if !ok || len(toks) == 0 {
+		return
+	}
+	key := l.text[toks[0].start:toks[0].end]
+	if toks[0].quoted {
+		key = strings.Trim(key, `"`)
+	}
+	if key == "" || cfgCommands[strings.ToLower(key)] {
+		return
+	}
+	switch {
+	case len(toks) == 1:
+		// A bare `sv_password` reads as the cvar with an empty value.
+		l.key, l.valStart, l.valEnd = key, toks[0].end, toks[0].end
+	case len(toks) == 2:
+		l.key, l.valStart, l.valEnd, l.quoted = key, toks[1].start, toks[1].end, toks[1].quoted
+	case GetCvarByName(key) != nil:
+		// `hostname My Server` sets a known cvar to the rest of the line. Other lines with
+		// several arguments, or quotes among them, are commands such as `css_plugins load X`.
+		for _, t := range toks {
+			if t.quoted {
+				return
+			}
+		}
+		l.key, l.valStart, l.valEnd = key, toks[1].start, toks[len(toks)-1].end
+	}
+}
+
+// tokenizeCfgLine splits a line into tokens, stopping at a `//` comment. ok is false
+// for lines holding several `;`-separated commands.
+func tokenizeCfgLine(s string) ([]cfgToken, bool) {
+	var toks []cfgToken
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			return toks, true
+		case c == ';':
+			return toks, false
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				toks = append(toks, cfgToken{start: i, end: len(s), quoted: true})
+				return toks, true
+			}
+			toks = append(toks, cfgToken{start: i, end: i + end + 2, quoted: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '"' && s[i] != ';' && !strings.HasPrefix(s[i:], "//") {
+				i++
+			}
+			toks = append(toks, cfgToken{start: start, end: i})
+		}
+	}
+	return toks, true
+}
+
+func validateCfgCvar(key, value string) error {
+	if key == "" || strings.ContainsAny(key, " \t\";\r\n") || strings.Contains(key, "//") {
+		return fmt.Errorf("invalid cvar name %q", key)
+	}
+	if cfgCommands[strings.ToLower(key)] {
+		return fmt.Errorf("%s is a command, not a cvar", key)
+	}
+	if strings.ContainsAny(value, "\"\r\n") {
+		return fmt.Errorf("%s: values can't contain quotes or line breaks", key)
+	}
+	return nil
+}
+
+// needsCfgQuotes reports whether value must be quoted to be read back as one value.
+func needsCfgQuotes(value string) bool {
+	return value == "" || strings.ContainsAny(value, " \t;{}()':") || strings.Contains(value, "//")
+}
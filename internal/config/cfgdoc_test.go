@@ -0,0 +1,141 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCfgDocument(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		in    string
+		cvars map[string]string        // what Cvars reads from in
+		edit  func(*CfgDocument) error // nil: only the round trip is checked
+		want  string
+	}{
+		{
+			name:  "bom and crlf",
+			in:    "\ufeff// server\r\nhostname \"Old\"\r\nsv_cheats 0\r\n",
+			cvars: map[string]string{"hostname": "Old", "sv_cheats": "0"},
+			edit:  func(d *CfgDocument) error { return d.Set("sv_cheats", "1") },
+			want:  "\ufeff// server\r\nhostname \"Old\"\r\nsv_cheats 1\r\n",
+		},
+		{
+			name:  "new cvar in crlf file",
+			in:    "hostname \"Old\"\r\n\r\n// end\r\n",
+			cvars: map[string]string{"hostname": "Old"},
+			edit:  func(d *CfgDocument) error { return d.Set("sv_lan", "1") },
+			want:  "hostname \"Old\"\r\nsv_lan \"1\"\r\n\r\n// end\r\n",
+		},
+		{
+			name:  "no final newline",
+			in:    "sv_cheats 0\nmp_maxrounds 24",
+			cvars: map[string]string{"sv_cheats": "0", "mp_maxrounds": "24"},
+			edit:  func(d *CfgDocument) error { return d.Set("sv_lan", "1") },
+			want:  "sv_cheats 0\nmp_maxrounds 24\nsv_lan \"1\"",
+		},
+		{
+			name:  "trailing comment",
+			in:    "mp_maxrounds 24 // regulation\n",
+			cvars: map[string]string{"mp_maxrounds": "24"},
+			edit:  func(d *CfgDocument) error { return d.Set("mp_maxrounds", "30") },
+			want:  "mp_maxrounds 30 // regulation\n",
+		},
+		{
+			name:  "quoted value gains spaces",
+			in:    "hostname \"Old\"\t// name\n",
+			cvars: map[string]string{"hostname": "Old"},
+			edit:  func(d *CfgDocument) error { return d.Set("hostname", "My Server") },
+			want:  "hostname \"My Server\"\t// name\n",
+		},
+		{
+			name:  "exec and multi-command lines",
+			in:    "exec banned_user.cfg\nsv_cheats 0; mp_maxrounds 24\ncss_plugins load Foo\nmp_restartgame 1\n",
+			cvars: map[string]string{},
+			edit:  func(d *CfgDocument) error { return d.Sync(map[string]string{}) },
+			want:  "exec banned_user.cfg\nsv_cheats 0; mp_maxrounds 24\ncss_plugins load Foo\nmp_restartgame 1\n",
+		},
+		{
+			name:  "bare name",
+			in:    "sv_password // none\n",
+			cvars: map[string]string{"sv_password": ""},
+			edit:  func(d *CfgDocument) error { return d.Set("sv_password", "abc") },
+			want:  "sv_password abc // none\n",
+		},
+		{
+			name:  "multi-word known cvar",
+			in:    "hostname My Server\n",
+			cvars: map[string]string{"hostname": "My Server"},
+			edit:  func(d *CfgDocument) error { return d.Set("hostname", "Other Server") },
+			want:  "hostname \"Other Server\"\n",
+		},
+		{
+			name:  "last setting wins",
+			in:    "sv_cheats 0\nSV_CHEATS 1\n",
+			cvars: map[string]string{"SV_CHEATS": "1"},
+			edit:  func(d *CfgDocument) error { return d.Set("sv_cheats", "0") },
+			want:  "sv_cheats 0\nSV_CHEATS 0\n",
+		},
+		{
+			name:  "sync removes and adds",
+			in:    "// top\nhostname \"Old\"\nsv_cheats 1 // testing\nexec extra.cfg\nsv_cheats 0",
+			cvars: map[string]string{"hostname": "Old", "sv_cheats": "0"},
+			edit: func(d *CfgDocument) error {
+				return d.Sync(map[string]string{"hostname": "New", "sv_lan": "1"})
+			},
+			want: "// top\nhostname \"New\"\nexec extra.cfg\nsv_lan \"1\"",
+		},
+		{
+			name:  "unset",
+			in:    "sv_cheats 1\nmp_maxrounds 24\n",
+			cvars: map[string]string{"sv_cheats": "1", "mp_maxrounds": "24"},
+			edit: func(d *CfgDocument) error {
+				if !d.Unset("SV_CHEATS") {
+					t.Error("Unset found nothing")
+				}
+				return nil
+			},
+			want: "mp_maxrounds 24\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := ParseCfg([]byte(tc.in))
+			if got := string(doc.Bytes()); got != tc.in {
+				t.Fatalf("round trip changed the file:\ngot  %q\nwant %q", got, tc.in)
+			}
+			if got := doc.Cvars(); !reflect.DeepEqual(got, tc.cvars) {
+				t.Errorf("Cvars() = %q, want %q", got, tc.cvars)
+			}
+			if tc.edit == nil {
+				return
+			}
+			if err := tc.edit(doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(doc.Bytes()); got != tc.want {
+				t.Errorf("after edit:\ngot  %q\nwant %q", got, tc.want)
+			}
+			// The edited document must read back the same way it was built.
+			if got, want := ParseCfg(doc.Bytes()).Cvars(), doc.Cvars(); !reflect.DeepEqual(got, want) {
+				t.Errorf("reparsed Cvars() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCfgDocumentSetRejects(t *testing.T) {
+	doc := ParseCfg(nil)
+	for _, kv := range [][2]string{
+		{"exec", "server.cfg"},
+		{"bad name", "1"},
+		{"hostname", `say "hi"`},
+		{"hostname", "a\nb"},
+	} {
+		if err := doc.Set(kv[0], kv[1]); err == nil {
+			t.Errorf("Set(%q, %q) succeeded", kv[0], kv[1])
+		}
+	}
+	if len(doc.Bytes()) != 0 {
+		t.Errorf("rejected sets changed the document: %q", doc.Bytes())
+	}
+}
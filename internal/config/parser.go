@@ -2,118 +2,37 @@ package config
 
 import (
 	"bufio"
+	"errors"
+	"io/fs"
 	"os"
-	"sort"
 	"strings"
 )
 
-// ReadCfgFile reads a CS2 .cfg file (e.g. server.cfg) and parses lines in format
-// `key "value"` or `key value`. Returns a map of key-value pairs.
-// Ignores comments (lines starting with //). Handles quoted and unquoted values.
+// ReadCfgFile reads a CS2 .cfg file (e.g. server.cfg) and returns the cvars it sets,
+// in format `key "value"` or `key value`. Comments and commands such as exec are
+// skipped; see CfgDocument.
 func ReadCfgFile(path string) (map[string]string, error) {
-	f, err := os.Open(path)
+	doc, err := ReadCfgDocument(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	result := make(map[string]string)
-	scanner := bufio.NewScanner(f)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "//") {
-			continue
-		}
-
-		key, value, ok := parseCfgLine(line)
-		if !ok {
-			continue
-		}
-
-		if key != "" {
-			result[key] = value
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return doc.Cvars(), nil
 }
 
-// parseCfgLine parses a single config line in format `key "value"` or `key value`.
-// Returns (key, value, ok).
-func parseCfgLine(line string) (string, string, bool) {
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return "", "", false
-	}
-
-	// Find first space to split key from value
-	idx := strings.Index(line, " ")
-	if idx < 0 {
-		// No space: treat whole line as key with empty value
-		return line, "", true
-	}
-
-	key := strings.TrimSpace(line[:idx])
-	valuePart := strings.TrimSpace(line[idx+1:])
-
-	if key == "" {
-		return "", "", false
+// EditCfgFile applies edit to the .cfg file at path and writes it back. The file is
+// created if it doesn't exist; otherwise comments, commands and layout are kept.
+func EditCfgFile(path string, edit func(doc *CfgDocument) error) error {
+	doc, err := ReadCfgDocument(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		doc, err = ParseCfg(nil), nil
 	}
-
-	// Parse value - quoted or unquoted
-	var value string
-	if strings.HasPrefix(valuePart, `"`) {
-		// Quoted value: find closing quote
-		valuePart = valuePart[1:]
-		endIdx := strings.Index(valuePart, `"`)
-		if endIdx >= 0 {
-			value = valuePart[:endIdx]
-		} else {
-			value = valuePart
-		}
-	} else {
-		// Unquoted: take until end of line or next space for simplicity
-		// CS2 config values can have spaces in unquoted form, so we take rest of line
-		value = valuePart
-	}
-
-	return key, value, true
-}
-
-// WriteCfgFile writes a map of cvars to a .cfg file in `key "value"` format,
-// one per line. Keys are sorted alphabetically.
-func WriteCfgFile(path string, cvars map[string]string) error {
-	if cvars == nil {
-		cvars = make(map[string]string)
-	}
-
-	keys := make([]string, 0, len(cvars))
-	for k := range cvars {
-		keys = append(keys, k)
+	if err != nil {
+		return err
 	}
-	sort.Strings(keys)
-
-	var sb strings.Builder
-	for _, k := range keys {
-		v := cvars[k]
-		sb.WriteString(k)
-		sb.WriteString(" \"")
-		sb.WriteString(escapeCfgValue(v))
-		sb.WriteString("\"\n")
+	if err := edit(doc); err != nil {
+		return err
 	}
-
-	return os.WriteFile(path, []byte(sb.String()), 0644)
-}
-
-func escapeCfgValue(v string) string {
-	return strings.ReplaceAll(v, `"`, `\"`)
+	return doc.WriteFile(path)
 }
 
 // ReadMapcycle reads a mapcycle.txt file and returns a list of map names,
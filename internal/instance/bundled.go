@@ -0,0 +1,135 @@
+package instance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cs2admin/internal/pkg/peinfo"
+)
+
+// CS2Admin's own CounterStrikeSharp plugins are built from plugins/ and embedded in the app
+// (see bundled/README.md), so each app release installs the plugin builds it was made with.
+
+//go:embed bundled
+var bundledFS embed.FS
+
+const (
+	// StatsPlugin records matches for the Matches page.
+	StatsPlugin = "CS2AdminStats"
+	// SkinsPlugin applies skins from the skin database.
+	SkinsPlugin = "CS2AdminSkins"
+)
+
+// BundledPlugin is one of CS2Admin's own plugins.
+type BundledPlugin struct {
+	Name        string `json:"name"` // plugin directory and assembly name
+	Description string `json:"description"`
+	// Version is the build embedded in the app. Available is false when this build of the
+	// app has none.
+	Version   string `json:"version"`
+	Available bool   `json:"available"`
+
+	sum string // hex SHA-256 of the embedded DLL
+}
+
+// BundledPluginStatus is a bundled plugin as installed on an instance.
+type BundledPluginStatus struct {
+	BundledPlugin
+	Installed        bool   `json:"installed"`
+	Enabled          bool   `json:"enabled"`
+	InstalledVersion string `json:"installed_version,omitempty"`
+	// Mismatch is set when the installed build isn't the one embedded in the app.
+	Mismatch bool `json:"mismatch"`
+}
+
+var bundledPlugins = []BundledPlugin{
+	{Name: StatsPlugin, Description: "Records match statistics for the Stats tab"},
+	{Name: SkinsPlugin, Description: "Applies weapon skins from the skin database"},
+}
+
+// BundledPlugins lists CS2Admin's own plugins with the versions embedded in the app.
+func BundledPlugins() []BundledPlugin {
+	out := make([]BundledPlugin, len(bundledPlugins))
+	for i, p := range bundledPlugins {
+		data, err := bundledFS.ReadFile("bundled/" + p.Name + "/" + p.Name + ".dll")
+		if err == nil {
+			p.Available = true
+			h := sha256.Sum256(data)
+			p.sum = hex.EncodeToString(h[:])
+			if v, err := peinfo.ReadFrom(bytes.NewReader(data)); err == nil {
+				p.Version = v.Best()
+			}
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// BundledStatus reports which of CS2Admin's own plugins are installed on the instance and
+// whether they match the builds embedded in the app. The installed DLL is compared by
+// SHA-256, so a rebuilt plugin with the same version number still counts as a mismatch.
+func BundledStatus(installPath string) []BundledPluginStatus {
+	var out []BundledPluginStatus
+	for _, p := range BundledPlugins() {
+		st := BundledPluginStatus{BundledPlugin: p}
+		for _, sub := range []struct {
+			rel     string
+			enabled bool
+		}{{cssPluginsDir, true}, {cssPluginsDir + "/disabled", false}} {
+			dir := filepath.Join(installPath, filepath.FromSlash(sub.rel), p.Name)
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				st.Installed, st.Enabled = true, sub.enabled
+				st.InstalledVersion = cssPluginVersion(dir)
+				if p.Available {
+					sum, err := fileSHA256(filepath.Join(dir, p.Name+".dll"))
+					st.Mismatch = err != nil || sum != p.sum
+				}
+				break
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// InstallBundledPlugin installs or upgrades one of CS2Admin's own plugins from the build
+// embedded in the app, through the same manifest and rollback path as other plugins.
+func InstallBundledPlugin(installPath, name string) (*PluginManifest, error) {
+	var found string
+	for _, p := range bundledPlugins {
+		if strings.EqualFold(p.Name, name) {
+			found = p.Name
+		}
+	}
+	if found == "" {
+		return nil, fmt.Errorf("%s is not a CS2Admin plugin", name)
+	}
+	sub, err := fs.Sub(bundledFS, "bundled/"+found)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fsEntries(sub)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil, fmt.Errorf("%s is not bundled with this build of CS2Admin", found)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := openPackage(entries, found, "bundled with CS2Admin")
+	if err != nil {
+		return nil, err
+	}
+	defer pkg.Close()
+	if pkg.Name != found {
+		return nil, fmt.Errorf("bundled %s build holds %s", found, pkg.Name)
+	}
+	return pkg.Install(installPath)
+}
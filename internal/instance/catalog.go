@@ -0,0 +1,579 @@
+package instance
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"cs2admin/internal/pkg/logger"
+	"cs2admin/internal/pkg/minisign"
+)
+
+// The plugin catalog describes where each plugin comes from and how it is laid out. The
+// built-in catalog ships with the app; a remote index and a local file can add plugins or
+// override fields of existing ones, e.g. to pin a different version.
+
+//go:embed catalog.json
+var builtinCatalog []byte
+
+const (
+	// RemoteCatalogCache is the cached copy of the remote index, in the app data dir.
+	RemoteCatalogCache = "plugin-catalog.remote.yaml"
+	// LocalCatalogFile is the user's own catalog in the app data dir; .yml and .json work too.
+	LocalCatalogFile = "plugin-catalog.yaml"
+)
+
+var localCatalogFiles = []string{LocalCatalogFile, "plugin-catalog.yml", "plugin-catalog.json"}
+
+// Catalog is a list of installable plugins. JSON is valid YAML, so either format parses.
+type Catalog struct {
+	Plugins []CatalogPlugin `json:"plugins" yaml:"plugins"`
+}
+
+// CatalogPlugin describes one plugin. Paths are install-relative and slash separated.
+type CatalogPlugin struct {
+	ID          string   `json:"id" yaml:"id"`
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases"`
+	// Version is the pinned release; empty or "latest" installs the newest one.
+	Version     string       `json:"version" yaml:"version"`
+	Source      PluginSource `json:"source" yaml:"source"`
+	InstallRoot string       `json:"install_root" yaml:"install_root"`
+	// ExpectDir is the directory the archive should produce under InstallRoot; a single
+	// wrapper folder around anything else is stripped.
+	ExpectDir string `json:"expect_dir" yaml:"expect_dir"`
+	Detect    string `json:"detect" yaml:"detect"` // present when installed; InstallRoot/ExpectDir if empty
+	// Checksums are SHA-256 hashes of the pinned version's assets, keyed like Source.Assets.
+	// GitHub's own asset digests are checked when there is none.
+	Checksums map[string]string `json:"checksums,omitempty" yaml:"checksums"`
+	// MinisignKey is the minisign public key that signs the assets, if the author signs
+	// them; the signature is read from the asset URL with ".minisig" appended.
+	MinisignKey string `json:"minisign_key,omitempty" yaml:"minisign_key"`
+	// VersionFile is read by DetectVersion for the installed version.
+	VersionFile string   `json:"version_file,omitempty" yaml:"version_file"`
+	Depends     []string `json:"depends,omitempty" yaml:"depends"`
+	// ConfigPaths are the plugin's config files, or glob patterns matching them.
+	ConfigPaths []string `json:"config_paths,omitempty" yaml:"config_paths"`
+	// ConfigSchemas describe config files for the editor, keyed by path or pattern; files
+	// without one get a schema inferred from their contents.
+	ConfigSchemas map[string]*ConfigSchema `json:"config_schemas,omitempty" yaml:"config_schemas"`
+}
+
+// PluginSource says where releases are downloaded from. Assets are keyed by GOOS, or "*" for
+// any; "{version}" in them is replaced with the version being installed.
+type PluginSource struct {
+	Type string `json:"type" yaml:"type"`           // "github" or "url"
+	Repo string `json:"repo,omitempty" yaml:"repo"` // github: owner/name
+	// Assets are release asset name patterns (github, path.Match syntax) or download URLs (url).
+	Assets map[string]string `json:"assets" yaml:"assets"`
+	// Latest, for url sources, points at a file holding the name or URL of the newest build.
+	Latest map[string]string `json:"latest,omitempty" yaml:"latest"`
+}
+
+// ResolvedPlugin is a concrete download for a catalog plugin.
+type ResolvedPlugin struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256,omitempty"` // expected hash of the download, if known
+}
+
+// ParseCatalog parses a catalog in JSON or YAML.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse plugin catalog: %w", err)
+	}
+	return &c, nil
+}
+
+// LoadCatalog returns the built-in catalog overlaid with the cached remote index and the
+// local catalog file from appDataDir. A broken overlay is logged and skipped so installs
+// keep working.
+func LoadCatalog(appDataDir string) (*Catalog, error) {
+	cat, err := ParseCatalog(builtinCatalog)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filepath.Join(appDataDir, RemoteCatalogCache)}
+	for _, name := range localCatalogFiles {
+		files = append(files, filepath.Join(appDataDir, name))
+	}
+	for _, p := range files {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			var over *Catalog
+			if over, err = ParseCatalog(data); err == nil {
+				merged := cat.merge(over)
+				if err = merged.Validate(); err == nil {
+					cat = merged
+					continue
+				}
+			}
+		}
+		logger.Log.Warn().Err(err).Str("file", p).Msg("Ignoring plugin catalog")
+	}
+	return cat, cat.Validate()
+}
+
+// FetchRemoteCatalog downloads the remote index and, if it is valid on top of the built-in
+// catalog, caches it in appDataDir for LoadCatalog.
+func FetchRemoteCatalog(indexURL, appDataDir string) error {
+	data, err := httpGet(indexURL, 10<<20)
+	if err != nil {
+		return fmt.Errorf("fetch plugin catalog: %w", err)
+	}
+	over, err := ParseCatalog(data)
+	if err != nil {
+		return err
+	}
+	base, err := ParseCatalog(builtinCatalog)
+	if err != nil {
+		return err
+	}
+	if err := base.merge(over).Validate(); err != nil {
+		return fmt.Errorf("remote plugin catalog: %w", err)
+	}
+	p := filepath.Join(appDataDir, RemoteCatalogCache)
+	if err := os.WriteFile(p+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// merge returns c with over's plugins laid on top: fields set in over replace those of the
+// plugin with the same ID, and new IDs are appended.
+func (c *Catalog) merge(over *Catalog) *Catalog {
+	out := &Catalog{Plugins: append([]CatalogPlugin(nil), c.Plugins...)}
+	for _, o := range over.Plugins {
+		i := out.index(o.ID)
+		if i < 0 {
+			out.Plugins = append(out.Plugins, o)
+			continue
+		}
+		p := &out.Plugins[i]
+		if o.Name != "" {
+			p.Name = o.Name
+		}
+		if o.Description != "" {
+			p.Description = o.Description
+		}
+		if o.Aliases != nil {
+			p.Aliases = o.Aliases
+		}
+		if o.Version != "" {
+			p.Version = o.Version
+		}
+		if o.Source.Type != "" {
+			p.Source = o.Source
+		}
+		if o.InstallRoot != "" {
+			p.InstallRoot = o.InstallRoot
+		}
+		if o.ExpectDir != "" {
+			p.ExpectDir = o.ExpectDir
+		}
+		if o.Detect != "" {
+			p.Detect = o.Detect
+		}
+		if o.Checksums != nil {
+			p.Checksums = o.Checksums
+		}
+		if o.MinisignKey != "" {
+			p.MinisignKey = o.MinisignKey
+		}
+		if o.VersionFile != "" {
+			p.VersionFile = o.VersionFile
+		}
+		if o.Depends != nil {
+			p.Depends = o.Depends
+		}
+		if o.ConfigPaths != nil {
+			p.ConfigPaths = o.ConfigPaths
+		}
+		if o.ConfigSchemas != nil {
+			p.ConfigSchemas = o.ConfigSchemas
+		}
+	}
+	return out
+}
+
+func (c *Catalog) index(id string) int {
+	for i := range c.Plugins {
+		if strings.EqualFold(c.Plugins[i].ID, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate checks that every plugin is complete, stays inside the install and depends only
+// on known plugins without cycles.
+func (c *Catalog) Validate() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Plugins {
+		id := strings.ToLower(p.ID)
+		switch {
+		case id == "":
+			return fmt.Errorf("plugin %q has no id", p.Name)
+		case seen[id]:
+			return fmt.Errorf("plugin %s is listed twice", p.ID)
+		case p.Source.Type != "github" && p.Source.Type != "url":
+			return fmt.Errorf("plugin %s: unknown source type %q", p.ID, p.Source.Type)
+		case p.Source.Type == "github" && strings.Count(p.Source.Repo, "/") != 1:
+			return fmt.Errorf("plugin %s: github source needs repo as owner/name", p.ID)
+		case len(p.Source.Assets) == 0:
+			return fmt.Errorf("plugin %s has no assets", p.ID)
+		}
+		for goos, sum := range p.Checksums {
+			if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("plugin %s: checksum for %s is not a SHA-256 hex digest", p.ID, goos)
+			}
+		}
+		if p.MinisignKey != "" {
+			if _, err := minisign.ParsePublicKey(p.MinisignKey); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.ID, err)
+			}
+		}
+		paths := append([]string{p.InstallRoot, p.detectPath()}, p.ConfigPaths...)
+		if p.VersionFile != "" {
+			paths = append(paths, p.VersionFile)
+		}
+		for pattern := range p.ConfigSchemas {
+			paths = append(paths, pattern)
+		}
+		for _, rel := range paths {
+			if rel == "" || !filepath.IsLocal(filepath.FromSlash(rel)) {
+				return fmt.Errorf("plugin %s: path %q must be inside the install", p.ID, rel)
+			}
+		}
+		seen[id] = true
+	}
+	for _, p := range c.Plugins {
+		if _, err := c.InstallOrder(p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the catalog entries in catalog order.
+func (c *Catalog) List() []CatalogPlugin {
+	return append([]CatalogPlugin(nil), c.Plugins...)
+}
+
+// Lookup finds a plugin by ID, name or alias, ignoring case.
+func (c *Catalog) Lookup(name string) (*CatalogPlugin, bool) {
+	name = strings.TrimSpace(name)
+	for i := range c.Plugins {
+		p := &c.Plugins[i]
+		if strings.EqualFold(p.ID, name) || strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+		for _, a := range p.Aliases {
+			if strings.EqualFold(a, name) {
+				return p, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// InstallOrder returns id's dependencies, dependencies first, followed by the plugin itself.
+func (c *Catalog) InstallOrder(id string) ([]*CatalogPlugin, error) {
+	var order []*CatalogPlugin
+	state := make(map[string]int) // 1 visiting, 2 done
+	var visit func(id string, chain []string) error
+	visit = func(id string, chain []string) error {
+		i := c.index(id)
+		if i < 0 {
+			if len(chain) == 0 {
+				return fmt.Errorf("unknown plugin: %s", id)
+			}
+			return fmt.Errorf("plugin %s depends on unknown plugin %s", chain[len(chain)-1], id)
+		}
+		p := &c.Plugins[i]
+		key := strings.ToLower(p.ID)
+		switch state[key] {
+		case 1:
+			return fmt.Errorf("plugin dependency cycle: %s", strings.Join(append(chain, p.ID), " -> "))
+		case 2:
+			return nil
+		}
+		state[key] = 1
+		for _, dep := range p.Depends {
+			if err := visit(dep, append(chain, p.ID)); err != nil {
+				return err
+			}
+		}
+		state[key] = 2
+		order = append(order, p)
+		return nil
+	}
+	if err := visit(id, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (p *CatalogPlugin) detectPath() string {
+	if p.Detect != "" {
+		return p.Detect
+	}
+	return path.Join(p.InstallRoot, p.ExpectDir)
+}
+
+// disabledPath is where a CounterStrikeSharp plugin lives while disabled, or "" for other
+// plugins.
+func (p *CatalogPlugin) disabledPath() string {
+	dir := p.detectPath()
+	if path.Dir(strings.ToLower(dir)) != strings.ToLower(cssPluginsDir) {
+		return ""
+	}
+	return cssPluginsDir + "/disabled/" + path.Base(dir)
+}
+
+// location returns where the plugin is installed and whether it is enabled.
+func (p *CatalogPlugin) location(installPath string) (rel string, enabled, ok bool) {
+	if _, err := os.Stat(filepath.Join(installPath, filepath.FromSlash(p.detectPath()))); err == nil {
+		return p.detectPath(), true, true
+	}
+	if d := p.disabledPath(); d != "" {
+		if _, err := os.Stat(filepath.Join(installPath, filepath.FromSlash(d))); err == nil {
+			return d, false, true
+		}
+	}
+	return "", false, false
+}
+
+// Installed reports whether the plugin is present in the install, enabled or not.
+func (p *CatalogPlugin) Installed(installPath string) bool {
+	_, _, ok := p.location(installPath)
+	return ok
+}
+
+// Resolve turns the plugin's pinned or latest version into a download URL for this OS.
+func (p *CatalogPlugin) Resolve() (*ResolvedPlugin, error) {
+	asset := osValue(p.Source.Assets)
+	if asset == "" {
+		return nil, fmt.Errorf("%s has no download for %s", p.Name, runtime.GOOS)
+	}
+	version := p.Version
+	if strings.EqualFold(version, "latest") {
+		version = ""
+	}
+	var res *ResolvedPlugin
+	var err error
+	switch p.Source.Type {
+	case "github":
+		res, err = p.resolveGitHub(asset, version)
+	default:
+		res, err = p.resolveURL(asset, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Catalog checksums are for the pinned version only.
+	if sum := osValue(p.Checksums); sum != "" && version != "" {
+		res.SHA256 = strings.ToLower(sum)
+	}
+	return res, nil
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name   string `json:"name"`
+		URL    string `json:"browser_download_url"`
+		Digest string `json:"digest"` // "sha256:<hex>" on newer releases
+	} `json:"assets"`
+}
+
+func (p *CatalogPlugin) resolveGitHub(pattern, version string) (*ResolvedPlugin, error) {
+	api := "https://api.github.com/repos/" + p.Source.Repo + "/releases/latest"
+	if version != "" {
+		api = "https://api.github.com/repos/" + p.Source.Repo + "/releases/tags/" + url.PathEscape(version)
+	}
+	data, err := httpGet(api, 4<<20)
+	if err != nil {
+		return nil, fmt.Errorf("look up %s release: %w", p.Name, err)
+	}
+	var rel githubRelease
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, fmt.Errorf("decode %s release: %w", p.Name, err)
+	}
+	pattern = strings.ReplaceAll(pattern, "{version}", rel.TagName)
+	for _, a := range rel.Assets {
+		if ok, _ := path.Match(pattern, a.Name); ok {
+			sum, _ := strings.CutPrefix(a.Digest, "sha256:")
+			if sum == a.Digest {
+				sum = ""
+			}
+			return &ResolvedPlugin{ID: p.ID, Version: rel.TagName, URL: a.URL, SHA256: sum}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s release %s has no asset matching %q", p.Name, rel.TagName, pattern)
+}
+
+func (p *CatalogPlugin) resolveURL(tmpl, version string) (*ResolvedPlugin, error) {
+	if version != "" || !strings.Contains(tmpl, "{version}") {
+		return &ResolvedPlugin{ID: p.ID, Version: version, URL: strings.ReplaceAll(tmpl, "{version}", version)}, nil
+	}
+	latest := osValue(p.Source.Latest)
+	if latest == "" {
+		return nil, fmt.Errorf("%s has no pinned version and no way to find the latest", p.Name)
+	}
+	data, err := httpGet(latest, 4096)
+	if err != nil {
+		return nil, fmt.Errorf("look up latest %s: %w", p.Name, err)
+	}
+	name := strings.TrimSpace(string(data))
+	base, err := url.Parse(latest)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(name)
+	if err != nil || name == "" || strings.ContainsAny(name, "\r\n") {
+		return nil, fmt.Errorf("unexpected latest %s marker %q", p.Name, name)
+	}
+	resolved := base.ResolveReference(ref).String()
+	return &ResolvedPlugin{ID: p.ID, Version: versionFromName(path.Base(tmpl), path.Base(ref.Path)), URL: resolved}, nil
+}
+
+// versionFromName extracts {version} from name using the file name template, or returns name
+// without its extension when it doesn't fit.
+func versionFromName(tmpl, name string) string {
+	if i := strings.Index(tmpl, "{version}"); i >= 0 {
+		prefix, suffix := tmpl[:i], tmpl[i+len("{version}"):]
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) && len(name) > len(prefix)+len(suffix) {
+			return name[len(prefix) : len(name)-len(suffix)]
+		}
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func osValue(m map[string]string) string {
+	if v, ok := m[runtime.GOOS]; ok {
+		return v
+	}
+	return m["*"]
+}
+
+// InstallPlugin installs the named plugin, first installing any dependencies that are
+// missing. The plugin itself is always (re)installed. It returns what was installed, in order.
+func (c *Catalog) InstallPlugin(installPath, name string) ([]ResolvedPlugin, error) {
+	p, ok := c.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin: %s", name)
+	}
+	order, err := c.InstallOrder(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	var installed []ResolvedPlugin
+	for _, dep := range order {
+		if dep != p && dep.Installed(installPath) {
+			continue
+		}
+		// Updating a disabled plugin re-enables it rather than installing a second copy.
+		if _, enabled, ok := dep.location(installPath); ok && !enabled {
+			if err := SetPluginEnabled(installPath, path.Base(dep.detectPath()), true); err != nil {
+				return installed, err
+			}
+		}
+		res, err := dep.Resolve()
+		if err != nil {
+			return installed, err
+		}
+		zipPath, err := downloadPlugin(res.URL, dep.Name, Verification{SHA256: res.SHA256, MinisignKey: dep.MinisignKey})
+		if err != nil {
+			return installed, err
+		}
+		basePath := filepath.Join(installPath, filepath.FromSlash(dep.InstallRoot))
+		meta := PluginManifest{ID: dep.ID, Name: dep.Name, Version: res.Version, Source: res.URL}
+		err = installArchive(installPath, meta, zipPath, basePath, dep.ExpectDir)
+		os.Remove(zipPath)
+		if err != nil {
+			return installed, fmt.Errorf("install %s: %w", dep.Name, err)
+		}
+		installed = append(installed, *res)
+	}
+	return installed, nil
+}
+
+// Uninstall removes the named plugin, refusing while an installed plugin depends on it.
+// Plugins outside the catalog are uninstalled by their manifest ID.
+func (c *Catalog) Uninstall(installPath, name string) (*UninstallResult, error) {
+	p, ok := c.Lookup(name)
+	if !ok {
+		return UninstallPlugin(installPath, name)
+	}
+	for i := range c.Plugins {
+		other := &c.Plugins[i]
+		if other == p || !other.Installed(installPath) {
+			continue
+		}
+		for _, dep := range other.Depends {
+			if strings.EqualFold(dep, p.ID) {
+				return nil, fmt.Errorf("%s is required by %s; uninstall that first", p.Name, other.Name)
+			}
+		}
+	}
+	return UninstallPlugin(installPath, p.ID)
+}
+
+// PluginDir returns the CounterStrikeSharp plugin directory name for the named plugin, for
+// SetPluginEnabled.
+func (c *Catalog) PluginDir(name string) (string, error) {
+	p, ok := c.Lookup(name)
+	if !ok {
+		return name, nil
+	}
+	if p.disabledPath() == "" {
+		return "", fmt.Errorf("%s isn't a CounterStrikeSharp plugin and can't be disabled", p.Name)
+	}
+	return path.Base(p.detectPath()), nil
+}
+
+// ManifestID returns the ID the named plugin's install manifest is kept under.
+func (c *Catalog) ManifestID(name string) string {
+	if p, ok := c.Lookup(name); ok {
+		return p.ID
+	}
+	return name
+}
+
+func httpGet(rawURL string, limit int64) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "CS2Admin/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, rawURL)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
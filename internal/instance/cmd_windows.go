@@ -10,8 +10,10 @@ import (
 // hideWindow sets SysProcAttr so the command runs without a visible console window.
 // Uses only CREATE_NO_WINDOW to prevent the console host from creating a window.
 // HideWindow is NOT used because it can conflict with CREATE_NO_WINDOW for GUI-subsystem apps.
+// NORMAL_PRIORITY_CLASS stops a server started during a backup from inheriting the app's
+// lowered priority.
 func hideWindow(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
-		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+		CreationFlags: 0x08000000 | 0x00000020, // CREATE_NO_WINDOW | NORMAL_PRIORITY_CLASS
 	}
 }
@@ -0,0 +1,146 @@
+package instance
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LoadedPlugin is a plugin as CounterStrikeSharp reports it from `css_plugins list`.
+type LoadedPlugin struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+	// State is CounterStrikeSharp's plugin state in lower case: "loaded", "unloaded",
+	// "loading" or "unregistered".
+	State  string `json:"state"`
+	Loaded bool   `json:"loaded"`
+}
+
+// A plugin line looks like
+//
+//	[#1:LOADED]: "WeaponPaints" (2.9a) by Nereziel & daffyy
+//
+// optionally followed by an indented description line.
+var cssPluginLineRe = regexp.MustCompile(`^\[#(\d+):(\w+)\]:\s+"(.*)"\s+\((.*?)\)(?:\s+by\s+(.*))?$`)
+
+// ParseCSSPluginList parses the output of `css_plugins list`.
+func ParseCSSPluginList(out string) []LoadedPlugin {
+	var result []LoadedPlugin
+	for _, line := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		m := cssPluginLineRe.FindStringSubmatch(trimmed)
+		if m == nil {
+			// The description is the indented line after its plugin.
+			if len(result) > 0 && result[len(result)-1].Description == "" &&
+				strings.HasPrefix(line, "    ") && !strings.HasPrefix(trimmed, "List of") {
+				result[len(result)-1].Description = trimmed
+			}
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		state := strings.ToLower(m[2])
+		result = append(result, LoadedPlugin{
+			ID:      id,
+			Name:    m[3],
+			Version: m[4],
+			Author:  strings.TrimSpace(m[5]),
+			State:   state,
+			Loaded:  state == "loaded",
+		})
+	}
+	return result
+}
+
+// CSSPluginCommand builds a `css_plugins` load, unload or reload command. target is a plugin
+// directory name for load, and a plugin's name or list ID for unload and reload.
+func CSSPluginCommand(action, target string) (string, error) {
+	switch action {
+	case "load", "unload", "reload":
+	default:
+		return "", fmt.Errorf("unknown plugin action %q", action)
+	}
+	target = strings.TrimSpace(target)
+	if target == "" || strings.ContainsAny(target, "\";\r\n") {
+		return "", fmt.Errorf("invalid plugin name %q", target)
+	}
+	return fmt.Sprintf("css_plugins %s \"%s\"", action, target), nil
+}
+
+// CSSCommandError returns the error CounterStrikeSharp reported in out, if any.
+func CSSCommandError(out string) error {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "unknown command"):
+			return fmt.Errorf("CounterStrikeSharp is not loaded on the server")
+		case strings.HasPrefix(lower, "could not"), strings.HasPrefix(lower, "failed"),
+			strings.Contains(lower, "exception"):
+			return fmt.Errorf("CounterStrikeSharp: %s", line)
+		}
+	}
+	return nil
+}
+
+// MergeLoadedPlugins records in plugins which CounterStrikeSharp plugins the server has
+// loaded, and appends loaded plugins with no match on disk. Plugins are matched by name,
+// ignoring case and punctuation, since a plugin's name usually but not always matches its
+// directory.
+func MergeLoadedPlugins(plugins []PluginInfo, loaded []LoadedPlugin) []PluginInfo {
+	byName := make(map[string]int, len(loaded))
+	for i, lp := range loaded {
+		byName[pluginKey(lp.Name)] = i
+	}
+	matched := make([]bool, len(loaded))
+	for i := range plugins {
+		info := &plugins[i]
+		// Metamod and CounterStrikeSharp itself aren't CounterStrikeSharp plugins.
+		if !strings.Contains(filepath.ToSlash(info.Path), cssPluginsDir+"/") {
+			continue
+		}
+		info.LoadState = "not loaded"
+		j, ok := byName[pluginKey(info.Name)]
+		if !ok {
+			j, ok = byName[pluginKey(filepath.Base(info.Path))]
+		}
+		if !ok || !info.Enabled || matched[j] {
+			continue
+		}
+		matched[j] = true
+		info.setLoaded(loaded[j])
+	}
+	for i, lp := range loaded {
+		if matched[i] {
+			continue
+		}
+		info := PluginInfo{Name: lp.Name, Installed: true, Version: lp.Version, Enabled: true}
+		info.setLoaded(lp)
+		plugins = append(plugins, info)
+	}
+	return plugins
+}
+
+func (info *PluginInfo) setLoaded(lp LoadedPlugin) {
+	info.LoadedID = lp.ID
+	info.LoadState = lp.State
+	info.LoadedVersion = lp.Version
+	info.Author = lp.Author
+}
+
+func pluginKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
@@ -0,0 +1,286 @@
+package instance
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"cs2admin/internal/pkg/logger"
+	"cs2admin/internal/pkg/minisign"
+)
+
+// Plugin archives come from third parties, so extraction trusts nothing in them: entries must
+// stay inside the install, sizes are capped, and everything is staged before any file in the
+// install is touched.
+
+const (
+	maxPluginDownload = 1 << 30 // bytes
+	maxExtractedSize  = 2 << 30 // bytes, all entries together
+	maxZipEntries     = 20000
+)
+
+// Verification is what a plugin download is checked against; empty fields are skipped.
+type Verification struct {
+	SHA256      string // hex
+	MinisignKey string // signature read from the download URL + ".minisig"
+}
+
+// downloadPlugin downloads url to a temp file, verifies it and returns its path. The caller
+// removes the file.
+func downloadPlugin(url, label string, v Verification) (string, error) {
+	logger.Log.Info().Str("plugin", label).Str("url", url).Msg("downloading plugin")
+
+	client := &http.Client{Timeout: 10 * time.Minute}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "CS2Admin/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", label, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: HTTP %d", label, resp.StatusCode)
+	}
+
+	tmpFile, err := os.CreateTemp("", "cs2admin-plugin-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	ok := false
+	defer func() {
+		tmpFile.Close()
+		if !ok {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmpFile, h), io.LimitReader(resp.Body, maxPluginDownload+1))
+	if err != nil {
+		return "", fmt.Errorf("save %s zip: %w", label, err)
+	}
+	if n > maxPluginDownload {
+		return "", fmt.Errorf("download %s: larger than %d MB", label, maxPluginDownload>>20)
+	}
+	logger.Log.Info().Str("plugin", label).Int64("bytes", n).Msg("downloaded")
+
+	if sum := hex.EncodeToString(h.Sum(nil)); v.SHA256 != "" && !strings.EqualFold(sum, v.SHA256) {
+		return "", fmt.Errorf("%s download is corrupt or was tampered with: SHA-256 %s, expected %s", label, sum, v.SHA256)
+	}
+	if v.MinisignKey != "" {
+		pk, err := minisign.ParsePublicKey(v.MinisignKey)
+		if err != nil {
+			return "", err
+		}
+		sig, err := httpGet(url+".minisig", 4096)
+		if err != nil {
+			return "", fmt.Errorf("download %s signature: %w", label, err)
+		}
+		if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		if err := pk.Verify(sig, tmpFile); err != nil {
+			return "", fmt.Errorf("%s signature: %w", label, err)
+		}
+	}
+	ok = true
+	return tmpPath, nil
+}
+
+// extractPluginZip extracts a plugin archive into basePath and returns the files it wrote for
+// the install manifest. Everything is extracted to a staging directory first and then moved
+// into place; if any move fails, the files already moved are put back, so a failed install
+// leaves the install as it was.
+func extractPluginZip(zipPath, installPath, basePath, expectDir, label string) ([]ManifestFile, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	defer zr.Close()
+	if len(zr.File) > maxZipEntries {
+		return nil, fmt.Errorf("%s archive has %d entries, more than the %d allowed", label, len(zr.File), maxZipEntries)
+	}
+
+	baseRel, err := filepath.Rel(installPath, basePath)
+	if err != nil || !filepath.IsLocal(baseRel) {
+		return nil, fmt.Errorf("install root %s is outside the install", basePath)
+	}
+	stateDir := filepath.Join(installPath, pluginStateDir)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, err
+	}
+	// Staging inside the install keeps it on the same volume, so the moves are renames.
+	staging, err := os.MkdirTemp(stateDir, "staging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	prefix := findZipRootPrefix(&zr.Reader, expectDir)
+	var files []ManifestFile
+	index := make(map[string]int)
+	var total int64
+	for _, f := range zr.File {
+		name := strings.ReplaceAll(f.Name, "\\", "/")
+		if prefix != "" {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		name = strings.TrimSuffix(name, "/")
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("%s archive entry %q points outside the install", label, f.Name)
+		}
+		mode := f.Mode()
+		if mode&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("%s archive entry %q is a symlink", label, f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rel := path.Join(filepath.ToSlash(baseRel), name)
+		mf, err := stageZipFile(f, filepath.Join(staging, "files", filepath.FromSlash(rel)), maxExtractedSize-total)
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+		total += mf.Size
+		mf.Path = rel
+		if i, dup := index[rel]; dup {
+			files[i] = mf
+		} else {
+			index[rel] = len(files)
+			files = append(files, mf)
+		}
+	}
+
+	if err := commitStaged(installPath, staging, files); err != nil {
+		return nil, fmt.Errorf("install %s: %w", label, err)
+	}
+	logger.Log.Info().Str("plugin", label).Int("files", len(files)).Str("path", basePath).Msg("plugin installed")
+	return files, nil
+}
+
+// stageZipFile writes one entry to dst, failing once more than limit bytes come out.
+func stageZipFile(f *zip.File, dst string, limit int64) (ManifestFile, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return ManifestFile{}, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer rc.Close()
+	br := bufio.NewReader(rc)
+	magic, _ := br.Peek(4)
+
+	// Archives made on Windows carry no Unix modes; Linux executables still need +x.
+	perm := os.FileMode(0644)
+	if f.Mode().Perm()&0111 != 0 || (runtime.GOOS != "windows" && bytes.Equal(magic, []byte("\x7fELF"))) {
+		perm = 0755
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	h := sha256.New()
+	// Sizes in the zip headers can lie; count what actually comes out.
+	n, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(br, limit+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	if n > limit {
+		return ManifestFile{}, fmt.Errorf("archive expands to more than %d MB", maxExtractedSize>>20)
+	}
+	return ManifestFile{SHA256: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+// commitStaged moves the staged files into the install. Files they replace are moved aside
+// first, so on failure everything done so far can be undone.
+func commitStaged(installPath, staging string, files []ManifestFile) error {
+	type moved struct {
+		target, backup string
+	}
+	var done []moved
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Remove(done[i].target)
+			if done[i].backup != "" {
+				os.Rename(done[i].backup, done[i].target)
+			}
+		}
+	}
+	for _, f := range files {
+		src := filepath.Join(staging, "files", filepath.FromSlash(f.Path))
+		m := moved{target: filepath.Join(installPath, filepath.FromSlash(f.Path))}
+		if err := os.MkdirAll(filepath.Dir(m.target), 0755); err != nil {
+			undo()
+			return err
+		}
+		if fi, err := os.Lstat(m.target); err == nil {
+			if fi.IsDir() {
+				undo()
+				return fmt.Errorf("replace %s: a directory is in the way", f.Path)
+			}
+			m.backup = filepath.Join(staging, "replaced", filepath.FromSlash(f.Path))
+			if err := os.MkdirAll(filepath.Dir(m.backup), 0755); err != nil {
+				undo()
+				return err
+			}
+			if err := os.Rename(m.target, m.backup); err != nil {
+				undo()
+				return fmt.Errorf("replace %s: %w", f.Path, err)
+			}
+		}
+		if err := os.Rename(src, m.target); err != nil {
+			if m.backup != "" {
+				os.Rename(m.backup, m.target)
+			}
+			undo()
+			return fmt.Errorf("write %s: %w", f.Path, err)
+		}
+		done = append(done, m)
+	}
+	return nil
+}
+
+// findZipRootPrefix finds a single top-level folder to strip so "addons/" or plugin dir lands in basePath.
+// If expectDir matches the zip root, we keep it (no strip).
+func findZipRootPrefix(rd *zip.Reader, expectDir string) string {
+	var root string
+	for _, f := range rd.File {
+		parts := strings.Split(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+		if len(parts) >= 1 && parts[0] != "" {
+			if root == "" {
+				root = parts[0]
+			} else if parts[0] != root {
+				return ""
+			}
+		}
+	}
+	if root == "" || root == "addons" || root == "game" || root == expectDir {
+		return ""
+	}
+	return root + "/"
+}
@@ -0,0 +1,143 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cs2admin/internal/pkg/valve"
+)
+
+// Metamod:Source only loads when gameinfo.gi lists its directory as a game search path ahead
+// of the stock ones. Every CS2 update or validate puts the stock file back.
+
+const (
+	// GameInfoFile is gameinfo.gi relative to the install path.
+	GameInfoFile       = "game/csgo/gameinfo.gi"
+	gameInfoBackupExt  = ".cs2admin.bak"
+	metamodSearchKey   = "Game"
+	metamodSearchValue = "csgo/addons/metamod"
+)
+
+func init() {
+	stockPatches = append(stockPatches, StockPatch{
+		Name:   "Metamod search path",
+		File:   GameInfoFile,
+		Plugin: "metamod",
+		Needed: metamodInstalled,
+		Apply:  PatchGameInfo,
+		Check:  GameInfoPatched,
+	})
+}
+
+func metamodInstalled(installPath string) bool {
+	fi, err := os.Stat(filepath.Join(installPath, "game", "csgo", "addons", "metamod"))
+	return err == nil && fi.IsDir()
+}
+
+// GameInfoPatched reports whether gameinfo.gi already has the Metamod search path.
+func GameInfoPatched(installPath string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(installPath, filepath.FromSlash(GameInfoFile)))
+	if err != nil {
+		return false, err
+	}
+	sp, err := gameInfoSearchPaths(data)
+	if err != nil {
+		return false, err
+	}
+	return hasMetamodPath(sp), nil
+}
+
+// PatchGameInfo adds the Metamod search path to gameinfo.gi. It is idempotent, leaves the
+// rest of the file byte for byte as it was, and keeps a copy of the unpatched file next to it.
+// It reports whether the file was changed.
+func PatchGameInfo(installPath string) (bool, error) {
+	p := filepath.Join(installPath, filepath.FromSlash(GameInfoFile))
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return false, fmt.Errorf("read gameinfo.gi: %w", err)
+	}
+	sp, err := gameInfoSearchPaths(data)
+	if err != nil {
+		return false, err
+	}
+	if hasMetamodPath(sp) {
+		return false, nil
+	}
+
+	// Metamod goes first among the Game paths, after the low-violence override if present.
+	var at *valve.KVNode
+	for i, c := range sp.Children {
+		if strings.EqualFold(c.Key, "Game_LowViolence") && i+1 < len(sp.Children) {
+			at = sp.Children[i+1]
+			break
+		}
+		if strings.EqualFold(c.Key, metamodSearchKey) {
+			at = c
+			break
+		}
+	}
+	var patched []byte
+	if at != nil {
+		patched = insertLineBefore(data, at.Start, metamodSearchKey+"\t"+metamodSearchValue)
+	} else {
+		patched = insertLineBefore(data, sp.BodyEnd, "\t"+metamodSearchKey+"\t"+metamodSearchValue)
+	}
+
+	// The backup is the file as SteamCMD shipped it, refreshed each time a new one is patched.
+	if err := os.WriteFile(p+gameInfoBackupExt, data, 0644); err != nil {
+		return false, fmt.Errorf("back up gameinfo.gi: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, patched, 0644); err != nil {
+		return false, fmt.Errorf("write gameinfo.gi: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("replace gameinfo.gi: %w", err)
+	}
+	return true, nil
+}
+
+func gameInfoSearchPaths(data []byte) (*valve.KVNode, error) {
+	root, err := valve.ParseKeyValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse gameinfo.gi: %w", err)
+	}
+	sp := root.FindPath("GameInfo", "FileSystem", "SearchPaths")
+	if sp == nil || !sp.IsBlock {
+		return nil, fmt.Errorf("gameinfo.gi has no FileSystem/SearchPaths block")
+	}
+	return sp, nil
+}
+
+func hasMetamodPath(sp *valve.KVNode) bool {
+	for _, c := range sp.Children {
+		if strings.EqualFold(c.Key, metamodSearchKey) && strings.EqualFold(strings.TrimRight(c.Value, "/"), metamodSearchValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertLineBefore inserts line above the line holding offset, copying that line's
+// indentation and the file's line ending.
+func insertLineBefore(data []byte, offset int, line string) []byte {
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	indent := data[lineStart:lineStart]
+	for i := lineStart; i < offset && (data[i] == ' ' || data[i] == '\t'); i++ {
+		indent = data[lineStart : i+1]
+	}
+	nl := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+	out := make([]byte, 0, len(data)+len(indent)+len(line)+len(nl))
+	out = append(out, data[:lineStart]...)
+	out = append(out, indent...)
+	out = append(out, line...)
+	out = append(out, nl...)
+	return append(out, data[lineStart:]...)
+}
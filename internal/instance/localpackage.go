@@ -0,0 +1,365 @@
+package instance
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Local plugin packages are zips or folders on disk, laid out one of three ways:
+//
+//   - addons-rooted: addons/... (or game/csgo/addons/...), extracted into game/csgo
+//   - plugin-folder-rooted: <Name>/<Name>.dll, a single CounterStrikeSharp plugin
+//   - bare: <Name>.dll and its files, as a plugin's build output
+//
+// Wrapper folders around any of these are ignored. The package is repacked with
+// install-relative paths, so it installs through the same staging, manifest and rollback
+// path as catalog downloads.
+
+// LocalPackage is a plugin package read from disk. Close removes its repacked copy.
+type LocalPackage struct {
+	Name   string // the CounterStrikeSharp plugin name, or the addons folder it installs
+	Layout string // "addons" or "plugin"
+	Source string // the zip or folder it was read from
+
+	zipPath string
+}
+
+type packageEntry struct {
+	name string // slash separated, relative to the package root
+	mode fs.FileMode
+	open func() (io.ReadCloser, error)
+}
+
+// OpenLocalPackage reads a plugin package from a zip file or a folder.
+func OpenLocalPackage(src string) (*LocalPackage, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		entries, err := fsEntries(os.DirFS(src))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", filepath.Base(src), err)
+		}
+		return openPackage(entries, filepath.Base(src), src)
+	}
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filepath.Base(src), err)
+	}
+	defer zr.Close()
+	entries, err := zipEntries(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(src)
+	return openPackage(entries, strings.TrimSuffix(base, filepath.Ext(base)), src)
+}
+
+// openPackage detects the layout of entries and repacks them; name is the package's file
+// or folder name, used when the layout doesn't say which DLL is the plugin.
+func openPackage(entries []packageEntry, name, source string) (*LocalPackage, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	if len(entries) > maxZipEntries {
+		return nil, fmt.Errorf("%s has %d files, more than the %d allowed", name, len(entries), maxZipEntries)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.name
+	}
+	pkg := &LocalPackage{Source: source}
+	target, err := pkg.detectLayout(names, name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := pkg.repack(entries, target); err != nil {
+		pkg.Close()
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Close removes the repacked copy of the package.
+func (p *LocalPackage) Close() error {
+	if p.zipPath == "" {
+		return nil
+	}
+	return os.Remove(p.zipPath)
+}
+
+// Install installs the package like a catalog plugin: with a manifest under the lowercased
+// plugin name, keeping the version it replaces for rollback.
+func (p *LocalPackage) Install(installPath string) (*PluginManifest, error) {
+	// Updating a disabled plugin re-enables it rather than installing a second copy.
+	if p.Layout == "plugin" {
+		if _, err := os.Stat(filepath.Join(installPath, filepath.FromSlash(cssPluginsDir), "disabled", p.Name)); err == nil {
+			if err := SetPluginEnabled(installPath, p.Name, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	meta := PluginManifest{ID: manifestID(p.Name), Name: p.Name, Source: p.Source}
+	if err := installArchive(installPath, meta, p.zipPath, installPath, "game"); err != nil {
+		return nil, fmt.Errorf("install %s: %w", p.Name, err)
+	}
+	m, err := ReadManifest(installPath, meta.ID)
+	if err != nil || m == nil {
+		return nil, fmt.Errorf("install %s: manifest missing after install", p.Name)
+	}
+	// A local build has no release tag; its assembly says what it is, or failing that, when
+	// it was installed, so the rollback version can still be told apart.
+	m.Version = cssPluginVersion(filepath.Join(installPath, filepath.FromSlash(cssPluginsDir), p.Name))
+	if m.Version == "" {
+		m.Version = "local " + m.InstalledAt.Local().Format("2006-01-02 15:04:05")
+	}
+	if err := writeManifest(installPath, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// detectLayout works out how the package is laid out and returns a function mapping each
+// entry to its install-relative path, or "" for entries that aren't installed.
+func (p *LocalPackage) detectLayout(names []string, fallback string) (func(string) string, error) {
+	// Strip wrapper folders: a lone top-level folder that isn't addons/ or game/.
+	prefix := ""
+	for range 4 {
+		tops, rootFiles := topLevel(names, prefix)
+		if len(rootFiles) > 0 || len(tops) != 1 || strings.EqualFold(tops[0], "addons") || strings.EqualFold(tops[0], "game") {
+			break
+		}
+		fallback = tops[0]
+		prefix += tops[0] + "/"
+	}
+	tops, rootFiles := topLevel(names, prefix)
+
+	for _, top := range tops {
+		var base, addons string
+		switch strings.ToLower(top) {
+		case "addons":
+			base, addons = "game/csgo/", prefix+top+"/"
+		case "game":
+			base, addons = "", prefix+top+"/csgo/addons/"
+		default:
+			continue
+		}
+		p.Layout = "addons"
+		p.Name = addonsPackageName(names, addons)
+		if p.Name == "" {
+			return nil, fmt.Errorf("no plugin found under %s/", top)
+		}
+		// README and LICENSE files next to addons/ stay out of the install.
+		return func(name string) string {
+			rel, ok := cutPrefixFold(name, prefix+top+"/")
+			if !ok {
+				return ""
+			}
+			return base + top + "/" + rel
+		}, nil
+	}
+
+	main, err := mainAssembly(rootFiles, fallback)
+	if err != nil {
+		return nil, err
+	}
+	p.Layout = "plugin"
+	p.Name = main
+	return func(name string) string {
+		rel, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			return ""
+		}
+		return cssPluginsDir + "/" + main + "/" + rel
+	}, nil
+}
+
+// addonsPackageName names an addons-rooted package after the CounterStrikeSharp plugin it
+// holds, or else its first folder under addons/.
+func addonsPackageName(names []string, addons string) string {
+	var dirs []string
+	for _, n := range names {
+		rel, ok := cutPrefixFold(n, addons)
+		if !ok {
+			continue
+		}
+		if plugin, ok := cutPrefixFold(rel, "counterstrikesharp/plugins/"); ok {
+			if dir, _, ok := strings.Cut(plugin, "/"); ok && !strings.EqualFold(dir, "disabled") {
+				return dir
+			}
+		}
+		if dir, _, ok := strings.Cut(rel, "/"); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return ""
+	}
+	sort.Strings(dirs)
+	return dirs[0]
+}
+
+// mainAssembly picks the plugin DLL among files: the one with a .deps.json, the only DLL,
+// or the one named like the package.
+func mainAssembly(files []string, fallback string) (string, error) {
+	var dlls []string
+	have := make(map[string]bool, len(files))
+	for _, f := range files {
+		have[strings.ToLower(f)] = true
+		if strings.EqualFold(path.Ext(f), ".dll") {
+			dlls = append(dlls, strings.TrimSuffix(f, path.Ext(f)))
+		}
+	}
+	if len(dlls) == 0 {
+		return "", fmt.Errorf("unrecognised package layout: expected addons/, a plugin folder or plugin DLLs")
+	}
+	for _, d := range dlls {
+		if have[strings.ToLower(d)+".deps.json"] {
+			return d, nil
+		}
+	}
+	if len(dlls) == 1 {
+		return dlls[0], nil
+	}
+	for _, d := range dlls {
+		if strings.EqualFold(d, fallback) {
+			return d, nil
+		}
+	}
+	sort.Strings(dlls)
+	return "", fmt.Errorf("can't tell which of %s is the plugin; add its .deps.json", strings.Join(dlls, ", "))
+}
+
+// topLevel lists the folders and files directly under prefix.
+func topLevel(names []string, prefix string) (dirs, files []string) {
+	seen := make(map[string]bool)
+	for _, n := range names {
+		rel, ok := strings.CutPrefix(n, prefix)
+		if !ok {
+			continue
+		}
+		dir, _, nested := strings.Cut(rel, "/")
+		if !nested {
+			files = append(files, rel)
+		} else if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, files
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// repack writes the entries to a temp zip under their install-relative paths.
+func (p *LocalPackage) repack(entries []packageEntry, target func(string) string) error {
+	tmp, err := os.CreateTemp("", "cs2admin-plugin-*.zip")
+	if err != nil {
+		return err
+	}
+	p.zipPath = tmp.Name()
+	zw := zip.NewWriter(tmp)
+	var total int64
+	for _, e := range entries {
+		rel := target(e.name)
+		if rel == "" {
+			continue
+		}
+		h := &zip.FileHeader{Name: rel, Method: zip.Deflate}
+		h.SetMode(e.mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			zw.Close()
+			tmp.Close()
+			return err
+		}
+		n, err := copyEntry(w, e, maxExtractedSize-total)
+		if err != nil {
+			zw.Close()
+			tmp.Close()
+			return fmt.Errorf("read %s: %w", e.name, err)
+		}
+		total += n
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+func copyEntry(w io.Writer, e packageEntry, limit int64) (int64, error) {
+	rc, err := e.open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	n, err := io.Copy(w, io.LimitReader(rc, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("package is larger than %d MB", maxExtractedSize>>20)
+	}
+	return n, err
+}
+
+// zipEntries lists the files in a zip, rejecting entries that would leave the install.
+func zipEntries(zr *zip.Reader) ([]packageEntry, error) {
+	var entries []packageEntry
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+		if f.Mode()&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("archive entry %q is a symlink", f.Name)
+		}
+		if f.FileInfo().IsDir() || name == "" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("archive entry %q points outside the package", f.Name)
+		}
+		entries = append(entries, packageEntry{name: path.Clean(name), mode: f.Mode(), open: f.Open})
+	}
+	return entries, nil
+}
+
+// fsEntries lists the files in fsys, which may be a folder on disk or embedded.
+func fsEntries(fsys fs.FS) ([]packageEntry, error) {
+	var entries []packageEntry
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", p)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if len(entries) >= maxZipEntries {
+			return fmt.Errorf("more than %d files", maxZipEntries)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, packageEntry{
+			name: p,
+			mode: info.Mode(),
+			open: func() (io.ReadCloser, error) { return fsys.Open(p) },
+		})
+		return nil
+	})
+	return entries, err
+}
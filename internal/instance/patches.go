@@ -0,0 +1,68 @@
+package instance
+
+import (
+	"fmt"
+
+	"cs2admin/internal/pkg/logger"
+)
+
+// StockPatch is a required edit to a stock game file. SteamCMD reverts stock files on every
+// update and validate, so patches are re-applied afterwards.
+type StockPatch struct {
+	Name   string
+	File   string // install-relative, slash separated
+	Plugin string // catalog ID of the plugin the patch is for, if any
+	// Needed reports whether the instance needs the patch, e.g. because a plugin is installed.
+	Needed func(installPath string) bool
+	// Apply makes the edit if it isn't there yet and reports whether it changed the file.
+	Apply func(installPath string) (bool, error)
+	// Check reports whether the edit is in place, for plugin status.
+	Check func(installPath string) (bool, error)
+}
+
+// stockPatches are the patches known to this version, applied in order.
+var stockPatches []StockPatch
+
+// PatchResult is the outcome of one patch in ApplyStockPatches.
+type PatchResult struct {
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	Changed bool   `json:"changed"` // false when the patch was already in place
+	Error   string `json:"error,omitempty"`
+}
+
+// StockPatchFiles returns the files known patches edit, so reports can flag them.
+func StockPatchFiles() []string {
+	files := make([]string, 0, len(stockPatches))
+	for _, p := range stockPatches {
+		files = append(files, p.File)
+	}
+	return files
+}
+
+// ApplyStockPatches applies every patch the instance needs. A failing patch doesn't stop the
+// others; the returned error summarizes failures.
+func ApplyStockPatches(installPath string) ([]PatchResult, error) {
+	var results []PatchResult
+	failed := 0
+	for _, p := range stockPatches {
+		if p.Needed != nil && !p.Needed(installPath) {
+			continue
+		}
+		r := PatchResult{Name: p.Name, File: p.File}
+		changed, err := p.Apply(installPath)
+		if err != nil {
+			failed++
+			r.Error = err.Error()
+			logger.Log.Error().Err(err).Str("patch", p.Name).Msg("Stock patch failed")
+		} else if changed {
+			r.Changed = true
+			logger.Log.Info().Str("patch", p.Name).Str("file", p.File).Msg("Stock patch applied")
+		}
+		results = append(results, r)
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d stock patch(es) failed", failed)
+	}
+	return results, nil
+}
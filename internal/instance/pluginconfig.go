@@ -0,0 +1,477 @@
+package instance
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cs2admin/internal/pkg/valve"
+)
+
+// CounterStrikeSharp writes each plugin's config on first load, to
+// configs/plugins/<Name>/<Name>.json. Other config files come from the catalog's
+// config_paths, which may be glob patterns.
+const cssConfigsDir = "game/csgo/addons/counterstrikesharp/configs/plugins"
+
+const (
+	// maxConfigSize caps what the editor reads and writes; plugin configs are small.
+	maxConfigSize   = 4 << 20
+	configBackupExt = ".cs2admin.bak"
+)
+
+// ConfigSchema is the subset of JSON Schema the config editor understands. The catalog can
+// provide one per config file; otherwise one is inferred from the file as it is.
+type ConfigSchema struct {
+	// Type is "object", "array", "string", "number", "integer" or "boolean"; empty allows
+	// anything.
+	Type        string                   `json:"type,omitempty" yaml:"type"`
+	Description string                   `json:"description,omitempty" yaml:"description"`
+	Properties  map[string]*ConfigSchema `json:"properties,omitempty" yaml:"properties"`
+	Items       *ConfigSchema            `json:"items,omitempty" yaml:"items"`
+	Enum        []any                    `json:"enum,omitempty" yaml:"enum"`
+	Minimum     *float64                 `json:"minimum,omitempty" yaml:"minimum"`
+	Maximum     *float64                 `json:"maximum,omitempty" yaml:"maximum"`
+	Required    []string                 `json:"required,omitempty" yaml:"required"`
+	// Inferred is set on schemas read off an existing file rather than the catalog.
+	Inferred bool `json:"inferred,omitempty" yaml:"-"`
+}
+
+// PluginConfigFile is a config file belonging to a plugin.
+type PluginConfigFile struct {
+	Path     string    `json:"path"`   // install-relative, slash separated
+	Format   string    `json:"format"` // "json", "keyvalues" or "text"
+	Exists   bool      `json:"exists"` // CounterStrikeSharp configs appear once the plugin has loaded
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// PluginConfig is a config file's contents and the schema edits are checked against.
+type PluginConfig struct {
+	PluginConfigFile
+	Content string        `json:"content"`
+	Schema  *ConfigSchema `json:"schema,omitempty"`
+}
+
+// PluginConfigs lists the config files of a plugin, named by catalog ID, name or alias, or by
+// its CounterStrikeSharp plugin directory.
+func (c *Catalog) PluginConfigs(installPath, name string) ([]PluginConfigFile, error) {
+	var patterns []string
+	cssName := name
+	if p, ok := c.Lookup(name); ok {
+		patterns = append(patterns, p.ConfigPaths...)
+		cssName = ""
+		if p.disabledPath() != "" {
+			cssName = path.Base(p.detectPath())
+		}
+	} else if name == "" || strings.ContainsAny(name, `/\:`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("unknown plugin %q", name)
+	}
+	if cssName != "" {
+		patterns = append(patterns, cssConfigsDir+"/"+cssName+"/*")
+	}
+
+	var files []PluginConfigFile
+	seen := make(map[string]bool)
+	add := func(rel string, fi os.FileInfo) {
+		if seen[strings.ToLower(rel)] || strings.HasSuffix(rel, configBackupExt) {
+			return
+		}
+		seen[strings.ToLower(rel)] = true
+		f := PluginConfigFile{Path: rel, Format: configFormat(rel)}
+		if fi != nil {
+			f.Exists, f.Size, f.Modified = true, fi.Size(), fi.ModTime()
+		}
+		files = append(files, f)
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(installPath, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("config path %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			rel, err := filepath.Rel(installPath, m)
+			if err == nil {
+				add(filepath.ToSlash(rel), fi)
+			}
+		}
+		// A config the catalog names but the plugin hasn't written yet is listed too.
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			add(pattern, nil)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// ReadPluginConfig reads one of the plugin's config files with its schema.
+func (c *Catalog) ReadPluginConfig(installPath, name, rel string) (*PluginConfig, error) {
+	f, err := c.pluginConfigFile(installPath, name, rel)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &PluginConfig{PluginConfigFile: *f}
+	if f.Exists {
+		data, err := readConfigFile(installPath, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Content = string(data)
+	}
+	cfg.Schema = c.configSchema(name, f.Path, cfg.Content)
+	return cfg, nil
+}
+
+// ValidatePluginConfig checks content as a new version of one of the plugin's config files
+// and returns the problems found.
+func (c *Catalog) ValidatePluginConfig(installPath, name, rel, content string) ([]string, error) {
+	cfg, err := c.ReadPluginConfig(installPath, name, rel)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateConfig(cfg.Format, content, cfg.Schema), nil
+}
+
+// SavePluginConfig validates content and writes it to one of the plugin's config files,
+// keeping the previous version next to it with a .cs2admin.bak extension. The file is
+// replaced atomically, so the plugin never reads a half-written config.
+func (c *Catalog) SavePluginConfig(installPath, name, rel, content string) error {
+	cfg, err := c.ReadPluginConfig(installPath, name, rel)
+	if err != nil {
+		return err
+	}
+	if len(content) > maxConfigSize {
+		return fmt.Errorf("%s is larger than %d MB", rel, maxConfigSize>>20)
+	}
+	if problems := ValidateConfig(cfg.Format, content, cfg.Schema); len(problems) > 0 {
+		return fmt.Errorf("%s is not valid: %s", rel, strings.Join(problems, "; "))
+	}
+
+	p := filepath.Join(installPath, filepath.FromSlash(cfg.Path))
+	perm := os.FileMode(0644)
+	if cfg.Exists {
+		if fi, err := os.Stat(p); err == nil {
+			perm = fi.Mode().Perm()
+		}
+		if err := os.WriteFile(p+configBackupExt, []byte(cfg.Content), perm); err != nil {
+			return fmt.Errorf("back up %s: %w", rel, err)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), perm); err != nil {
+		return fmt.Errorf("write %s: %w", rel, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace %s: %w", rel, err)
+	}
+	return nil
+}
+
+// pluginConfigFile finds rel among the plugin's config files, so only those can be edited.
+func (c *Catalog) pluginConfigFile(installPath, name, rel string) (*PluginConfigFile, error) {
+	files, err := c.PluginConfigs(installPath, name)
+	if err != nil {
+		return nil, err
+	}
+	rel = strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	for i := range files {
+		if strings.EqualFold(files[i].Path, rel) {
+			return &files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a config file of %s", rel, name)
+}
+
+// configSchema returns the catalog's schema for rel, or one inferred from content.
+func (c *Catalog) configSchema(name, rel, content string) *ConfigSchema {
+	if p, ok := c.Lookup(name); ok {
+		for pattern, s := range p.ConfigSchemas {
+			if ok, _ := path.Match(pattern, rel); ok || strings.EqualFold(pattern, rel) {
+				return s
+			}
+		}
+	}
+	if configFormat(rel) != "json" || strings.TrimSpace(content) == "" {
+		return nil
+	}
+	v, err := decodeConfigJSON(content)
+	if err != nil {
+		return nil
+	}
+	s := InferSchema(v)
+	s.Inferred = true
+	return s
+}
+
+func readConfigFile(installPath, rel string) ([]byte, error) {
+	f, err := os.Open(filepath.Join(installPath, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("%s is larger than %d MB", rel, maxConfigSize>>20)
+	}
+	return data, nil
+}
+
+func configFormat(rel string) string {
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".json":
+		return "json"
+	case ".vdf", ".kv":
+		return "keyvalues"
+	}
+	return "text"
+}
+
+// InferSchema derives a schema from a decoded JSON value: each value keeps its type, so an
+// edit can't turn a number into a string or drop a section, but values are otherwise free.
+// Numbers are "number" since a whole number in the file may be a float setting.
+func InferSchema(v any) *ConfigSchema {
+	switch v := v.(type) {
+	case map[string]any:
+		s := &ConfigSchema{Type: "object", Properties: make(map[string]*ConfigSchema, len(v))}
+		for k, child := range v {
+			s.Properties[k] = InferSchema(child)
+			if child != nil {
+				s.Required = append(s.Required, k)
+			}
+		}
+		sort.Strings(s.Required)
+		return s
+	case []any:
+		s := &ConfigSchema{Type: "array"}
+		if len(v) > 0 {
+			// Element types only carry over when the array is uniform.
+			first := InferSchema(v[0])
+			for _, e := range v[1:] {
+				if InferSchema(e).Type != first.Type {
+					return s
+				}
+			}
+			if first.Type != "object" {
+				s.Items = first
+			} else {
+				s.Items = &ConfigSchema{Type: "object"}
+			}
+		}
+		return s
+	case string:
+		return &ConfigSchema{Type: "string"}
+	case json.Number:
+		return &ConfigSchema{Type: "number"}
+	case bool:
+		return &ConfigSchema{Type: "boolean"}
+	}
+	return &ConfigSchema{}
+}
+
+// ValidateConfig checks content in the given format against schema, which may be nil, and
+// returns the problems found.
+func ValidateConfig(format, content string, schema *ConfigSchema) []string {
+	switch format {
+	case "json":
+		v, err := decodeConfigJSON(content)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		var problems []string
+		schema.check(v, "", &problems)
+		return problems
+	case "keyvalues":
+		if _, err := valve.ParseKeyValues([]byte(content)); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	return nil
+}
+
+func (s *ConfigSchema) check(v any, at string, problems *[]string) {
+	if s == nil {
+		return
+	}
+	where := at
+	if where == "" {
+		where = "config"
+	}
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, where+": "+fmt.Sprintf(format, args...))
+	}
+
+	if v == nil {
+		if s.Type != "" {
+			fail("must be %s, not null", article(s.Type))
+		}
+		return
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, k := range s.Required {
+			if _, ok := obj[k]; !ok {
+				fail("%q is missing", k)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Unknown keys are allowed: plugins add settings between versions.
+			s.Properties[k].check(obj[k], joinKey(at, k), problems)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, e := range arr {
+			s.Items.check(e, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			fail("must be a string")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("must be true or false")
+		}
+	case "number", "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		f, err := n.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if s.Type == "integer" && f != float64(int64(f)) {
+			fail("must be a whole number")
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be at least %g", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be at most %g", *s.Maximum)
+		}
+	}
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				return
+			}
+		}
+		fail("must be one of %v", s.Enum)
+	}
+}
+
+func joinKey(at, k string) string {
+	if at == "" {
+		return k
+	}
+	return at + "." + k
+}
+
+func article(t string) string {
+	if t == "array" || t == "object" || t == "integer" {
+		return "an " + t
+	}
+	return "a " + t
+}
+
+// decodeConfigJSON decodes a config the way CounterStrikeSharp reads it, which allows
+// comments. Syntax errors are reported by line and column.
+func decodeConfigJSON(content string) (any, error) {
+	data := stripJSONComments([]byte(content))
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err == nil {
+		if _, err2 := dec.Token(); err2 != io.EOF {
+			err = errors.New("unexpected data after the closing brace")
+		}
+	}
+	var syn *json.SyntaxError
+	switch {
+	case err == nil:
+		return v, nil
+	case errors.As(err, &syn):
+		// Offset counts the byte that broke the syntax.
+		line, col := lineCol(data, syn.Offset-1)
+		return nil, fmt.Errorf("line %d, column %d: %s", line, col, syn.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return nil, errors.New("unexpected end of file")
+	}
+	return nil, err
+}
+
+// stripJSONComments blanks out // and /* */ comments outside strings, keeping offsets and
+// line breaks so error positions still match the original.
+func stripJSONComments(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+		case out[i] == '"':
+			inString = true
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				if out[i] != '\n' && out[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+func lineCol(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, int(offset) - bytes.LastIndexByte(before, '\n')
+}
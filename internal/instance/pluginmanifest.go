@@ -0,0 +1,421 @@
+package instance
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Every plugin install records what it wrote in a manifest, so uninstall can remove exactly
+// those files and an update can keep the previous version for rollback. Both live in the
+// install under pluginStateDir, next to the game rather than inside it.
+
+const pluginStateDir = ".cs2admin"
+
+// ManifestFile is one file written by a plugin install.
+type ManifestFile struct {
+	Path   string `json:"path"` // install-relative, slash separated
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// PluginManifest records one installed plugin version.
+type PluginManifest struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Version     string         `json:"version"`
+	Source      string         `json:"source"` // download URL or local file it came from
+	InstalledAt time.Time      `json:"installed_at"`
+	Files       []ManifestFile `json:"files"`
+}
+
+// UninstallResult says what an uninstall removed and what it left because it was modified.
+type UninstallResult struct {
+	Removed int      `json:"removed"`
+	Kept    []string `json:"kept"` // install-relative paths of modified files left in place
+}
+
+func manifestID(name string) string { return strings.ToLower(name) }
+
+func manifestPath(installPath, id string) string {
+	return filepath.Join(installPath, pluginStateDir, "plugins", manifestID(id)+".json")
+}
+
+func rollbackPath(installPath, id string) string {
+	return filepath.Join(installPath, pluginStateDir, "rollback", manifestID(id)+".zip")
+}
+
+// ReadManifest returns the install manifest of plugin id, or nil if it has none.
+func ReadManifest(installPath, id string) (*PluginManifest, error) {
+	data, err := os.ReadFile(manifestPath(installPath, id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m PluginManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("read %s manifest: %w", id, err)
+	}
+	return &m, nil
+}
+
+func writeManifest(installPath string, m *PluginManifest) error {
+	p := manifestPath(installPath, m.ID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// RollbackVersion returns the version kept for rolling plugin id back, or "" if none is kept.
+func RollbackVersion(installPath, id string) string {
+	zr, err := zip.OpenReader(rollbackPath(installPath, id))
+	if err != nil {
+		return ""
+	}
+	defer zr.Close()
+	m, err := rollbackManifest(&zr.Reader)
+	if err != nil {
+		return ""
+	}
+	return m.Version
+}
+
+// A rollback archive holds the manifest as manifest.json and each file under files/.
+func rollbackManifest(zr *zip.Reader) (*PluginManifest, error) {
+	for _, f := range zr.File {
+		if f.Name != "manifest.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var m PluginManifest
+		if err := json.NewDecoder(rc).Decode(&m); err != nil {
+			return nil, fmt.Errorf("read rollback manifest: %w", err)
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("rollback archive has no manifest")
+}
+
+// archivePlugin writes m and the files it lists that still exist into a rollback archive at
+// dst. The archived manifest lists the files as archived, so a rollback can verify them.
+func archivePlugin(installPath string, m *PluginManifest, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	err = func() error {
+		archived := *m
+		archived.Files = nil
+		for _, f := range m.Files {
+			src, err := os.Open(filepath.Join(installPath, filepath.FromSlash(f.Path)))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var w io.Writer
+			h := sha256.New()
+			var n int64
+			fi, err := src.Stat()
+			if err == nil {
+				hdr := &zip.FileHeader{Name: "files/" + f.Path, Method: zip.Deflate}
+				hdr.SetMode(fi.Mode())
+				if w, err = zw.CreateHeader(hdr); err == nil {
+					n, err = io.Copy(io.MultiWriter(w, h), src)
+				}
+			}
+			src.Close()
+			if err != nil {
+				return fmt.Errorf("archive %s: %w", f.Path, err)
+			}
+			archived.Files = append(archived.Files, ManifestFile{Path: f.Path, SHA256: hex.EncodeToString(h.Sum(nil)), Size: n})
+		}
+		w, err := zw.Create("manifest.json")
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(w).Encode(&archived); err != nil {
+			return err
+		}
+		return zw.Close()
+	}()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// removeUnmodified deletes the files that still match their recorded hash, skipping paths in
+// keep, then prunes directories left empty.
+func removeUnmodified(installPath string, files []ManifestFile, keep map[string]bool) *UninstallResult {
+	res := &UninstallResult{}
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		if keep[f.Path] {
+			continue
+		}
+		p := filepath.Join(installPath, filepath.FromSlash(f.Path))
+		sum, err := fileSHA256(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || sum != f.SHA256 {
+			res.Kept = append(res.Kept, f.Path)
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			res.Kept = append(res.Kept, f.Path)
+			continue
+		}
+		res.Removed++
+		dirs[path.Dir(f.Path)] = true
+	}
+	for d := range dirs {
+		// Remove fails on non-empty directories, which stops the walk up.
+		for ; d != "." && d != "/" && d != "game/csgo" && d != "game"; d = path.Dir(d) {
+			if os.Remove(filepath.Join(installPath, filepath.FromSlash(d))) != nil {
+				break
+			}
+		}
+	}
+	return res
+}
+
+func fileSet(files []ManifestFile) map[string]bool {
+	set := make(map[string]bool, len(files))
+	for _, f := range files {
+		set[f.Path] = true
+	}
+	return set
+}
+
+// installArchive extracts a plugin archive and records meta as its manifest, with the files
+// written. An existing version is kept for rollback, and its unmodified files the new version
+// no longer ships are removed afterwards. If extraction fails, the install and the version
+// kept for rollback are left as they were.
+func installArchive(installPath string, meta PluginManifest, zipPath, basePath, expectDir string) error {
+	old, err := ReadManifest(installPath, meta.ID)
+	if err != nil {
+		return err
+	}
+	rbPath := rollbackPath(installPath, meta.ID)
+	if old != nil {
+		if err := archivePlugin(installPath, old, rbPath+".new"); err != nil {
+			return fmt.Errorf("keep %s %s for rollback: %w", meta.Name, old.Version, err)
+		}
+	}
+	files, err := extractPluginZip(zipPath, installPath, basePath, expectDir, meta.Name)
+	if err != nil {
+		os.Remove(rbPath + ".new")
+		return err
+	}
+	if old != nil {
+		if err := os.Rename(rbPath+".new", rbPath); err != nil {
+			return err
+		}
+		removeUnmodified(installPath, old.Files, fileSet(files))
+	}
+	meta.ID = manifestID(meta.ID)
+	meta.InstalledAt = time.Now().UTC()
+	meta.Files = files
+	return writeManifest(installPath, &meta)
+}
+
+// UninstallPlugin deletes the files plugin id installed, leaving any that were modified since,
+// and forgets the plugin and its rollback version.
+func UninstallPlugin(installPath, id string) (*UninstallResult, error) {
+	m, err := ReadManifest(installPath, id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%s has no install manifest; it was installed outside CS2Admin and has to be removed by hand", id)
+	}
+	res := removeUnmodified(installPath, m.Files, nil)
+	if err := os.Remove(manifestPath(installPath, id)); err != nil {
+		return res, err
+	}
+	os.Remove(rollbackPath(installPath, id))
+	return res, nil
+}
+
+// RollbackPlugin restores the version kept by the last update of plugin id. The version it
+// replaces is kept in turn, so a rollback can be undone the same way. The kept files are
+// staged and checked against the rollback manifest before any file in the install is
+// touched, and the current version's leftover files are removed only once they are in place.
+func RollbackPlugin(installPath, id string) (*PluginManifest, error) {
+	rbPath := rollbackPath(installPath, id)
+	zr, err := zip.OpenReader(rbPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no previous version of %s is kept", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	prev, err := rollbackManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	stateDir := filepath.Join(installPath, pluginStateDir)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(stateDir, "staging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	var files []ManifestFile
+	var total int64
+	for _, want := range prev.Files {
+		if !filepath.IsLocal(filepath.FromSlash(want.Path)) || strings.Contains(want.Path, ":") {
+			return nil, fmt.Errorf("rollback manifest entry %q points outside the install", want.Path)
+		}
+		f, ok := entries["files/"+want.Path]
+		if !ok {
+			continue // it was already gone when the version was archived
+		}
+		mf, err := stageZipFile(f, filepath.Join(staging, "files", filepath.FromSlash(want.Path)), maxExtractedSize-total)
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", want.Path, err)
+		}
+		if mf.SHA256 != want.SHA256 {
+			return nil, fmt.Errorf("rollback copy of %s doesn't match its manifest", want.Path)
+		}
+		total += mf.Size
+		mf.Path = want.Path
+		files = append(files, mf)
+	}
+
+	cur, err := ReadManifest(installPath, id)
+	if err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		if err := archivePlugin(installPath, cur, rbPath+".new"); err != nil {
+			return nil, fmt.Errorf("keep current version: %w", err)
+		}
+	}
+	if err := commitStaged(installPath, staging, files); err != nil {
+		os.Remove(rbPath + ".new")
+		return nil, fmt.Errorf("roll back %s: %w", prev.Name, err)
+	}
+	if cur != nil {
+		removeUnmodified(installPath, cur.Files, fileSet(files))
+	}
+	prev.Files = files
+	if err := writeManifest(installPath, prev); err != nil {
+		return nil, err
+	}
+	zr.Close()
+	if cur != nil {
+		err = os.Rename(rbPath+".new", rbPath)
+	} else {
+		err = os.Remove(rbPath)
+	}
+	return prev, err
+}
+
+// SetPluginEnabled moves a CounterStrikeSharp plugin directory between plugins/ and
+// plugins/disabled/, which CounterStrikeSharp doesn't load, and updates any manifest that
+// lists its files.
+func SetPluginEnabled(installPath, dir string, enabled bool) error {
+	if dir == "" || dir != filepath.Base(dir) || strings.ContainsAny(dir, `/\`) || dir == ".." || strings.EqualFold(dir, "disabled") {
+		return fmt.Errorf("invalid plugin directory %q", dir)
+	}
+	on := cssPluginsDir + "/" + dir
+	off := cssPluginsDir + "/disabled/" + dir
+	from, to := off, on
+	if !enabled {
+		from, to = on, off
+	}
+	src := filepath.Join(installPath, filepath.FromSlash(from))
+	dst := filepath.Join(installPath, filepath.FromSlash(to))
+	if _, err := os.Stat(dst); err == nil {
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			return nil // already there
+		}
+		return fmt.Errorf("both %s and %s exist", from, to)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("move %s: %w", dir, err)
+	}
+	return rewriteManifestPaths(installPath, from+"/", to+"/")
+}
+
+func rewriteManifestPaths(installPath, oldPrefix, newPrefix string) error {
+	paths, _ := filepath.Glob(filepath.Join(installPath, pluginStateDir, "plugins", "*.json"))
+	for _, p := range paths {
+		id := strings.TrimSuffix(filepath.Base(p), ".json")
+		m, err := ReadManifest(installPath, id)
+		if err != nil || m == nil {
+			continue
+		}
+		changed := false
+		for i := range m.Files {
+			if rest, ok := strings.CutPrefix(m.Files[i].Path, oldPrefix); ok {
+				m.Files[i].Path = newPrefix + rest
+				changed = true
+			}
+		}
+		if changed {
+			if err := writeManifest(installPath, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
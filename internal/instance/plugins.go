@@ -1,17 +1,9 @@
 package instance
 
 import (
-	"archive/zip"
-	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
-	"time"
-
-	"cs2admin/internal/pkg/logger"
 )
 
 // PluginInfo describes an installed or available plugin.
@@ -21,204 +13,83 @@ type PluginInfo struct {
 	Version   string `json:"version"`
 	Path      string `json:"path"`
 	Enabled   bool   `json:"enabled"`
+	// PatchStatus is set for plugins that need a stock file patched: "patched", "missing" or
+	// "error", with the reason in PatchError.
+	PatchStatus string `json:"patch_status,omitempty"`
+	PatchError  string `json:"patch_error,omitempty"`
+	// CatalogID is set for plugins from the catalog; the others were found on disk.
+	CatalogID string `json:"catalog_id,omitempty"`
+	// Managed is set when CS2Admin installed the plugin and can uninstall it cleanly.
+	// InstalledRelease is the release it installed, in the source's own numbering, and
+	// RollbackVersion the previous release kept by the last update.
+	Managed          bool   `json:"managed"`
+	InstalledRelease string `json:"installed_release,omitempty"`
+	RollbackVersion  string `json:"rollback_version,omitempty"`
+	// LatestVersion and UpdateAvailable are filled in by Catalog.CheckUpdates.
+	LatestVersion   string `json:"latest_version,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+	// LoadState is set for CounterStrikeSharp plugins while the server is running: the state
+	// `css_plugins list` reports ("loaded", "unloaded", ...), or "not loaded". LoadedID is the
+	// plugin's number in that list.
+	LoadState     string `json:"load_state,omitempty"`
+	LoadedID      int    `json:"loaded_id,omitempty"`
+	LoadedVersion string `json:"loaded_version,omitempty"`
+	Author        string `json:"author,omitempty"`
 }
 
-// Known plugin release URLs (placeholders - resolved at runtime via GitHub API or static URLs).
-var (
-	// MetamodSourceURL - Metamod:Source for CS2 (Windows). AlliedModders/sourcemm.net.
-	MetamodSourceURL = "https://mms.alliedmods.net/mms-drop/2.0/mmsource-2.0.0-git1384-windows.zip"
-	// CounterStrikeSharpURL - CounterStrikeSharp with runtime.
-	CounterStrikeSharpURL = "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v1.0.362/counterstrikesharp-with-runtime-windows-1.0.362.zip"
-	// WeaponPaintsURL - WeaponPaints plugin for CSS.
-	WeaponPaintsURL = "https://github.com/Nereziel/cs2-WeaponPaints/releases/download/build-411/WeaponPaints.zip"
-)
-
-// GetInstalledPlugins scans the addons directory and returns plugin info.
-func GetInstalledPlugins(installPath string) ([]PluginInfo, error) {
-	addonsPath := filepath.Join(installPath, "game", "csgo", "addons")
-	pluginsPath := filepath.Join(addonsPath, "plugins")
-
-	if _, err := os.Stat(addonsPath); os.IsNotExist(err) {
+// GetInstalledPlugins returns the catalog plugins present in the install, with their
+// installed version and the status of any stock-file patch they need, followed by every
+// other CounterStrikeSharp plugin found on disk.
+func GetInstalledPlugins(installPath string, cat *Catalog) ([]PluginInfo, error) {
+	if _, err := os.Stat(filepath.Join(installPath, "game", "csgo", "addons")); os.IsNotExist(err) {
 		return nil, nil
 	}
 
 	var result []PluginInfo
-
-	metamodPath := filepath.Join(addonsPath, "metamod")
-	if fi, err := os.Stat(metamodPath); err == nil && fi.IsDir() {
-		result = append(result, PluginInfo{
-			Name:      "metamod",
-			Installed: true,
-			Version:   "2.0",
-			Path:      metamodPath,
-			Enabled:   true,
-		})
-	}
-
-	cssPath := filepath.Join(addonsPath, "counterstrikesharp")
-	if fi, err := os.Stat(cssPath); err == nil && fi.IsDir() {
-		result = append(result, PluginInfo{
-			Name:      "counterstrikesharp",
-			Installed: true,
-			Version:   "1.0",
-			Path:      cssPath,
-			Enabled:   true,
-		})
-	}
-
-	wpPath := filepath.Join(pluginsPath, "WeaponPaints")
-	if fi, err := os.Stat(wpPath); err == nil && fi.IsDir() {
-		result = append(result, PluginInfo{
-			Name:      "WeaponPaints",
-			Installed: true,
-			Version:   "",
-			Path:      wpPath,
-			Enabled:   true,
-		})
-	}
-
-	return result, nil
-}
-
-// InstallMetamod downloads and extracts Metamod:Source to the CS2 addons directory.
-func InstallMetamod(installPath string) error {
-	url := MetamodSourceURL
-	if runtime.GOOS == "linux" {
-		url = "https://mms.alliedmods.net/mms-drop/2.0/mmsource-2.0.0-git1384-linux.zip"
-	}
-	return downloadAndExtractZip(installPath, url, "game/csgo", "metamod", "Metamod:Source")
-}
-
-// InstallCounterStrikeSharp downloads and extracts CounterStrikeSharp to the CS2 addons directory.
-func InstallCounterStrikeSharp(installPath string) error {
-	url := CounterStrikeSharpURL
-	if runtime.GOOS == "linux" {
-		url = "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v1.0.362/counterstrikesharp-with-runtime-linux-1.0.362.zip"
-	}
-	return downloadAndExtractZip(installPath, url, "game/csgo", "counterstrikesharp", "CounterStrikeSharp")
-}
-
-// InstallWeaponPaints downloads and extracts WeaponPaints plugin to addons/counterstrikesharp/plugins/WeaponPaints.
-func InstallWeaponPaints(installPath string) error {
-	url := WeaponPaintsURL
-	basePath := filepath.Join(installPath, "game", "csgo", "addons", "counterstrikesharp", "plugins")
-	return downloadAndExtractZipTo(installPath, url, basePath, "WeaponPaints", "WeaponPaints")
-}
-
-func downloadAndExtractZip(installPath, url, subDir, expectDir, label string) error {
-	basePath := filepath.Join(installPath, subDir)
-	return downloadAndExtractZipTo(installPath, url, basePath, expectDir, label)
-}
-
-func downloadAndExtractZipTo(installPath, url, basePath, expectDir, label string) error {
-	logger.Log.Info().Str("plugin", label).Str("url", url).Msg("downloading plugin")
-
-	client := &http.Client{Timeout: 120 * time.Second}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("User-Agent", "CS2Admin/1.0")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("download %s: %w", label, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download %s: HTTP %d", label, resp.StatusCode)
-	}
-
-	tmpFile, err := os.CreateTemp("", "cs2admin-plugin-*.zip")
-	if err != nil {
-		return fmt.Errorf("create temp file: %w", err)
-	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
-	defer tmpFile.Close()
-
-	n, err := io.Copy(tmpFile, resp.Body)
-	if err != nil {
-		return fmt.Errorf("save %s zip: %w", label, err)
-	}
-	logger.Log.Info().Str("plugin", label).Int64("bytes", n).Msg("downloaded")
-
-	if err = tmpFile.Sync(); err != nil {
-		return fmt.Errorf("sync temp file: %w", err)
-	}
-	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("seek temp file: %w", err)
-	}
-
-	rd, err := zip.NewReader(tmpFile, n)
-	if err != nil {
-		return fmt.Errorf("open zip: %w", err)
-	}
-
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return fmt.Errorf("create directory %s: %w", basePath, err)
-	}
-
-	prefix := findZipRootPrefix(rd, expectDir)
-
-	extracted := 0
-	for _, f := range rd.File {
-		name := f.Name
-		if prefix != "" && strings.HasPrefix(name, prefix) {
-			name = name[len(prefix):]
-		}
-		if name == "" || name == "." {
+	seen := make(map[string]bool)
+	for _, p := range cat.List() {
+		rel, enabled, ok := p.location(installPath)
+		if !ok {
 			continue
 		}
-		target := filepath.Join(basePath, filepath.FromSlash(name))
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return fmt.Errorf("mkdir %s: %w", target, err)
-			}
-			continue
-		}
-		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-			return fmt.Errorf("mkdir parent: %w", err)
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return fmt.Errorf("open zip entry %s: %w", f.Name, err)
-		}
-		dst, err := os.Create(target)
-		if err != nil {
-			rc.Close()
-			return fmt.Errorf("create %s: %w", target, err)
+		seen[strings.ToLower(rel)] = true
+		info := PluginInfo{
+			Name:      p.ID,
+			Installed: true,
+			Version:   p.installedVersion(installPath, rel),
+			Path:      filepath.Join(installPath, filepath.FromSlash(rel)),
+			Enabled:   enabled,
+			CatalogID: p.ID,
 		}
-		_, err = io.Copy(dst, rc)
-		rc.Close()
-		dst.Close()
-		if err != nil {
-			return fmt.Errorf("extract %s: %w", f.Name, err)
+		info.addManifest(installPath, p.ID)
+		for _, sp := range stockPatches {
+			if !strings.EqualFold(sp.Plugin, p.ID) || sp.Check == nil {
+				continue
+			}
+			switch patched, err := sp.Check(installPath); {
+			case err != nil:
+				info.PatchStatus, info.PatchError = "error", err.Error()
+			case patched:
+				info.PatchStatus = "patched"
+			default:
+				info.PatchStatus = "missing"
+			}
 		}
-		extracted++
+		result = append(result, info)
 	}
-
-	logger.Log.Info().Str("plugin", label).Int("files", extracted).Str("path", basePath).Msg("plugin installed")
-	return nil
+	return append(result, cssPlugins(installPath, seen)...), nil
 }
 
-// findZipRootPrefix finds a single top-level folder to strip so "addons/" or plugin dir lands in basePath.
-// If expectDir matches the zip root, we keep it (no strip).
-func findZipRootPrefix(rd *zip.Reader, expectDir string) string {
-	var root string
-	for _, f := range rd.File {
-		parts := strings.Split(strings.ReplaceAll(f.Name, "\\", "/"), "/")
-		if len(parts) >= 1 && parts[0] != "" {
-			if root == "" {
-				root = parts[0]
-			} else if parts[0] != root {
-				return ""
-			}
-		}
+// addManifest fills in what the install manifest of plugin id records, if it has one.
+func (info *PluginInfo) addManifest(installPath, id string) {
+	m, err := ReadManifest(installPath, id)
+	if err != nil || m == nil {
+		return
 	}
-	if root == "" || root == "addons" || root == "game" || root == expectDir {
-		return ""
+	info.Managed = true
+	info.InstalledRelease = m.Version
+	if info.Version == "" {
+		info.Version = m.Version
 	}
-	return root + "/"
+	info.RollbackVersion = RollbackVersion(installPath, id)
 }
@@ -0,0 +1,243 @@
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cs2admin/internal/pkg/logger"
+	"cs2admin/internal/pkg/peinfo"
+	"cs2admin/internal/pkg/valve"
+)
+
+// cssPluginsDir holds one directory per CounterStrikeSharp plugin; plugins moved into its
+// "disabled" subdirectory aren't loaded.
+const cssPluginsDir = "game/csgo/addons/counterstrikesharp/plugins"
+
+// Metamod's Linux binaries have no version resource, but embed the full version string,
+// e.g. "2.0.0-dev+1384".
+var metamodVersionRe = regexp.MustCompile(`\b\d+\.\d+\.\d+-(?:dev|git)\+?\d+\b`)
+
+// DetectVersion reads the installed version from rel, an install-relative file: Metamod's
+// metamod.vdf (followed to its binaries), a .NET deps.json, or any PE binary or assembly.
+// It returns "" when no version can be found.
+func DetectVersion(installPath, rel string) string {
+	p := filepath.Join(installPath, filepath.FromSlash(rel))
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".vdf":
+		return metamodVersion(installPath, p)
+	case ".json":
+		return depsJSONVersion(p, strings.TrimSuffix(filepath.Base(p), ".deps.json"))
+	}
+	return binaryVersion(p)
+}
+
+// installedVersion reads the plugin's version from its VersionFile, or from its assembly
+// when it is a CounterStrikeSharp plugin; rel is where the plugin currently is.
+func (p *CatalogPlugin) installedVersion(installPath, rel string) string {
+	if p.VersionFile != "" {
+		return DetectVersion(installPath, p.VersionFile)
+	}
+	if p.disabledPath() != "" {
+		return cssPluginVersion(filepath.Join(installPath, filepath.FromSlash(rel)))
+	}
+	return ""
+}
+
+// metamodVersion follows the loader path in metamod.vdf to the bin directory and reads the
+// version from the Metamod binaries there.
+func metamodVersion(installPath, vdfPath string) string {
+	data, err := os.ReadFile(vdfPath)
+	if err != nil {
+		return ""
+	}
+	root, err := valve.ParseKeyValues(data)
+	if err != nil {
+		return ""
+	}
+	var binDir string
+	for _, c := range root.Children {
+		if f := c.Find("file"); f != nil && f.Value != "" {
+			// Paths in metamod.vdf are relative to game/csgo.
+			binDir = filepath.Dir(filepath.Join(installPath, "game", "csgo", filepath.FromSlash(f.Value)))
+			break
+		}
+	}
+	if binDir == "" {
+		return ""
+	}
+	for _, name := range []string{"metamod.2.cs2.dll", "libmetamod.2.cs2.so", "server.dll", "libserver.so"} {
+		if v := binaryVersion(filepath.Join(binDir, name)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// binaryVersion returns the PE version resource of p, or a Metamod version string found in
+// the file.
+func binaryVersion(p string) string {
+	v, err := peinfo.Read(p)
+	if err == nil {
+		return v.Best()
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	return string(metamodVersionRe.Find(data))
+}
+
+// depsJSONVersion reads the version of project name from a .NET deps.json, whose libraries
+// are keyed "Name/Version".
+func depsJSONVersion(p, name string) string {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	var deps struct {
+		Libraries map[string]struct {
+			Type string `json:"type"`
+		} `json:"libraries"`
+	}
+	if err := json.Unmarshal(data, &deps); err != nil {
+		return ""
+	}
+	for key, lib := range deps.Libraries {
+		n, ver, ok := strings.Cut(key, "/")
+		if ok && lib.Type == "project" && strings.EqualFold(n, name) {
+			return ver
+		}
+	}
+	return ""
+}
+
+// cssPluginVersion reads a CounterStrikeSharp plugin's version from its assembly, falling
+// back to its deps.json.
+func cssPluginVersion(dir string) string {
+	name := filepath.Base(dir)
+	if v := binaryVersion(filepath.Join(dir, name+".dll")); v != "" {
+		return v
+	}
+	return depsJSONVersion(filepath.Join(dir, name+".deps.json"), name)
+}
+
+// cssPlugins lists the CounterStrikeSharp plugins in the install, enabled and disabled,
+// skipping the directories in skip (install-relative, lower case).
+func cssPlugins(installPath string, skip map[string]bool) []PluginInfo {
+	var result []PluginInfo
+	for _, sub := range []struct {
+		rel     string
+		enabled bool
+	}{{cssPluginsDir, true}, {cssPluginsDir + "/disabled", false}} {
+		entries, err := os.ReadDir(filepath.Join(installPath, filepath.FromSlash(sub.rel)))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			rel := sub.rel + "/" + e.Name()
+			if !e.IsDir() || (sub.enabled && strings.EqualFold(e.Name(), "disabled")) || skip[strings.ToLower(rel)] {
+				continue
+			}
+			dir := filepath.Join(installPath, filepath.FromSlash(rel))
+			info := PluginInfo{
+				Name:      e.Name(),
+				Installed: true,
+				Version:   cssPluginVersion(dir),
+				Path:      dir,
+				Enabled:   sub.enabled,
+			}
+			info.addManifest(installPath, e.Name())
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// CheckUpdates fills in the version InstallPlugin would install for each catalog plugin in
+// plugins (the pinned one, or the latest release) and flags those that are behind it.
+func (c *Catalog) CheckUpdates(plugins []PluginInfo) {
+	for i := range plugins {
+		info := &plugins[i]
+		if info.CatalogID == "" {
+			continue
+		}
+		p, ok := c.Lookup(info.CatalogID)
+		if !ok {
+			continue
+		}
+		target := p.Version
+		if target == "" || strings.EqualFold(target, "latest") {
+			res, err := p.Resolve()
+			if err != nil {
+				logger.Log.Warn().Err(err).Str("plugin", p.ID).Msg("Plugin update check failed")
+				continue
+			}
+			target = res.Version
+		}
+		info.LatestVersion = target
+		// The release recorded at install uses the source's numbering. A version read from a
+		// binary is only comparable when both sides are dotted version numbers: a release tag
+		// like "build-411" says nothing about an assembly's 1.0.0.
+		installed := info.InstalledRelease
+		if installed == "" && isDottedVersion(target) && isDottedVersion(info.Version) {
+			installed = info.Version
+		}
+		info.UpdateAvailable = target != "" && installed != "" && compareVersions(target, installed) > 0
+	}
+}
+
+// compareVersions compares the numeric parts of two version strings, so "v1.0.362",
+// "1.0.362.0" and "1.0.362+abc1234" are all equal and "2.0.0-git1384" equals
+// "2.0.0-dev+1384". Build metadata after "+" counts only when it is a plain number.
+func compareVersions(a, b string) int {
+	na, nb := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < max(len(na), len(nb)); i++ {
+		var x, y int
+		if i < len(na) {
+			x = na[i]
+		}
+		if i < len(nb) {
+			y = nb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+var (
+	digitsRe        = regexp.MustCompile(`\d+`)
+	dottedVersionRe = regexp.MustCompile(`^[vV]?\d+(\.\d+)+`)
+)
+
+// isDottedVersion reports whether v is numbered like "1.0.362" or "v2.0.0-dev+1384", rather
+// than a build counter or name such as "build-411".
+func isDottedVersion(v string) bool {
+	return dottedVersionRe.MatchString(v)
+}
+
+func versionNumbers(v string) []int {
+	if base, meta, ok := strings.Cut(v, "+"); ok {
+		if _, err := strconv.Atoi(meta); err != nil {
+			v = base
+		}
+	}
+	var nums []int
+	for _, d := range digitsRe.FindAllString(v, -1) {
+		n, _ := strconv.Atoi(d)
+		nums = append(nums, n)
+	}
+	return nums
+}
@@ -23,8 +23,21 @@ type ServerInstance struct {
 	GsltToken     string         `gorm:"column:gslt_token" json:"-"`    // encrypted
 	AutoRestart   bool      `gorm:"column:auto_restart;default:true" json:"auto_restart"`
 	AutoStart     bool      `gorm:"column:auto_start" json:"auto_start"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	// UpdateOnNewBuild runs the update workflow when Steam reports a newer build.
+	UpdateOnNewBuild bool       `gorm:"column:update_on_new_build" json:"update_on_new_build"`
+	BuildID          string     `gorm:"column:build_id" json:"build_id"`               // installed, from appmanifest_730.acf
+	LatestBuildID    string     `gorm:"column:latest_build_id" json:"latest_build_id"` // from steamcmd app_info_print
+	BuildCheckedAt   *time.Time `gorm:"column:build_checked_at" json:"build_checked_at"`
+	// Branch is the Steam beta branch the instance tracks; empty means public.
+	Branch       string `gorm:"column:branch" json:"branch"`
+	BetaPassword string `gorm:"column:beta_password" json:"-"` // encrypted
+	// PinnedBuildID holds the instance at a build: updates are refused while it's set.
+	PinnedBuildID string `gorm:"column:pinned_build_id" json:"pinned_build_id"`
+	// TrackMatches records matches through the bundled CS2AdminStats plugin; starting the
+	// server warns when the plugin is missing or out of date.
+	TrackMatches bool      `gorm:"column:track_matches" json:"track_matches"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // BeforeCreate generates UUID for ServerInstance
@@ -100,7 +113,23 @@ type Backup struct {
 	Path       string    `gorm:"not null" json:"path"`
 	SizeBytes  int64     `gorm:"column:size_bytes" json:"size_bytes"`
 	BackupType string    `gorm:"column:backup_type;index" json:"backup_type"`
-	CreatedAt  time.Time `json:"created_at"`
+	Format     string    `gorm:"column:format;default:zip" json:"format"` // zip, snapshot
+	// StoredBytes is the disk space this backup added; for snapshots only new chunks count.
+	StoredBytes int64     `gorm:"column:stored_bytes" json:"stored_bytes"`
+	Report      string    `gorm:"type:text" json:"report"` // JSON, e.g. what a custom-content backup skipped
+	Pinned      bool      `gorm:"column:pinned;default:false" json:"pinned"` // never pruned by retention
+	// ManifestSHA256 is the digest verification checks: of the integrity manifest, or of the
+	// whole file for encrypted backups.
+	ManifestSHA256 string     `gorm:"column:manifest_sha256" json:"manifest_sha256"`
+	VerifyStatus   string     `gorm:"column:verify_status" json:"verify_status"` // "", ok, corrupt, missing
+	VerifyError    string     `gorm:"type:text" json:"verify_error"`
+	VerifiedAt     *time.Time `json:"verified_at"`
+	// RemoteTargetID and RemoteKey locate the off-site copy, if the backup has been uploaded.
+	RemoteTargetID string     `gorm:"column:remote_target_id;index" json:"remote_target_id"`
+	RemoteKey      string     `gorm:"column:remote_key" json:"remote_key"`
+	UploadedAt     *time.Time `json:"uploaded_at"`
+	Encrypted      bool       `gorm:"column:encrypted;default:false" json:"encrypted"` // passphrase-encrypted zip
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 // BeforeCreate generates UUID for Backup
@@ -111,6 +140,48 @@ func (b *Backup) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// BackupTarget is an off-site destination backups are uploaded to (directory, S3 bucket, SFTP).
+type BackupTarget struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Name       string    `gorm:"not null" json:"name"`
+	Kind       string    `gorm:"not null" json:"kind"`       // local, s3, sftp
+	Config     string    `gorm:"type:text" json:"config"`    // JSON, non-secret settings
+	Secret     string    `gorm:"type:text" json:"-"`         // encrypted JSON credentials
+	AutoUpload bool      `gorm:"column:auto_upload;default:false" json:"auto_upload"` // upload every new backup
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BeforeCreate generates UUID for BackupTarget
+func (t *BackupTarget) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// RetentionPolicy limits how many backups of one type an instance keeps
+type RetentionPolicy struct {
+	ID            uuid.UUID `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	InstanceID    uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_retention_scope" json:"instance_id"`
+	BackupType    string    `gorm:"column:backup_type;not null;uniqueIndex:idx_retention_scope" json:"backup_type"`
+	KeepLast      int       `gorm:"column:keep_last" json:"keep_last"`
+	KeepDaily     int       `gorm:"column:keep_daily" json:"keep_daily"`
+	KeepWeekly    int       `gorm:"column:keep_weekly" json:"keep_weekly"`
+	KeepMonthly   int       `gorm:"column:keep_monthly" json:"keep_monthly"`
+	MaxTotalBytes int64     `gorm:"column:max_total_bytes" json:"max_total_bytes"` // 0 = unlimited
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// BeforeCreate generates UUID for RetentionPolicy
+func (r *RetentionPolicy) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
 // ScheduledTask represents a cron-scheduled task
 type ScheduledTask struct {
 	ID         uuid.UUID  `gorm:"primaryKey;type:varchar(36)" json:"id"`
@@ -313,6 +384,8 @@ func AutoMigrate(db *gorm.DB) error {
 		&BanEntry{},
 		&WorkshopItem{},
 		&Backup{},
+		&RetentionPolicy{},
+		&BackupTarget{},
 		&ScheduledTask{},
 		&BenchmarkResult{},
 		&MetricSnapshot{},
@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Streams are split into segments sealed separately with AES-256-GCM, so arbitrarily large
+// data can be encrypted and decrypted without holding it in memory. Each segment's nonce is a
+// random per-stream prefix, the segment counter and a final-segment flag, which rules out
+// reordering, dropping or truncating segments (the STREAM construction).
+const (
+	streamSegmentSize = 64 << 10
+	streamPrefixSize  = gcmNonceSize - 5 // counter (4 bytes) + final flag (1 byte)
+)
+
+// ErrStreamCorrupt is returned when an encrypted stream fails authentication.
+var ErrStreamCorrupt = errors.New("encrypted stream is corrupt or the key is wrong")
+
+// PassphraseKey derives a 32-byte key from a passphrase with PBKDF2-SHA256.
+func PassphraseKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, aesKeySize)
+}
+
+func newStreamAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != aesKeySize {
+		return nil, fmt.Errorf("key must be %d bytes", aesKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func streamNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, gcmNonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+	if final {
+		nonce[gcmNonceSize-1] = 1
+	}
+	return nonce
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewStreamWriter returns a writer that encrypts everything written to it into w. Close must be
+// called to write the final segment; without it the stream won't decrypt.
+func NewStreamWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := GenerateRandomBytes(streamPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &streamWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, streamSegmentSize)}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	n := 0
+	for len(p) > 0 {
+		// Only seal a full segment once more data arrives: the last one must carry the final flag.
+		if len(s.buf) == streamSegmentSize {
+			if err := s.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(s.buf[len(s.buf):streamSegmentSize], p)
+		s.buf = s.buf[:len(s.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (s *streamWriter) seal(final bool) error {
+	if s.counter == ^uint32(0) {
+		return errors.New("stream too long")
+	}
+	out := s.aead.Seal(nil, streamNonce(s.prefix, s.counter, final), s.buf, nil)
+	s.counter++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(out)
+	return err
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(true)
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	seg     []byte
+	plain   []byte
+	done    bool
+}
+
+// NewStreamReader returns a reader that decrypts a stream written by NewStreamWriter. Reads
+// fail with ErrStreamCorrupt as soon as a segment doesn't authenticate.
+func NewStreamReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("read stream header: %w", err)
+	}
+	return &streamReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		prefix: prefix,
+		seg:    make([]byte, streamSegmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.seg)
+	final := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		final = true
+	case err == io.EOF:
+		// The final segment is never missing, even for empty input.
+		return ErrStreamCorrupt
+	case err != nil:
+		return err
+	default:
+		if _, perr := s.r.Peek(1); perr == io.EOF {
+			final = true
+		}
+	}
+
+	plain, err := s.aead.Open(s.seg[:0], streamNonce(s.prefix, s.counter, final), s.seg[:n], nil)
+	if err != nil {
+		return ErrStreamCorrupt
+	}
+	s.counter++
+	s.plain = plain
+	s.done = final
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package minisign verifies minisign signatures (https://jedisct1.github.io/minisign/), the
+// detached Ed25519 signature format some plugin authors publish next to their releases.
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	algLegacy    = "Ed" // signs the message itself
+	algPrehashed = "ED" // signs the BLAKE2b-512 hash of the message
+)
+
+// PublicKey is a parsed minisign public key.
+type PublicKey struct {
+	KeyID [8]byte
+	Key   ed25519.PublicKey
+}
+
+// ParsePublicKey parses the base64 key line of a minisign public key, with or without the
+// "untrusted comment" line before it.
+func ParsePublicKey(s string) (*PublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != algLegacy {
+		return nil, errors.New("not a minisign public key")
+	}
+	pk := &PublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(pk.KeyID[:], raw[2:10])
+	return pk, nil
+}
+
+// Verify checks sig, the contents of a .minisig file, against message.
+func (pk *PublicKey) Verify(sig []byte, message io.Reader) error {
+	lines := strings.Split(strings.ReplaceAll(string(sig), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+	alg, keyID, signature := string(raw[:2]), raw[2:10], raw[10:]
+	if !bytes.Equal(keyID, pk.KeyID[:]) {
+		return fmt.Errorf("signed with key %X, expected %X", keyID, pk.KeyID)
+	}
+
+	var signed []byte
+	switch alg {
+	case algPrehashed:
+		h, _ := blake2b.New512(nil)
+		if _, err := io.Copy(h, message); err != nil {
+			return err
+		}
+		signed = h.Sum(nil)
+	case algLegacy:
+		if signed, err = io.ReadAll(message); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported minisign algorithm %q", alg)
+	}
+	if !ed25519.Verify(pk.Key, signed, signature) {
+		return errors.New("signature does not match")
+	}
+
+	// The global signature covers the signature and the trusted comment.
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("malformed minisign global signature")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pk.Key, append(append([]byte(nil), signature...), trusted...), global) {
+		return errors.New("trusted comment signature does not match")
+	}
+	return nil
+}
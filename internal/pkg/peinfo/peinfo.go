@@ -0,0 +1,238 @@
+// Package peinfo reads version resources from Windows PE files. .NET assemblies are PE files
+// on every OS, so this also covers CounterStrikeSharp and its plugins on Linux.
+package peinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	resourceDirectory = 2  // IMAGE_DIRECTORY_ENTRY_RESOURCE
+	rtVersion         = 16 // RT_VERSION
+	fixedSignature    = 0xFEEF04BD
+)
+
+// ErrNoVersion is returned for PE files without a version resource.
+var ErrNoVersion = errors.New("no version resource")
+
+// Version holds the fields of a VS_VERSIONINFO resource.
+type Version struct {
+	FileVersion    string // from the fixed part, e.g. "1.0.362.0"
+	ProductVersion string // from the fixed part
+	// Strings are the StringFileInfo values of the first string table, e.g. "ProductVersion",
+	// which for .NET assemblies carries the informational version.
+	Strings map[string]string
+}
+
+// Best returns the most specific version string: the ProductVersion string, the
+// FileVersion string, then the fixed file version.
+func (v *Version) Best() string {
+	for _, k := range []string{"ProductVersion", "FileVersion"} {
+		if s := v.Strings[k]; s != "" {
+			return s
+		}
+	}
+	return v.FileVersion
+}
+
+// Read returns the version resource of the PE file at path.
+func Read(path string) (*Version, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// ReadFrom returns the version resource of the PE file in r.
+func ReadFrom(r io.ReaderAt) (*Version, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return read(f)
+}
+
+func read(f *pe.File) (*Version, error) {
+
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > resourceDirectory {
+			dir = oh.DataDirectory[resourceDirectory]
+		}
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > resourceDirectory {
+			dir = oh.DataDirectory[resourceDirectory]
+		}
+	}
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, ErrNoVersion
+	}
+	var sec *pe.Section
+	for _, s := range f.Sections {
+		if dir.VirtualAddress >= s.VirtualAddress && dir.VirtualAddress < s.VirtualAddress+max(s.VirtualSize, s.Size) {
+			sec = s
+			break
+		}
+	}
+	if sec == nil {
+		return nil, fmt.Errorf("resource directory outside any section")
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+	rsrc := dir.VirtualAddress - sec.VirtualAddress
+	if int(rsrc) >= len(data) {
+		return nil, ErrNoVersion
+	}
+
+	// Type → name → language; take the first entry at the lower two levels.
+	off, ok := findEntry(data, rsrc, rsrc, rtVersion)
+	for level := 0; ok && level < 2; level++ {
+		off, ok = findEntry(data, rsrc, off, -1)
+	}
+	if !ok || int(off)+16 > len(data) {
+		return nil, ErrNoVersion
+	}
+	rva := binary.LittleEndian.Uint32(data[off:])
+	size := binary.LittleEndian.Uint32(data[off+4:])
+	start := int64(rva) - int64(sec.VirtualAddress)
+	if start < 0 || start+int64(size) > int64(len(data)) {
+		return nil, fmt.Errorf("version resource outside resource section")
+	}
+	return parseVersionInfo(data[start : start+int64(size)])
+}
+
+// findEntry looks in the resource directory at dirOff for an entry with the given ID (any
+// entry if id < 0) and returns the offset it points to, relative to data. Subdirectory offsets
+// are relative to the resource root at base.
+func findEntry(data []byte, base, dirOff uint32, id int) (uint32, bool) {
+	if int(dirOff)+16 > len(data) {
+		return 0, false
+	}
+	named := binary.LittleEndian.Uint16(data[dirOff+12:])
+	ids := binary.LittleEndian.Uint16(data[dirOff+14:])
+	for i := uint32(0); i < uint32(named)+uint32(ids); i++ {
+		e := dirOff + 16 + i*8
+		if int(e)+8 > len(data) {
+			return 0, false
+		}
+		name := binary.LittleEndian.Uint32(data[e:])
+		target := binary.LittleEndian.Uint32(data[e+4:])
+		if id >= 0 && (name&0x80000000 != 0 || name != uint32(id)) {
+			continue
+		}
+		return base + target&0x7FFFFFFF, true
+	}
+	return 0, false
+}
+
+// versionBlock is one node of the VS_VERSIONINFO tree.
+type versionBlock struct {
+	key      string
+	value    []byte
+	text     bool
+	children []byte
+}
+
+func readBlock(b []byte) (blk versionBlock, rest []byte, err error) {
+	if len(b) < 6 {
+		return blk, nil, fmt.Errorf("truncated version block")
+	}
+	length := int(binary.LittleEndian.Uint16(b))
+	valueLen := int(binary.LittleEndian.Uint16(b[2:]))
+	blk.text = binary.LittleEndian.Uint16(b[4:]) == 1
+	if length < 6 || length > len(b) {
+		return blk, nil, fmt.Errorf("bad version block length %d", length)
+	}
+	body := b[:length]
+	rest = b[min(align4(length), len(b)):]
+
+	i := 6
+	var key []uint16
+	for ; i+1 < len(body); i += 2 {
+		c := binary.LittleEndian.Uint16(body[i:])
+		if c == 0 {
+			i += 2
+			break
+		}
+		key = append(key, c)
+	}
+	blk.key = string(utf16.Decode(key))
+	i = align4(i)
+	if blk.text {
+		valueLen *= 2 // in WORDs for text values
+	}
+	if i+valueLen > len(body) {
+		valueLen = max(0, len(body)-i)
+	}
+	blk.value = body[min(i, len(body)) : min(i, len(body))+valueLen]
+	if c := align4(i + valueLen); c < len(body) {
+		blk.children = body[c:]
+	}
+	return blk, rest, nil
+}
+
+func parseVersionInfo(b []byte) (*Version, error) {
+	root, _, err := readBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return nil, ErrNoVersion
+	}
+	v := &Version{Strings: make(map[string]string)}
+	if len(root.value) >= 52 && binary.LittleEndian.Uint32(root.value) == fixedSignature {
+		v.FileVersion = fixedVersion(root.value[8:])
+		v.ProductVersion = fixedVersion(root.value[16:])
+	}
+	for rest := root.children; len(rest) > 0; {
+		var blk versionBlock
+		if blk, rest, err = readBlock(rest); err != nil {
+			break
+		}
+		if blk.key != "StringFileInfo" {
+			continue
+		}
+		table, _, err := readBlock(blk.children)
+		if err != nil {
+			break
+		}
+		for s := table.children; len(s) > 0; {
+			var str versionBlock
+			if str, s, err = readBlock(s); err != nil {
+				break
+			}
+			v.Strings[str.key] = utf16String(str.value)
+		}
+	}
+	return v, nil
+}
+
+func fixedVersion(b []byte) string {
+	ms := binary.LittleEndian.Uint32(b)
+	ls := binary.LittleEndian.Uint32(b[4:])
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+func align4(n int) int { return (n + 3) &^ 3 }
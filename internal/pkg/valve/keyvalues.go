@@ -0,0 +1,225 @@
+package valve
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KVNode is a KeyValues node that remembers where it came from in the source text, so edits
+// can be spliced into the original bytes without disturbing comments, order or formatting.
+// Unlike ParseVDF, ParseKeyValues accepts unquoted tokens and platform conditionals as used
+// by gameinfo.gi and most other hand-written Source 2 files.
+type KVNode struct {
+	Key      string
+	Value    string // empty for blocks
+	Cond     string // platform conditional such as "[$WIN64]", if any
+	Children []*KVNode
+	IsBlock  bool
+
+	Start   int // offset of the key
+	End     int // offset just past the value, conditional or closing brace
+	BodyEnd int // blocks only: offset of the closing brace
+}
+
+// ParseKeyValues parses KeyValues text. The returned root is synthetic: its children are the
+// top-level entries and its BodyEnd is the end of the data.
+func ParseKeyValues(data []byte) (*KVNode, error) {
+	lx := &kvLexer{data: data}
+	root := &KVNode{IsBlock: true}
+	children, end, err := lx.parseBlock(false)
+	if err != nil {
+		return nil, err
+	}
+	root.Children, root.End, root.BodyEnd = children, end, end
+	return root, nil
+}
+
+// Find returns the first child with the given key (case-insensitive).
+func (n *KVNode) Find(key string) *KVNode {
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// FindPath follows keys from n and returns the node at the end, or nil.
+func (n *KVNode) FindPath(keys ...string) *KVNode {
+	cur := n
+	for _, k := range keys {
+		if cur = cur.Find(k); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+type kvTokenKind int
+
+const (
+	kvEOF kvTokenKind = iota
+	kvString
+	kvOpen
+	kvClose
+	kvCond
+)
+
+type kvToken struct {
+	kind       kvTokenKind
+	text       string
+	start, end int
+}
+
+type kvLexer struct {
+	data []byte
+	pos  int
+}
+
+func (lx *kvLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			lx.pos++
+		case c == '/' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '/':
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' {
+				lx.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (lx *kvLexer) next() (kvToken, error) {
+	lx.skipSpace()
+	start := lx.pos
+	if lx.pos >= len(lx.data) {
+		return kvToken{kind: kvEOF, start: start, end: start}, nil
+	}
+	switch c := lx.data[lx.pos]; c {
+	case '{':
+		lx.pos++
+		return kvToken{kind: kvOpen, start: start, end: lx.pos}, nil
+	case '}':
+		lx.pos++
+		return kvToken{kind: kvClose, start: start, end: lx.pos}, nil
+	case '[':
+		for lx.pos < len(lx.data) && lx.data[lx.pos] != ']' && lx.data[lx.pos] != '\n' {
+			lx.pos++
+		}
+		if lx.pos >= len(lx.data) || lx.data[lx.pos] != ']' {
+			return kvToken{}, fmt.Errorf("unterminated conditional at offset %d", start)
+		}
+		lx.pos++
+		return kvToken{kind: kvCond, text: string(lx.data[start:lx.pos]), start: start, end: lx.pos}, nil
+	case '"':
+		lx.pos++
+		var sb strings.Builder
+		for lx.pos < len(lx.data) {
+			c := lx.data[lx.pos]
+			if c == '\\' && lx.pos+1 < len(lx.data) {
+				switch n := lx.data[lx.pos+1]; n {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				default:
+					sb.WriteByte(n)
+				}
+				lx.pos += 2
+				continue
+			}
+			lx.pos++
+			if c == '"' {
+				return kvToken{kind: kvString, text: sb.String(), start: start, end: lx.pos}, nil
+			}
+			sb.WriteByte(c)
+		}
+		return kvToken{}, fmt.Errorf("unterminated string at offset %d", start)
+	default:
+		for lx.pos < len(lx.data) {
+			c := lx.data[lx.pos]
+			if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '{' || c == '}' || c == '"' {
+				break
+			}
+			if c == '/' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '/' {
+				break
+			}
+			lx.pos++
+		}
+		return kvToken{kind: kvString, text: string(lx.data[start:lx.pos]), start: start, end: lx.pos}, nil
+	}
+}
+
+func (lx *kvLexer) peek() (kvToken, error) {
+	pos := lx.pos
+	t, err := lx.next()
+	lx.pos = pos
+	return t, err
+}
+
+// parseBlock reads entries up to the closing brace (nested) or the end of data (top level)
+// and returns them with the offset of the brace or end.
+func (lx *kvLexer) parseBlock(nested bool) ([]*KVNode, int, error) {
+	var children []*KVNode
+	for {
+		t, err := lx.next()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch t.kind {
+		case kvEOF:
+			if nested {
+				return nil, 0, fmt.Errorf("missing closing brace at end of data")
+			}
+			return children, t.start, nil
+		case kvClose:
+			if !nested {
+				return nil, 0, fmt.Errorf("unexpected '}' at offset %d", t.start)
+			}
+			return children, t.start, nil
+		case kvString:
+			node, err := lx.parseEntry(t)
+			if err != nil {
+				return nil, 0, err
+			}
+			children = append(children, node)
+		default:
+			return nil, 0, fmt.Errorf("expected key at offset %d", t.start)
+		}
+	}
+}
+
+func (lx *kvLexer) parseEntry(key kvToken) (*KVNode, error) {
+	node := &KVNode{Key: key.text, Start: key.start}
+	t, err := lx.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == kvCond { // "Key" [$COND] { ... }
+		node.Cond = t.text
+		if t, err = lx.next(); err != nil {
+			return nil, err
+		}
+	}
+	switch t.kind {
+	case kvOpen:
+		children, brace, err := lx.parseBlock(true)
+		if err != nil {
+			return nil, err
+		}
+		node.IsBlock, node.Children, node.BodyEnd, node.End = true, children, brace, brace+1
+	case kvString:
+		node.Value, node.End = t.text, t.end
+	default:
+		return nil, fmt.Errorf("expected value or '{' after %q at offset %d", key.text, t.start)
+	}
+	// "Key" "Value" [$COND]
+	if p, err := lx.peek(); err == nil && p.kind == kvCond && !node.IsBlock {
+		lx.next()
+		node.Cond, node.End = p.text, p.end
+	}
+	return node, nil
+}
@@ -30,10 +30,22 @@ type ScheduledEntry struct {
 	NextRun time.Time
 }
 
+// job is a recurring in-process maintenance job. Jobs are registered by the app at startup;
+// only their last run time is stored, as an AppSetting, so an app that restarts more often
+// than a job's interval still runs it.
+type job struct {
+	name     string
+	interval time.Duration
+	nextRun  time.Time
+	running  bool
+	fn       func()
+}
+
 // Scheduler manages cron-like scheduled tasks.
 type Scheduler struct {
 	db       *gorm.DB
 	tasks    map[string]*ScheduledEntry
+	jobs     map[string]*job
 	mu       sync.RWMutex
 	stopCh   chan struct{}
 	onAction func(instanceID string, action TaskAction, payload string)
@@ -44,6 +56,54 @@ func New(db *gorm.DB) *Scheduler {
 	return &Scheduler{
 		db:    db,
 		tasks: make(map[string]*ScheduledEntry),
+		jobs:  make(map[string]*job),
+	}
+}
+
+// Every registers fn to run every interval, counted from its last recorded run. A job that
+// is overdue, or has never run, runs on the scheduler's first check. Registering the same
+// name again replaces the job. A run that is still going when the job is due again is skipped.
+func (s *Scheduler) Every(name string, interval time.Duration, fn func()) {
+	nextRun := time.Now()
+	if last, ok := s.jobLastRun(name); ok && last.Before(nextRun) {
+		nextRun = last.Add(interval)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &job{
+		name:     name,
+		interval: interval,
+		nextRun:  nextRun,
+		fn:       fn,
+	}
+}
+
+func jobSettingKey(name string) string { return "scheduler_job_last_run:" + name }
+
+// jobLastRun returns when the job last started, as recorded by setJobLastRun.
+func (s *Scheduler) jobLastRun(name string) (time.Time, bool) {
+	var setting models.AppSetting
+	if err := s.db.Where("key = ?", jobSettingKey(name)).First(&setting).Error; err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, setting.Value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (s *Scheduler) setJobLastRun(name string, t time.Time) {
+	value := t.UTC().Format(time.RFC3339)
+	var setting models.AppSetting
+	var err error
+	if s.db.Where("key = ?", jobSettingKey(name)).First(&setting).Error != nil {
+		err = s.db.Create(&models.AppSetting{Key: jobSettingKey(name), Value: value}).Error
+	} else {
+		err = s.db.Model(&setting).Update("value", value).Error
+	}
+	if err != nil {
+		logger.Log.Warn().Err(err).Str("job", name).Msg("scheduler: record job run failed")
 	}
 }
 
@@ -153,6 +213,29 @@ func (s *Scheduler) checkAndRun() {
 		}
 		s.mu.Lock()
 	}
+
+	for _, j := range s.jobs {
+		if j.running || j.nextRun.After(now) {
+			continue
+		}
+		j.running = true
+		j.nextRun = now.Add(j.interval)
+		s.setJobLastRun(j.name, now)
+		go s.runJob(j)
+	}
+}
+
+func (s *Scheduler) runJob(j *job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log.Error().Interface("panic", r).Str("job", j.name).Msg("scheduler: job panicked")
+		}
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+	}()
+	logger.Log.Debug().Str("job", j.name).Msg("scheduler: running job")
+	j.fn()
 }
 
 // AddTask adds a scheduled task.
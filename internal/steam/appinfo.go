@@ -0,0 +1,83 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cs2admin/internal/pkg/valve"
+)
+
+// BranchPublic is the default Steam branch.
+const BranchPublic = "public"
+
+// BuildInfo is the current build of an app branch as Steam reports it.
+type BuildInfo struct {
+	AppID       string `json:"app_id"`
+	Branch      string `json:"branch"`
+	BuildID     string `json:"build_id"`
+	TimeUpdated int64  `json:"time_updated"`
+}
+
+// LatestBuild asks Steam for the current build of CS2 on branch. app_info_update forces a
+// refresh; without it SteamCMD answers from its local cache, which can be days old.
+func (s *SteamCMD) LatestBuild(ctx context.Context, branch string) (*BuildInfo, error) {
+	if branch == "" {
+		branch = BranchPublic
+	}
+	var lines []string
+	args := []string{
+		"+login", "anonymous",
+		"+app_info_update", "1",
+		"+app_info_print", CS2AppID,
+		"+quit",
+	}
+	if err := s.Run(ctx, args, nil, func(line string) { lines = append(lines, line) }); err != nil {
+		return nil, err
+	}
+	return ParseAppInfo(lines, CS2AppID, branch)
+}
+
+// ParseAppInfo extracts the build of branch from `app_info_print` output. The app's VDF block
+// starts at the line holding just its quoted app ID; SteamCMD's chatter before it is skipped
+// and anything after the block is ignored by the parser.
+func ParseAppInfo(lines []string, appID, branch string) (*BuildInfo, error) {
+	start := -1
+	for i, l := range lines {
+		if strings.TrimSpace(l) == `"`+appID+`"` {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("app info for %s not found in steamcmd output", appID)
+	}
+	root, err := valve.ParseVDF([]byte(strings.Join(lines[start:], "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("parse app info: %w", err)
+	}
+
+	depots := root.FindChild("depots")
+	if depots == nil {
+		return nil, errors.New("app info has no depots section")
+	}
+	branches := depots.FindChild("branches")
+	if branches == nil {
+		return nil, errors.New("app info has no branches section")
+	}
+	b := branches.FindChild(branch)
+	if b == nil {
+		return nil, fmt.Errorf("branch %q not found", branch)
+	}
+	info := &BuildInfo{
+		AppID:       appID,
+		Branch:      branch,
+		BuildID:     b.GetString("buildid"),
+		TimeUpdated: parseInt64(b.GetString("timeupdated")),
+	}
+	if info.BuildID == "" {
+		return nil, fmt.Errorf("branch %q has no buildid", branch)
+	}
+	return info, nil
+}
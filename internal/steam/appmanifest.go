@@ -0,0 +1,71 @@
+package steam
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"cs2admin/internal/pkg/valve"
+)
+
+// CS2AppID is the Steam app ID of the CS2 dedicated server.
+const CS2AppID = "730"
+
+// AppManifest holds the fields we use from steamapps/appmanifest_<appid>.acf.
+type AppManifest struct {
+	AppID       string            `json:"app_id"`
+	Name        string            `json:"name"`
+	BuildID     string            `json:"build_id"`
+	LastUpdated int64             `json:"last_updated"`
+	SizeOnDisk  int64             `json:"size_on_disk"`
+	StateFlags  int               `json:"state_flags"`
+	Depots      map[string]string `json:"depots"` // depot ID -> manifest ID
+}
+
+// AppManifestPath returns the path of the app manifest SteamCMD writes for appID under installDir.
+func AppManifestPath(installDir, appID string) string {
+	return filepath.Join(installDir, "steamapps", "appmanifest_"+appID+".acf")
+}
+
+// ReadAppManifest parses the CS2 app manifest in installDir.
+func ReadAppManifest(installDir string) (*AppManifest, error) {
+	data, err := os.ReadFile(AppManifestPath(installDir, CS2AppID))
+	if err != nil {
+		return nil, err
+	}
+	return ParseAppManifest(data)
+}
+
+// ParseAppManifest parses the contents of an appmanifest_*.acf file.
+func ParseAppManifest(data []byte) (*AppManifest, error) {
+	root, err := valve.ParseVDF(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse app manifest: %w", err)
+	}
+	if root.Key != "AppState" && root.FindChild("appid") == nil {
+		return nil, fmt.Errorf("parse app manifest: missing AppState")
+	}
+
+	m := &AppManifest{
+		AppID:       root.GetString("appid"),
+		Name:        root.GetString("name"),
+		BuildID:     root.GetString("buildid"),
+		LastUpdated: parseInt64(root.GetString("LastUpdated")),
+		SizeOnDisk:  parseInt64(root.GetString("SizeOnDisk")),
+		StateFlags:  root.GetInt("StateFlags"),
+		Depots:      make(map[string]string),
+	}
+
+	if depots := root.FindChild("InstalledDepots"); depots != nil {
+		for _, d := range depots.Children {
+			m.Depots[d.Key] = d.GetString("manifest")
+		}
+	}
+	return m, nil
+}
+
+func parseInt64(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
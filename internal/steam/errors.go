@@ -0,0 +1,196 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies a SteamCMD failure.
+type ErrorKind string
+
+const (
+	ErrKindUpdateFailed   ErrorKind = "update_failed"   // state 0x602 and friends: the update job died, usually mid-download
+	ErrKindNetwork        ErrorKind = "network"         // state 0x402, lost connection to Steam
+	ErrKindTimeout        ErrorKind = "timeout"         // a request to Steam timed out
+	ErrKindNoSubscription ErrorKind = "no_subscription" // Steam refused the anonymous license; almost always transient
+	ErrKindRateLimited    ErrorKind = "rate_limited"
+	ErrKindDiskFull       ErrorKind = "disk_full"
+	ErrKindDiskWrite      ErrorKind = "disk_write" // files can't be written: permissions, antivirus, or a file in use
+	ErrKindUnknown        ErrorKind = "unknown"
+)
+
+// Error is a classified SteamCMD failure.
+type Error struct {
+	Kind      ErrorKind
+	Code      string // SteamCMD app state code such as "0x602", when it reported one
+	Line      string // the output line the failure was recognized from
+	Retryable bool
+	Hint      string // what the user can do about it
+	Err       error  // process exit error, if any
+}
+
+func (e *Error) Error() string {
+	msg := e.Line
+	if msg == "" && e.Err != nil {
+		msg = "steamcmd exit: " + e.Err.Error()
+	}
+	if msg == "" {
+		msg = "steamcmd failed"
+	}
+	if e.Hint != "" {
+		msg += " — " + e.Hint
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err is a SteamCMD failure that is worth retrying.
+func IsRetryable(err error) bool {
+	var se *Error
+	return errors.As(err, &se) && se.Retryable
+}
+
+type errorRule struct {
+	re        *regexp.Regexp
+	kind      ErrorKind
+	retryable bool
+	hint      string
+}
+
+// Ordered most specific first. SteamCMD spells errors "Error!" and "ERROR!" depending on the
+// command, so patterns are case-insensitive.
+var errorRules = []errorRule{
+	{regexp.MustCompile(`(?i)not enough (free )?disk space|disk space is insufficient|state is 0x202\b`), ErrKindDiskFull, false,
+		"not enough free disk space; free up space on the install drive and try again"},
+	{regexp.MustCompile(`(?i)disk write failure`), ErrKindDiskWrite, false,
+		"files could not be written; check folder permissions, antivirus exclusions and that the server is stopped"},
+	{regexp.MustCompile(`(?i)rate limit exceeded`), ErrKindRateLimited, true,
+		"Steam is rate limiting this machine; wait a few minutes before trying again"},
+	{regexp.MustCompile(`(?i)no subscription`), ErrKindNoSubscription, true,
+		"Steam refused the anonymous license; this is usually transient and clears on retry"},
+	{regexp.MustCompile(`(?i)timed? ?out|timeout`), ErrKindTimeout, true,
+		"Steam did not answer in time; try again in a few minutes"},
+	{regexp.MustCompile(`(?i)state is 0x402\b|connection .*(failed|lost)|no connection|failed to connect`), ErrKindNetwork, true,
+		"lost connection to Steam; check the network connection"},
+	{regexp.MustCompile(`(?i)state is 0x[0-9a-f]+ after update job|failed to install app|update job failed`), ErrKindUpdateFailed, true,
+		"the Steam update job failed, usually mid-download; running it again resumes where it stopped"},
+}
+
+var stateCodeRe = regexp.MustCompile(`(?i)state is (0x[0-9a-f]+)`)
+
+// ClassifyLine recognizes a SteamCMD output line reporting a failure. It returns nil for
+// lines that aren't errors.
+func ClassifyLine(line string) *Error {
+	lower := strings.ToLower(line)
+	isError := strings.HasPrefix(lower, "error!") || strings.Contains(lower, "failed") ||
+		strings.Contains(lower, "timed out") || strings.Contains(lower, "rate limit")
+	if !isError {
+		return nil
+	}
+	e := &Error{Kind: ErrKindUnknown, Line: line}
+	if m := stateCodeRe.FindStringSubmatch(line); m != nil {
+		e.Code = strings.ToLower(m[1])
+	}
+	for _, r := range errorRules {
+		if r.re.MatchString(line) {
+			e.Kind, e.Retryable, e.Hint = r.kind, r.retryable, r.hint
+			return e
+		}
+	}
+	if !strings.HasPrefix(lower, "error!") {
+		return nil // "failed" in ordinary chatter, e.g. a failed self-update check SteamCMD recovers from
+	}
+	return e
+}
+
+// RetryPolicy controls how failed SteamCMD runs are retried.
+type RetryPolicy struct {
+	Attempts int           // total attempts, including the first
+	Delay    time.Duration // wait before the first retry; doubles after each attempt
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries transient failures twice, 15s then 30s apart.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Delay: 15 * time.Second, MaxDelay: 2 * time.Minute}
+
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.Delay << (attempt - 1)
+	var se *Error
+	if errors.As(err, &se) && se.Kind == ErrKindRateLimited {
+		d *= 4
+	}
+	return min(d, p.MaxDelay)
+}
+
+// retry runs fn until it succeeds, fails with a non-retryable error, or runs out of attempts.
+// onRetry is told about each retry before the wait.
+func (p RetryPolicy) retry(ctx context.Context, fn func() error, onRetry func(attempt int, err error, wait time.Duration)) error {
+	attempts := max(1, p.Attempts)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		wait := p.delay(attempt, err)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Space SteamCMD needs for CS2 beyond what's already installed. A fresh install downloads
+// everything; an update stages changed chunks next to the existing files.
+const (
+	CS2InstallBytes uint64 = 40 << 30
+	CS2UpdateBytes  uint64 = 8 << 30
+)
+
+// CheckFreeSpace fails with ErrKindDiskFull when the drive holding installDir has less than
+// CS2 needs. installDir doesn't have to exist yet.
+func CheckFreeSpace(installDir string) error {
+	need := CS2InstallBytes
+	if _, err := ReadAppManifest(installDir); err == nil {
+		need = CS2UpdateBytes
+	}
+	free, err := freeSpace(existingParent(installDir))
+	if err != nil {
+		return nil // can't tell; let SteamCMD report it
+	}
+	if free >= need {
+		return nil
+	}
+	return &Error{
+		Kind: ErrKindDiskFull,
+		Line: fmt.Sprintf("only %.1f GB free at %s, CS2 needs about %.0f GB", gb(free), installDir, gb(need)),
+		Hint: "free up space or choose another install directory",
+	}
+}
+
+func gb(n uint64) float64 { return float64(n) / (1 << 30) }
+
+// existingParent walks up from p to the first directory that exists.
+func existingParent(p string) string {
+	p, _ = filepath.Abs(p)
+	for {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		p = parent
+	}
+}
@@ -0,0 +1,352 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrJobCancelled is returned by Wait for a job that was cancelled.
+var ErrJobCancelled = errors.New("steamcmd job cancelled")
+
+// JobStatus is the lifecycle state of a SteamCMD job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+const (
+	jobLogLines  = 200 // raw output lines kept per job
+	finishedJobs = 50  // finished jobs kept for listing
+)
+
+// Job is a snapshot of a queued or finished SteamCMD job.
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"` // "install", "update", "workshop", "app_info", ...
+	InstanceID string     `json:"instance_id,omitempty"`
+	Title      string     `json:"title"`
+	Status     JobStatus  `json:"status"`
+	Progress   *Progress  `json:"progress,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	ErrorKind  ErrorKind  `json:"error_kind,omitempty"` // set when SteamCMD's failure was recognized
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Log        []string   `json:"log,omitempty"` // only filled by JobQueue.Get
+}
+
+// JobFunc does a job's work. It must stop when ctx is cancelled; progressCh and lineFn
+// are owned by the queue, which closes progressCh after the func returns.
+type JobFunc func(ctx context.Context, progressCh chan<- Progress, lineFn func(string)) error
+
+// JobSpec describes a job to submit.
+type JobSpec struct {
+	Kind       string
+	InstanceID string
+	Title      string
+	// Key serializes jobs that touch the same files, usually the install dir: jobs with the
+	// same key never run at the same time, whatever the queue's concurrency.
+	Key string
+	Run JobFunc
+}
+
+type job struct {
+	Job
+	key    string
+	run    JobFunc
+	log    []string
+	cancel context.CancelFunc
+	ctx    context.Context
+	done   chan struct{}
+	err    error
+}
+
+func (j *job) snapshot() Job {
+	s := j.Job
+	if j.Progress != nil {
+		p := *j.Progress
+		s.Progress = &p
+	}
+	return s
+}
+
+// JobQueue runs SteamCMD jobs in submission order with at most limit running at once.
+// SteamCMD keeps its state in its own directory, so a limit of 1 is the safe default.
+type JobQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	jobs    []*job
+	busy    map[string]bool
+
+	onUpdate func(Job)
+	onLine   func(jobID, line string)
+}
+
+// NewJobQueue creates a queue running at most limit jobs at once (minimum 1).
+func NewJobQueue(limit int) *JobQueue {
+	return &JobQueue{limit: max(1, limit), busy: make(map[string]bool)}
+}
+
+// SetOnUpdate sets the callback for job state and progress changes.
+func (q *JobQueue) SetOnUpdate(fn func(Job)) { q.onUpdate = fn }
+
+// SetOnLine sets the callback for raw SteamCMD output lines.
+func (q *JobQueue) SetOnLine(fn func(jobID, line string)) { q.onLine = fn }
+
+// SetLimit changes how many jobs may run at once. Running jobs are not affected.
+func (q *JobQueue) SetLimit(limit int) {
+	q.mu.Lock()
+	q.limit = max(1, limit)
+	q.dispatch()
+	q.mu.Unlock()
+}
+
+// Submit queues a job and returns its ID.
+func (q *JobQueue) Submit(spec JobSpec) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job: Job{
+			ID:         uuid.NewString(),
+			Kind:       spec.Kind,
+			InstanceID: spec.InstanceID,
+			Title:      spec.Title,
+			Status:     JobQueued,
+			CreatedAt:  time.Now(),
+		},
+		key:    spec.Key,
+		run:    spec.Run,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	q.jobs = append(q.jobs, j)
+	snap := j.snapshot()
+	q.mu.Unlock()
+	q.emit(snap)
+
+	q.mu.Lock()
+	q.dispatch()
+	q.mu.Unlock()
+	return j.ID
+}
+
+// Do submits a job and waits for it to finish.
+func (q *JobQueue) Do(spec JobSpec) (string, error) {
+	id := q.Submit(spec)
+	return id, q.Wait(id)
+}
+
+// Wait blocks until the job finishes and returns its error.
+func (q *JobQueue) Wait(id string) error {
+	q.mu.Lock()
+	j := q.find(id)
+	q.mu.Unlock()
+	if j == nil {
+		return fmt.Errorf("job %s not found", id)
+	}
+	<-j.done
+	return j.err
+}
+
+// Cancel cancels a queued job, or kills the SteamCMD process of a running one.
+func (q *JobQueue) Cancel(id string) error {
+	q.mu.Lock()
+	j := q.find(id)
+	if j == nil {
+		q.mu.Unlock()
+		return fmt.Errorf("job %s not found", id)
+	}
+	switch j.Status {
+	case JobQueued:
+		j.cancel()
+		q.finish(j, ErrJobCancelled)
+		snap := j.snapshot()
+		q.mu.Unlock()
+		q.emit(snap)
+		return nil
+	case JobRunning:
+		j.cancel() // run() records the outcome once the process has exited
+		q.mu.Unlock()
+		return nil
+	default:
+		q.mu.Unlock()
+		return fmt.Errorf("job already %s", j.Status)
+	}
+}
+
+// Get returns a job including its recent output.
+func (q *JobQueue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j := q.find(id)
+	if j == nil {
+		return nil, false
+	}
+	s := j.snapshot()
+	s.Log = append([]string(nil), j.log...)
+	return &s, true
+}
+
+// List returns all known jobs, oldest first.
+func (q *JobQueue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		out = append(out, j.snapshot())
+	}
+	return out
+}
+
+// Close cancels every queued and running job.
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	ids := make([]string, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		if j.Status == JobQueued || j.Status == JobRunning {
+			ids = append(ids, j.ID)
+		}
+	}
+	q.mu.Unlock()
+	for _, id := range ids {
+		q.Cancel(id)
+	}
+}
+
+func (q *JobQueue) find(id string) *job {
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// dispatch starts queued jobs while there is room. Callers hold q.mu.
+func (q *JobQueue) dispatch() {
+	for _, j := range q.jobs {
+		if q.running >= q.limit {
+			return
+		}
+		if j.Status != JobQueued || (j.key != "" && q.busy[j.key]) {
+			continue
+		}
+		now := time.Now()
+		j.Status = JobRunning
+		j.StartedAt = &now
+		q.running++
+		if j.key != "" {
+			q.busy[j.key] = true
+		}
+		go q.exec(j)
+	}
+}
+
+func (q *JobQueue) exec(j *job) {
+	q.mu.Lock()
+	snap := j.snapshot()
+	q.mu.Unlock()
+	q.emit(snap)
+
+	progressCh := make(chan Progress, 100)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for p := range progressCh {
+			q.mu.Lock()
+			j.Progress = &p
+			snap := j.snapshot()
+			q.mu.Unlock()
+			q.emit(snap)
+		}
+	}()
+
+	err := j.run(j.ctx, progressCh, func(line string) {
+		q.mu.Lock()
+		j.log = append(j.log, line)
+		if len(j.log) > jobLogLines {
+			j.log = j.log[len(j.log)-jobLogLines:]
+		}
+		q.mu.Unlock()
+		if q.onLine != nil {
+			q.onLine(j.ID, line)
+		}
+	})
+	close(progressCh)
+	<-forwarded
+
+	if j.ctx.Err() != nil {
+		err = ErrJobCancelled
+	}
+
+	q.mu.Lock()
+	q.running--
+	delete(q.busy, j.key)
+	q.finish(j, err)
+	snap = j.snapshot()
+	q.prune()
+	q.dispatch()
+	q.mu.Unlock()
+	q.emit(snap)
+}
+
+// finish records a job's outcome. Callers hold q.mu.
+func (q *JobQueue) finish(j *job, err error) {
+	now := time.Now()
+	j.FinishedAt = &now
+	j.err = err
+	switch {
+	case errors.Is(err, ErrJobCancelled):
+		j.Status = JobCancelled
+		j.Error = err.Error()
+	case err != nil:
+		j.Status = JobFailed
+		j.Error = err.Error()
+		var se *Error
+		if errors.As(err, &se) {
+			j.ErrorKind = se.Kind
+		}
+	default:
+		j.Status = JobDone
+	}
+	j.cancel()
+	close(j.done)
+}
+
+// prune drops the oldest finished jobs beyond finishedJobs. Callers hold q.mu.
+func (q *JobQueue) prune() {
+	finished := 0
+	for _, j := range q.jobs {
+		if j.FinishedAt != nil {
+			finished++
+		}
+	}
+	kept := q.jobs[:0]
+	for _, j := range q.jobs {
+		if j.FinishedAt != nil && finished > finishedJobs {
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	q.jobs = kept
+}
+
+func (q *JobQueue) emit(j Job) {
+	if q.onUpdate != nil {
+		q.onUpdate(j)
+	}
+}
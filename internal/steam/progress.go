@@ -1,8 +1,11 @@
 package steam
 
 import (
+	"bytes"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Progress represents SteamCMD operation progress.
@@ -10,15 +13,31 @@ type Progress struct {
 	Stage   string  // "downloading", "validating", "installing", "complete", "error"
 	Percent float64 // 0-100
 	Message string  // human-readable message
+
+	// Byte counts of the current stage, when SteamCMD prints them.
+	BytesDone  int64
+	BytesTotal int64
+
+	// Filled in by the tracker from successive lines.
+	Rate         float64     // smoothed bytes per second in the current stage
+	ETASeconds   float64     // estimated time left in the current stage; 0 if unknown
+	StageSeconds float64     // time spent in the current stage so far
+	Stages       []StageTime // finished stages, in order
+}
+
+// StageTime is how long a finished stage took.
+type StageTime struct {
+	Stage   string
+	Seconds float64
 }
 
 var (
 	// Update state (0x61) downloading, progress: 45.23 (12345 / 67890)
-	progressRe = regexp.MustCompile(`Update state \(0x[0-9a-fA-F]+\) (\w+), progress:\s*([\d.]+)`)
+	// Update state (0x5) verifying install, progress: 3.08 (1073741824 / 34816208342)
+	// Linux builds print the same with varying spacing and sometimes without the byte counts.
+	progressRe = regexp.MustCompile(`Update state \(0x[0-9a-fA-F]+\)\s+([A-Za-z][A-Za-z ]*?)\s*,\s*progress:\s*([\d.]+)(?:\s*\(\s*(\d+)\s*/\s*(\d+)\s*\))?`)
 	// Success! App '730' fully installed.
 	successRe = regexp.MustCompile(`Success! App '\d+' fully installed\.`)
-	// Error!
-	errorRe = regexp.MustCompile(`^Error!`)
 )
 
 // ParseProgressLine parses a SteamCMD stdout line and returns a Progress struct if the line
@@ -28,9 +47,9 @@ func ParseProgressLine(line string) *Progress {
 		return nil
 	}
 
-	// Error
-	if errorRe.MatchString(line) {
-		return &Progress{Stage: "error", Percent: 0, Message: line}
+	// Error, with what to do about it when we recognize it
+	if e := ClassifyLine(line); e != nil {
+		return &Progress{Stage: "error", Percent: 0, Message: e.Error()}
 	}
 
 	// Success/complete
@@ -41,23 +60,95 @@ func ParseProgressLine(line string) *Progress {
 	// Update state: downloading, validating, preallocating, reconfiguring, etc.
 	m := progressRe.FindStringSubmatch(line)
 	if m != nil {
-		stage := m[1]
 		percent, _ := strconv.ParseFloat(m[2], 64)
-
-		// Normalize stage names
-		switch stage {
-		case "downloading":
-		case "validating":
-		case "preallocating", "reconfiguring":
-			stage = "installing"
-		}
-
-		return &Progress{
-			Stage:   stage,
+		p := &Progress{
+			Stage:   normalizeStage(m[1]),
 			Percent: percent,
-			Message: line,
+			Message: strings.TrimSpace(line),
+		}
+		if m[3] != "" {
+			p.BytesDone, _ = strconv.ParseInt(m[3], 10, 64)
+			p.BytesTotal, _ = strconv.ParseInt(m[4], 10, 64)
 		}
+		return p
 	}
 
 	return nil
 }
+
+// normalizeStage maps SteamCMD's state names onto the stages the UI knows.
+func normalizeStage(stage string) string {
+	stage = strings.ToLower(strings.Join(strings.Fields(stage), " "))
+	switch {
+	case stage == "downloading":
+		return "downloading"
+	case stage == "validating", strings.HasPrefix(stage, "verifying"):
+		return "validating"
+	case stage == "preallocating", stage == "reconfiguring", stage == "committing", stage == "staging":
+		return "installing"
+	}
+	return strings.ReplaceAll(stage, " ", "_")
+}
+
+// rateSmoothing weighs the latest sample in the moving average of the transfer rate.
+const rateSmoothing = 0.3
+
+// progressTracker turns successive parsed lines into rate, ETA and stage timings.
+type progressTracker struct {
+	stage      string
+	stageStart time.Time
+	stages     []StageTime
+
+	lastBytes int64
+	lastAt    time.Time
+	rate      float64
+}
+
+func (t *progressTracker) update(p *Progress, now time.Time) {
+	if p.Stage != t.stage {
+		if t.stage != "" {
+			t.stages = append(t.stages, StageTime{Stage: t.stage, Seconds: now.Sub(t.stageStart).Seconds()})
+		}
+		t.stage, t.stageStart = p.Stage, now
+		t.lastBytes, t.lastAt, t.rate = p.BytesDone, now, 0
+	} else if p.BytesTotal > 0 {
+		// SteamCMD prints roughly once a second; ignore bursts so the rate doesn't spike.
+		if dt := now.Sub(t.lastAt).Seconds(); dt >= 0.5 && p.BytesDone >= t.lastBytes {
+			sample := float64(p.BytesDone-t.lastBytes) / dt
+			if t.rate == 0 {
+				t.rate = sample
+			} else {
+				t.rate = rateSmoothing*sample + (1-rateSmoothing)*t.rate
+			}
+			t.lastBytes, t.lastAt = p.BytesDone, now
+		}
+	}
+
+	p.StageSeconds = now.Sub(t.stageStart).Seconds()
+	p.Stages = append([]StageTime(nil), t.stages...)
+	p.Rate = t.rate
+	if t.rate > 0 && p.BytesTotal > p.BytesDone {
+		p.ETASeconds = float64(p.BytesTotal-p.BytesDone) / t.rate
+	}
+}
+
+// scanLines splits SteamCMD output on \n, \r\n and bare \r; some builds redraw progress
+// lines with carriage returns only.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		if data[i] == '\r' && i+1 == len(data) && !atEOF {
+			return 0, nil, nil // might be the start of \r\n; wait for more
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
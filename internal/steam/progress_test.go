@@ -0,0 +1,163 @@
+package steam
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden from the parser's output")
+
+// parseLog runs a captured SteamCMD log through the same splitting and parsing as runOnce
+// and renders each progress update as "stage percent done/total".
+func parseLog(t *testing.T, name string) (golden string, stages []string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		p := ParseProgressLine(line)
+		if p == nil {
+			continue
+		}
+		fmt.Fprintf(&out, "%s %.2f %d/%d\n", p.Stage, p.Percent, p.BytesDone, p.BytesTotal)
+		if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+			stages = append(stages, p.Stage)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), stages
+}
+
+// TestParseProgressGolden runs every testdata/*.log through the parser and compares the
+// result with the matching .golden file; see testdata/README.md for adding captured logs.
+func TestParseProgressGolden(t *testing.T) {
+	logs, err := filepath.Glob(filepath.Join("testdata", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 {
+		t.Fatal("no testdata/*.log files")
+	}
+	for _, path := range logs {
+		name := filepath.Base(path)
+		t.Run(name, func(t *testing.T) {
+			got, stages := parseLog(t, name)
+			if len(stages) == 0 {
+				t.Errorf("no progress parsed from %s", name)
+			}
+			goldenPath := strings.TrimSuffix(path, ".log") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("parsed progress differs from %s:\ngot:\n%s\nwant:\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
+
+func TestParseProgressLine(t *testing.T) {
+	for _, tc := range []struct {
+		line        string
+		stage       string // "" for lines that aren't progress
+		percent     float64
+		done, total int64
+	}{
+		{" Update state (0x61) downloading, progress: 1.20 (417794500 / 34816208342)", "downloading", 1.20, 417794500, 34816208342},
+		{" Update state (0x81) verifying update, progress: 12.50", "validating", 12.50, 0, 0},
+		{" Update state (0x5) verifying install, progress: 3.08 (1073741824 / 34816208342)", "validating", 3.08, 1073741824, 34816208342},
+		{" Update state (0x101) committing, progress: 100.00 (10 / 10)", "installing", 100, 10, 10},
+		{" Update state (0x61)  downloading ,progress:  50.00  ( 5 / 10 )", "downloading", 50, 5, 10},
+		{"Success! App '730' fully installed.", "complete", 100, 0, 0},
+		{"Error! App '730' state is 0x602 after update job.", "error", 0, 0, 0},
+		{"Waiting for user info...OK", "", 0, 0, 0},
+		{"[  0%] Checking for available updates...", "", 0, 0, 0},
+	} {
+		p := ParseProgressLine(strings.TrimSpace(tc.line))
+		if tc.stage == "" {
+			if p != nil {
+				t.Errorf("%q: got %+v, want nil", tc.line, p)
+			}
+			continue
+		}
+		if p == nil {
+			t.Errorf("%q: got nil, want %s", tc.line, tc.stage)
+			continue
+		}
+		if p.Stage != tc.stage || !near(p.Percent, tc.percent) || p.BytesDone != tc.done || p.BytesTotal != tc.total {
+			t.Errorf("%q: got %s %.2f %d/%d, want %s %.2f %d/%d",
+				tc.line, p.Stage, p.Percent, p.BytesDone, p.BytesTotal, tc.stage, tc.percent, tc.done, tc.total)
+		}
+	}
+}
+
+func TestScanLinesCarriageReturns(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("a\rb\r\nc\nd\r"))
+	scanner.Split(scanLines)
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if strings.Join(got, "|") != "a|b|c|d" {
+		t.Fatalf("lines = %q", got)
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(sec float64) time.Time { return t0.Add(time.Duration(sec * float64(time.Second))) }
+	dl := func(done int64) *Progress {
+		return &Progress{Stage: "downloading", BytesDone: done, BytesTotal: 1000}
+	}
+
+	var tr progressTracker
+	steps := []struct {
+		p            *Progress
+		at           float64
+		rate, eta    float64
+		stageSeconds float64
+	}{
+		{dl(0), 0, 0, 0, 0},
+		{dl(100), 1, 100, 9, 1},           // first sample sets the rate
+		{dl(150), 1.2, 100, 8.5, 1.2},     // under half a second after the last sample: ignored
+		{dl(300), 2, 130, 700.0 / 130, 2}, // 0.3*200 + 0.7*100
+		{dl(250), 3, 130, 750.0 / 130, 3}, // bytes going backwards: ignored
+		{&Progress{Stage: "validating", Percent: 10}, 4, 0, 0, 0},
+		{&Progress{Stage: "validating", Percent: 60}, 6.5, 0, 0, 2.5},
+	}
+	for i, s := range steps {
+		tr.update(s.p, at(s.at))
+		if !near(s.p.Rate, s.rate) || !near(s.p.ETASeconds, s.eta) || !near(s.p.StageSeconds, s.stageSeconds) {
+			t.Errorf("step %d: rate %.2f eta %.2f stage %.2fs, want %.2f %.2f %.2fs",
+				i, s.p.Rate, s.p.ETASeconds, s.p.StageSeconds, s.rate, s.eta, s.stageSeconds)
+		}
+	}
+
+	last := steps[len(steps)-1].p
+	if len(last.Stages) != 1 || last.Stages[0].Stage != "downloading" || !near(last.Stages[0].Seconds, 4) {
+		t.Errorf("finished stages = %+v, want downloading after 4s", last.Stages)
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
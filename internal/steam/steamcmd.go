@@ -3,6 +3,7 @@ package steam
 import (
 	"archive/zip"
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"cs2admin/internal/pkg/logger"
 )
@@ -20,13 +22,19 @@ const steamcmdURL = "https://steamcdn-a.akamaihd.net/client/installer/steamcmd.z
 
 // SteamCMD wraps the SteamCMD CLI for installing and updating CS2.
 type SteamCMD struct {
-	path string // directory where steamcmd.exe lives
-	mu   sync.Mutex
+	path  string // directory where steamcmd.exe lives
+	mu    sync.Mutex
+	retry RetryPolicy
 }
 
 // New creates a new SteamCMD wrapper. basePath is the directory where SteamCMD will be installed.
 func New(basePath string) *SteamCMD {
-	return &SteamCMD{path: basePath}
+	return &SteamCMD{path: basePath, retry: DefaultRetryPolicy}
+}
+
+// SetRetryPolicy changes how transient SteamCMD failures are retried.
+func (s *SteamCMD) SetRetryPolicy(p RetryPolicy) {
+	s.retry = p
 }
 
 // ExePath returns the full path to steamcmd.exe.
@@ -127,15 +135,37 @@ func extractFile(f *zip.File, dest string) error {
 }
 
 // Run executes SteamCMD with the given args, parses stdout for progress, and sends
-// Progress structs to progressCh. Closes progressCh when done. progressCh may be nil.
+// Progress structs to progressCh (may be nil). The caller owns progressCh.
 // lineFn is called for every raw stdout/stderr line (may be nil).
-func (s *SteamCMD) Run(args []string, progressCh chan<- Progress, lineFn ...func(string)) error {
+// Failures are returned as *Error; retryable ones are retried per the retry policy, with a
+// note to lineFn before each retry. Cancelling ctx kills the SteamCMD process.
+func (s *SteamCMD) Run(ctx context.Context, args []string, progressCh chan<- Progress, lineFn ...func(string)) error {
 	if err := s.EnsureInstalled(); err != nil {
 		return err
 	}
 
-	cmd := exec.Command(s.ExePath(), args...)
+	// Extract optional line callback
+	var onLine func(string)
+	if len(lineFn) > 0 && lineFn[0] != nil {
+		onLine = lineFn[0]
+	}
+
+	return s.retry.retry(ctx, func() error {
+		return s.runOnce(ctx, args, progressCh, onLine)
+	}, func(attempt int, err error, wait time.Duration) {
+		logger.Log.Warn().Err(err).Int("attempt", attempt).Dur("wait", wait).Msg("SteamCMD failed, retrying")
+		if onLine != nil {
+			onLine(fmt.Sprintf("Attempt %d failed: %v", attempt, err))
+			onLine(fmt.Sprintf("Retrying in %s ...", wait))
+		}
+	})
+}
+
+func (s *SteamCMD) runOnce(ctx context.Context, args []string, progressCh chan<- Progress, onLine func(string)) error {
+	cmd := exec.CommandContext(ctx, s.ExePath(), args...)
 	hideWindow(cmd) // prevent visible console window on Windows
+	// SteamCMD restarts itself after self-updates; don't wait forever on a child holding stdout.
+	cmd.WaitDelay = 5 * time.Second
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("stdout pipe: %w", err)
@@ -146,13 +176,11 @@ func (s *SteamCMD) Run(args []string, progressCh chan<- Progress, lineFn ...func
 		return fmt.Errorf("start steamcmd: %w", err)
 	}
 
-	// Extract optional line callback
-	var onLine func(string)
-	if len(lineFn) > 0 && lineFn[0] != nil {
-		onLine = lineFn[0]
-	}
-
+	var failure *Error // last error SteamCMD reported
+	succeeded := false
+	var tracker progressTracker
 	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanLines)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
@@ -165,69 +193,90 @@ func (s *SteamCMD) Run(args []string, progressCh chan<- Progress, lineFn ...func
 			onLine(line)
 		}
 
+		if e := ClassifyLine(line); e != nil {
+			failure = e
+		}
+		if successRe.MatchString(line) {
+			succeeded = true
+		}
+
 		if p := ParseProgressLine(line); p != nil && progressCh != nil {
+			tracker.update(p, time.Now())
 			select {
 			case progressCh <- *p:
 			default:
-				// Channel full or closed; don't block
+				// Channel full; don't block
 			}
 		}
 	}
 
 	if err := cmd.Wait(); err != nil {
-		if progressCh != nil {
-			close(progressCh)
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		return fmt.Errorf("steamcmd exit: %w", err)
+		if failure == nil {
+			return &Error{Kind: ErrKindUnknown, Err: err}
+		}
+		failure.Err = err
+		return failure
 	}
-
-	if progressCh != nil {
-		close(progressCh)
+	// SteamCMD sometimes exits 0 after "Error! App '730' state is 0x602 after update job."
+	if failure != nil && !succeeded && strings.HasPrefix(strings.ToLower(failure.Line), "error!") {
+		return failure
 	}
 	return nil
 }
 
-// InstallCS2 installs CS2 to installDir with validation.
-func (s *SteamCMD) InstallCS2(installDir string, progressCh chan<- Progress, lineFn ...func(string)) error {
-	absDir, err := filepath.Abs(installDir)
-	if err != nil {
-		return fmt.Errorf("resolve install dir: %w", err)
+// Branch selects the Steam branch app_update installs from.
+type Branch struct {
+	Name     string // empty means public
+	Password string // for password-protected betas
+}
+
+// appUpdateArgs builds an app_update of CS2 into absDir. The branch is always passed, so
+// moving an install back to public works too.
+func appUpdateArgs(absDir string, branch Branch, validate bool) []string {
+	name := branch.Name
+	if name == "" {
+		name = BranchPublic
 	}
 	args := []string{
 		"+login", "anonymous",
 		"+force_install_dir", absDir,
-		"+app_update", "730", "validate",
-		"+quit",
+		"+app_update", CS2AppID, "-beta", name,
+	}
+	if branch.Password != "" {
+		args = append(args, "-betapassword", branch.Password)
+	}
+	if validate {
+		args = append(args, "validate")
 	}
-	return s.Run(args, progressCh, lineFn...)
+	return append(args, "+quit")
 }
 
-// UpdateCS2 updates CS2 at installDir without validation.
-func (s *SteamCMD) UpdateCS2(installDir string, progressCh chan<- Progress) error {
+// InstallCS2 installs CS2 from branch to installDir with validation.
+func (s *SteamCMD) InstallCS2(ctx context.Context, installDir string, branch Branch, progressCh chan<- Progress, lineFn ...func(string)) error {
 	absDir, err := filepath.Abs(installDir)
 	if err != nil {
 		return fmt.Errorf("resolve install dir: %w", err)
 	}
-	args := []string{
-		"+login", "anonymous",
-		"+force_install_dir", absDir,
-		"+app_update", "730",
-		"+quit",
-	}
-	return s.Run(args, progressCh)
+	return s.Run(ctx, appUpdateArgs(absDir, branch, true), progressCh, lineFn...)
 }
 
-// ValidateCS2 validates CS2 files at installDir.
-func (s *SteamCMD) ValidateCS2(installDir string, progressCh chan<- Progress) error {
+// UpdateCS2 updates CS2 at installDir from branch without validation.
+func (s *SteamCMD) UpdateCS2(ctx context.Context, installDir string, branch Branch, progressCh chan<- Progress, lineFn ...func(string)) error {
 	absDir, err := filepath.Abs(installDir)
 	if err != nil {
 		return fmt.Errorf("resolve install dir: %w", err)
 	}
-	args := []string{
-		"+login", "anonymous",
-		"+force_install_dir", absDir,
-		"+app_update", "730", "validate",
-		"+quit",
+	return s.Run(ctx, appUpdateArgs(absDir, branch, false), progressCh, lineFn...)
+}
+
+// ValidateCS2 validates CS2 files at installDir against branch.
+func (s *SteamCMD) ValidateCS2(ctx context.Context, installDir string, branch Branch, progressCh chan<- Progress, lineFn ...func(string)) error {
+	absDir, err := filepath.Abs(installDir)
+	if err != nil {
+		return fmt.Errorf("resolve install dir: %w", err)
 	}
-	return s.Run(args, progressCh)
+	return s.Run(ctx, appUpdateArgs(absDir, branch, true), progressCh, lineFn...)
 }
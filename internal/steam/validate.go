@@ -0,0 +1,78 @@
+package steam
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SteamCMD doesn't say which files a validate re-downloaded, so repairs compare a stat
+// snapshot of the install taken before and after.
+
+// FileStat is the size and modification time of one file in a FileSnapshot.
+type FileStat struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// FileSnapshot maps install-relative, slash-separated paths to their stats.
+type FileSnapshot map[string]FileStat
+
+// SnapshotFiles stats every file under installDir except SteamCMD's own bookkeeping in
+// steamapps/, which changes on every run.
+func SnapshotFiles(installDir string) (FileSnapshot, error) {
+	snap := make(FileSnapshot)
+	err := filepath.Walk(installDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(installDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel == "steamapps" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			snap[rel] = FileStat{Size: info.Size(), ModTime: info.ModTime().UTC()}
+		}
+		return nil
+	})
+	return snap, err
+}
+
+// SnapshotDiff lists what changed between two snapshots, each sorted.
+type SnapshotDiff struct {
+	Changed []string // present before and after, rewritten
+	Added   []string // missing before
+}
+
+// DiffSnapshots compares before and after. Files that only disappeared are ignored: validate
+// never deletes, so those are the server's own (logs, dumps) being rotated meanwhile.
+func DiffSnapshots(before, after FileSnapshot) SnapshotDiff {
+	var d SnapshotDiff
+	for rel, a := range after {
+		b, ok := before[rel]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, rel)
+		case b.Size != a.Size || !b.ModTime.Equal(a.ModTime):
+			d.Changed = append(d.Changed, rel)
+		}
+	}
+	sort.Strings(d.Changed)
+	sort.Strings(d.Added)
+	return d
+}
+
+// IsLogFile reports whether rel is server output rather than game content, so it can be left
+// out of repair reports.
+func IsLogFile(rel string) bool {
+	return strings.HasPrefix(rel, "game/csgo/logs/") || strings.HasSuffix(rel, ".log") || strings.HasSuffix(rel, ".mdmp")
+}
@@ -1,13 +1,14 @@
 package steam
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strconv"
 )
 
 // DownloadWorkshopItem downloads a Steam Workshop item for CS2 (App ID 730).
-func (s *SteamCMD) DownloadWorkshopItem(installDir string, workshopID int64, progressCh chan<- Progress) error {
+func (s *SteamCMD) DownloadWorkshopItem(ctx context.Context, installDir string, workshopID int64, progressCh chan<- Progress, lineFn ...func(string)) error {
 	absDir, err := filepath.Abs(installDir)
 	if err != nil {
 		return fmt.Errorf("resolve install dir: %w", err)
@@ -18,5 +19,5 @@ func (s *SteamCMD) DownloadWorkshopItem(installDir string, workshopID int64, pro
 		"+workshop_download_item", "730", strconv.FormatInt(workshopID, 10),
 		"+quit",
 	}
-	return s.Run(args, progressCh)
+	return s.Run(ctx, args, progressCh, lineFn...)
 }
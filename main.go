@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"cs2admin/internal/backup"
 	"cs2admin/internal/config"
 	"cs2admin/internal/models"
 	"cs2admin/internal/pkg/crypto"
@@ -41,7 +42,17 @@ func main() {
 		logger.Log.Fatal().Err(err).Msg("Failed to derive encryption key")
 	}
 
-	// 4. Open SQLite database
+	// 4. Swap in an app-state restore staged by the previous run
+	if applied, err := backup.ApplyStagedAppState(cfg.AppDataDir); err != nil {
+		logger.Log.Error().Err(err).Msg("Failed to apply staged app-state restore")
+	} else if applied {
+		logger.Log.Info().Msg("Applied staged app-state restore")
+		if cfg, err = config.Load(); err != nil {
+			logger.Log.Fatal().Err(err).Msg("Failed to reload config")
+		}
+	}
+
+	// 5. Open SQLite database
 	db, err := gorm.Open(sqlite.Open(cfg.GetDBPath()), &gorm.Config{
 		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
 	})
@@ -50,16 +61,16 @@ func main() {
 	}
 	logger.Log.Info().Str("path", cfg.GetDBPath()).Msg("Database opened")
 
-	// 5. Run auto-migrations
+	// 6. Run auto-migrations
 	if err := models.AutoMigrate(db); err != nil {
 		logger.Log.Fatal().Err(err).Msg("Database migration failed")
 	}
 	logger.Log.Info().Msg("Database migrations complete")
 
-	// 6. Create application
+	// 7. Create application
 	app := NewApp(cfg, db, encKey)
 
-	// 7. Run Wails
+	// 8. Run Wails
 	err = wails.Run(&options.App{
 		Title:     "CS2 Admin",
 		Width:     1280,
@@ -13,6 +13,21 @@ Write-Host "Building CS2 Admin v$Version..."
 if (Test-Path $BuildDir) { Remove-Item $BuildDir -Recurse -Force }
 New-Item -ItemType Directory -Path $BuildDir | Out-Null
 
+# Publish the bundled CounterStrikeSharp plugins so they are embedded in the app
+$BundledDir = Join-Path $PSScriptRoot ".." "internal" "instance" "bundled"
+foreach ($plugin in @("CS2AdminStats", "CS2AdminSkins")) {
+    Write-Host "Publishing $plugin..."
+    $out = Join-Path $BundledDir $plugin
+    if (Test-Path $out) { Remove-Item $out -Recurse -Force }
+    $project = Join-Path $PSScriptRoot ".." "plugins" $plugin
+    dotnet publish $project -c Release -o $out -p:SourceRevisionId=$Version
+    if ($LASTEXITCODE -ne 0) { throw "dotnet publish $plugin failed" }
+    # The server provides the API assembly; a second copy in the plugin folder is ignored
+    Remove-Item (Join-Path $out "CounterStrikeSharp.API.dll") -ErrorAction SilentlyContinue
+    # Data files the plugin reads from its own folder
+    Get-ChildItem $project -Filter *.json -File | Copy-Item -Destination $out
+}
+
 # Build Windows
 Write-Host "Building Windows x64..."
 $env:GOOS = "windows"